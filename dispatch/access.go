@@ -0,0 +1,261 @@
+package dispatch
+
+// IAM principals mapped to Kubernetes groups in the aws-auth ConfigMap of a cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi-eks/sdk/go/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	accessAction      string = "access"
+	accessAdd         string = "add"
+	accessRemove      string = "remove"
+	accessList        string = "list"
+	accessMappingsKey string = "dispatch:accessMappings"
+	adminGroup        string = "system:masters"
+	roleKind          string = "role"
+	userKind          string = "user"
+)
+
+var iamPrincipalARN = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:(role|user)/[\w+=,.@/-]+$`)
+
+var kubernetesName = regexp.MustCompile(`^[\w:.@{}-]+$`)
+
+// IAM role or user granted Kubernetes groups, the username defaults to the principal name
+type accessMapping struct {
+	ARN      string   `yaml:"arn" json:"arn"`
+	Username string   `yaml:"username,omitempty" json:"username,omitempty"`
+	Groups   []string `yaml:"groups" json:"groups"`
+}
+
+// repeatable flag granting IAM roles or users Kubernetes admin access
+type adminFlags struct {
+	kind     string
+	mappings *[]accessMapping
+}
+
+func (a adminFlags) String() string {
+	if a.mappings == nil {
+		return ""
+	}
+
+	var arns []string
+
+	for _, mapping := range *a.mappings {
+		if principalKind(mapping.ARN) == a.kind {
+			arns = append(arns, mapping.ARN)
+		}
+	}
+
+	return strings.Join(arns, ",")
+}
+
+func (a adminFlags) Set(arn string) error {
+	if principalKind(arn) != a.kind {
+		return fmt.Errorf("invalid IAM %s ARN %s (e.g. arn:aws:iam::123456789012:%s/name)", a.kind, arn, a.kind)
+	}
+
+	*a.mappings = append(*a.mappings, accessMapping{ARN: arn, Groups: []string{adminGroup}})
+
+	return nil
+}
+
+// role or user, empty for ARNs which are not IAM principals
+func principalKind(arn string) string {
+	match := iamPrincipalARN.FindStringSubmatch(arn)
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
+}
+
+// aws-auth matches role ARNs without their path, e.g. the path of AWS SSO roles
+func normalizeMapping(mapping accessMapping) accessMapping {
+	kind := principalKind(mapping.ARN)
+	if kind == "" {
+		return mapping
+	}
+
+	prefix, name, _ := strings.Cut(mapping.ARN, ":"+kind+"/")
+	name = name[strings.LastIndex(name, "/")+1:]
+
+	if kind == roleKind {
+		mapping.ARN = prefix + ":role/" + name
+	}
+
+	if mapping.Username == "" {
+		mapping.Username = name
+	}
+
+	return mapping
+}
+
+func validateAccessMappings(mappings []accessMapping) error {
+	for _, mapping := range mappings {
+		if principalKind(mapping.ARN) == "" {
+			return fmt.Errorf("invalid IAM principal ARN %s, access is mapped for IAM roles and users", mapping.ARN)
+		}
+
+		if len(mapping.Groups) == 0 {
+			return fmt.Errorf("%s is not mapped to any Kubernetes groups", mapping.ARN)
+		}
+
+		for _, group := range mapping.Groups {
+			if !kubernetesName.MatchString(group) {
+				return fmt.Errorf("invalid Kubernetes group %s of %s", group, mapping.ARN)
+			}
+		}
+
+		if mapping.Username != "" && !kubernetesName.MatchString(mapping.Username) {
+			return fmt.Errorf("invalid Kubernetes username %s of %s", mapping.Username, mapping.ARN)
+		}
+	}
+
+	return nil
+}
+
+// later mappings of a principal replace earlier ones, sorted by ARN
+func mergeAccessMappings(mappingSets ...[]accessMapping) []accessMapping {
+	merged := map[string]accessMapping{}
+
+	for _, mappings := range mappingSets {
+		for _, mapping := range mappings {
+			mapping = normalizeMapping(mapping)
+			merged[mapping.ARN] = mapping
+		}
+	}
+
+	var sorted []accessMapping
+
+	for _, mapping := range merged {
+		sorted = append(sorted, mapping)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ARN < sorted[j].ARN })
+
+	return sorted
+}
+
+func removeAccessMapping(mappings []accessMapping, arn string) ([]accessMapping, error) {
+	var remaining []accessMapping
+
+	removed := normalizeMapping(accessMapping{ARN: arn})
+
+	for _, mapping := range mappings {
+		if mapping.ARN != removed.ARN {
+			remaining = append(remaining, mapping)
+		}
+	}
+
+	if len(remaining) == len(mappings) {
+		return nil, fmt.Errorf("%s has no access mapping", arn)
+	}
+
+	return remaining, nil
+}
+
+// access mappings stored with the stack config
+func storedAccessMappings(stackConfig map[string]string) []accessMapping {
+	var mappings []accessMapping
+
+	if stackConfig[accessMappingsKey] == "" {
+		return mappings
+	}
+
+	if err := json.Unmarshal([]byte(stackConfig[accessMappingsKey]), &mappings); err != nil {
+		reportErr(err, "read stored access mappings")
+	}
+
+	return mappings
+}
+
+// access mappings of a cluster after an access add or remove event
+func changedAccessMappings(event Event, stored []accessMapping) ([]accessMapping, error) {
+	if event.Subcommand == accessRemove {
+		return removeAccessMapping(stored, event.PrincipalARN)
+	}
+
+	return mergeAccessMappings(stored, event.AccessMappings), nil
+}
+
+// stack config of access mappings, no mappings remove the stored value
+func accessConfig(mappings []accessMapping) map[string]string {
+	if len(mappings) == 0 {
+		return map[string]string{accessMappingsKey: ""}
+	}
+
+	value, err := json.Marshal(mappings)
+	if err != nil {
+		reportErr(err, "create access mappings")
+	}
+
+	return map[string]string{accessMappingsKey: string(value)}
+}
+
+// role and user mappings added to the aws-auth ConfigMap by pulumi-eks
+func setAccessArgs(args *eks.ClusterArgs, mappings []accessMapping) {
+	var roles eks.RoleMappingArray
+
+	var users eks.UserMappingArray
+
+	for _, mapping := range mappings {
+		if principalKind(mapping.ARN) == roleKind {
+			roles = append(roles, eks.RoleMappingArgs{
+				RoleArn:  pulumi.String(mapping.ARN),
+				Username: pulumi.String(mapping.Username),
+				Groups:   pulumi.ToStringArray(mapping.Groups),
+			})
+		} else {
+			users = append(users, eks.UserMappingArgs{
+				UserArn:  pulumi.String(mapping.ARN),
+				Username: pulumi.String(mapping.Username),
+				Groups:   pulumi.ToStringArray(mapping.Groups),
+			})
+		}
+	}
+
+	if len(roles) > 0 {
+		args.RoleMappings = roles
+	}
+
+	if len(users) > 0 {
+		args.UserMappings = users
+	}
+}
+
+func printAccessMappings(mappings []accessMapping) {
+	for _, mapping := range mappings {
+		fmt.Printf("\t <> %s as %s, groups %s\n", mapping.ARN, mapping.Username, strings.Join(mapping.Groups, ", "))
+	}
+}
+
+// print the access mappings of a cluster
+func listAccess(event Event) {
+	summary, found := getStackSummaries(event.Bucket)[event.Name]
+	if !found {
+		fmt.Printf("\n %s was not found, exiting.\n\n", event.Name)
+		os.Exit(0)
+	}
+
+	mappings := storedAccessMappings(summary.Config)
+
+	fmt.Printf("\n - %s access mappings, the IAM identity which created the cluster also has admin access:\n", event.Name)
+
+	if len(mappings) == 0 {
+		fmt.Print("\t no additional IAM roles or users are mapped\n\n")
+
+		return
+	}
+
+	printAccessMappings(mappings)
+	fmt.Println()
+}
@@ -0,0 +1,129 @@
+package dispatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateAccessMappings(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping accessMapping
+		err     bool
+	}{
+		{name: "Role", mapping: accessMapping{ARN: "arn:aws:iam::123456789012:role/platform", Groups: []string{adminGroup}}},
+		{name: "User", mapping: accessMapping{ARN: "arn:aws:iam::123456789012:user/alice", Username: "alice", Groups: []string{"developers"}}},
+		{name: "Role path", mapping: accessMapping{ARN: "arn:aws:iam::123456789012:role/aws-reserved/sso.amazonaws.com/AWSReservedSSO_Admin_1a2b", Groups: []string{adminGroup}}},
+		{name: "Not a principal", mapping: accessMapping{ARN: "arn:aws:s3:::bucket", Groups: []string{adminGroup}}, err: true},
+		{name: "No groups", mapping: accessMapping{ARN: "arn:aws:iam::123456789012:user/alice"}, err: true},
+		{name: "Invalid group", mapping: accessMapping{ARN: "arn:aws:iam::123456789012:user/alice", Groups: []string{"dev ops"}}, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateAccessMappings([]accessMapping{test.mapping}); (err != nil) != test.err {
+				t.Errorf("validateAccessMappings unit test failure\n got: '%v', want error: '%v'", err, test.err)
+			}
+		})
+	}
+}
+
+func TestMergeAccessMappings(t *testing.T) {
+	defaults := []accessMapping{
+		{ARN: "arn:aws:iam::123456789012:role/team/platform", Groups: []string{adminGroup}},
+		{ARN: "arn:aws:iam::123456789012:user/bob", Groups: []string{"viewers"}},
+	}
+
+	flags := []accessMapping{
+		{ARN: "arn:aws:iam::123456789012:user/bob", Groups: []string{adminGroup}},
+	}
+
+	want := []accessMapping{
+		{ARN: "arn:aws:iam::123456789012:role/platform", Username: "platform", Groups: []string{adminGroup}},
+		{ARN: "arn:aws:iam::123456789012:user/bob", Username: "bob", Groups: []string{adminGroup}},
+	}
+
+	if got := mergeAccessMappings(defaults, nil, flags); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeAccessMappings unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+}
+
+func TestChangedAccessMappings(t *testing.T) {
+	stored := []accessMapping{
+		{ARN: "arn:aws:iam::123456789012:role/platform", Username: "platform", Groups: []string{adminGroup}},
+	}
+
+	add := Event{Subcommand: accessAdd, AccessMappings: []accessMapping{{ARN: "arn:aws:iam::123456789012:user/alice", Groups: []string{"developers"}}}}
+
+	added, err := changedAccessMappings(add, stored)
+	if err != nil || len(added) != 2 || added[1].Username != "alice" {
+		t.Errorf("changedAccessMappings unit test failure\n got: '%v', error: '%v'", added, err)
+	}
+
+	remove := Event{Subcommand: accessRemove, PrincipalARN: "arn:aws:iam::123456789012:role/team/platform"}
+
+	if removed, err := changedAccessMappings(remove, stored); err != nil || len(removed) != 0 {
+		t.Errorf("changedAccessMappings unit test failure\n got: '%v', error: '%v'", removed, err)
+	}
+
+	remove.PrincipalARN = "arn:aws:iam::123456789012:user/alice"
+
+	if _, err := changedAccessMappings(remove, stored); err == nil {
+		t.Errorf("changedAccessMappings unit test failure\n removing an unmapped principal must fail")
+	}
+}
+
+func TestAdminFlags(t *testing.T) {
+	var mappings []accessMapping
+
+	roles := adminFlags{kind: roleKind, mappings: &mappings}
+	users := adminFlags{kind: userKind, mappings: &mappings}
+
+	if err := roles.Set("arn:aws:iam::123456789012:role/platform"); err != nil {
+		t.Fatalf("adminFlags unit test failure\n error: '%v'", err)
+	}
+
+	if err := users.Set("arn:aws:iam::123456789012:role/platform"); err == nil {
+		t.Errorf("adminFlags unit test failure\n role ARNs must be rejected by -admin-user")
+	}
+
+	if len(mappings) != 1 || mappings[0].Groups[0] != adminGroup || roles.String() != mappings[0].ARN || users.String() != "" {
+		t.Errorf("adminFlags unit test failure\n got: '%v'", mappings)
+	}
+}
+
+func TestAccessUpdateProgram(t *testing.T) {
+	stackConfig := map[string]string{nodeSizeKey: "medium", nodeCountKey: "3", versionKey: k8sVersion}
+	admin := accessMapping{ARN: "arn:aws:iam::123456789012:role/admin", Username: "admin", Groups: []string{adminGroup}}
+
+	// access updates carry no node spec of their own
+	event := Event{Name: "my-cluster", User: "alice", Action: accessAction, Subcommand: accessAdd, AccessMappings: []accessMapping{admin}}
+
+	access, err := changedAccessMappings(event, storedAccessMappings(stackConfig))
+	if err != nil {
+		t.Fatalf("access update program test failure\n error: '%v'", err)
+	}
+
+	applyStoredSpec(&event, mergeStackConfig(stackConfig, accessConfig(access)))
+
+	resources := runClusterProgram(t, event, clusterSettings{access: access})
+
+	cluster, found := resources["eks:index:Cluster::my-cluster"]
+	if !found {
+		t.Fatalf("access update program test failure\n no EKS cluster in: '%v'", resources)
+	}
+
+	nodes := resources["eks:index:NodeGroupV2::my-cluster-nodes"]
+
+	if size := nodes["desiredCapacity"]; !size.IsNumber() || size.NumberValue() != 3 {
+		t.Errorf("access update program test failure\n got desired capacity: '%v', want the stored node count 3", size)
+	}
+
+	if instanceType := nodes["instanceType"]; !instanceType.IsString() || instanceType.StringValue() != mediumEC2 {
+		t.Errorf("access update program test failure\n got instance type: '%v', want: '%v'", instanceType, mediumEC2)
+	}
+
+	roles := cluster["roleMappings"]
+	if !roles.IsArray() || len(roles.ArrayValue()) != 1 || roles.ArrayValue()[0].ObjectValue()["roleArn"].StringValue() != admin.ARN {
+		t.Errorf("access update program test failure\n got role mappings: '%v', want: '%v'", roles, admin.ARN)
+	}
+}
@@ -0,0 +1,370 @@
+package dispatch
+
+// adopt existing EKS clusters into Dispatch management
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	adoptAction     string = "adopt"
+	adoptedKey      string = "dispatch:adopted"
+	adoptedFromKey  string = "dispatch:adoptedFrom"
+	oidcProviderARN string = ":oidc-provider/"
+)
+
+// properties of adopted resources left unmanaged by Dispatch
+var (
+	adoptedVPCIgnored = []string{
+		"tags", "tagsAll", "enableDnsHostnames", "enableDnsSupport", "instanceTenancy",
+		"enableNetworkAddressUsageMetrics", "assignGeneratedIpv6CidrBlock", "ipv6CidrBlock",
+		"ipv6CidrBlockNetworkBorderGroup", "ipv6IpamPoolId", "ipv6NetmaskLength",
+		"ipv4IpamPoolId", "ipv4NetmaskLength", "enableClassiclink", "enableClassiclinkDnsSupport",
+	}
+	adoptedClusterIgnored = []string{
+		"tags", "tagsAll", "vpcConfig", "enabledClusterLogTypes", "encryptionConfig",
+		"kubernetesNetworkConfig", "outpostConfig", "defaultAddonsToRemoves",
+	}
+	adoptedNodeGroupIgnored = []string{
+		"tags", "tagsAll", "amiType", "capacityType", "diskSize", "forceUpdateVersion", "instanceTypes",
+		"labels", "launchTemplate", "releaseVersion", "remoteAccess", "taints", "updateConfig", "version",
+		"subnetIds",
+	}
+	adoptedOIDCIgnored = []string{"tags", "tagsAll", "clientIdLists", "thumbprintLists"}
+)
+
+type adoptedNodeGroup struct {
+	Name          string   `json:"name"`
+	RoleARN       string   `json:"roleArn"`
+	Subnets       []string `json:"subnets"`
+	InstanceTypes []string `json:"instanceTypes"`
+	MinSize       int      `json:"minSize"`
+	MaxSize       int      `json:"maxSize"`
+	DesiredSize   int      `json:"desiredSize"`
+}
+
+// existing EKS cluster resources managed by an adopted stack
+type adoptedCluster struct {
+	Name            string             `json:"name"`
+	ARN             string             `json:"arn"`
+	RoleARN         string             `json:"roleArn"`
+	Version         string             `json:"version"`
+	CreatedAt       string             `json:"createdAt"`
+	VpcID           string             `json:"vpcId"`
+	VpcCIDR         string             `json:"vpcCidr"`
+	Subnets         []string           `json:"subnets"`
+	OIDCIssuer      string             `json:"oidcIssuer,omitempty"`
+	OIDCProviderARN string             `json:"oidcProviderArn,omitempty"`
+	NodeGroups      []adoptedNodeGroup `json:"nodeGroups"`
+}
+
+// run an aws CLI command, errors include the command's stderr
+func awsCLI(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("aws", args...)
+	cmd.Stderr = &stderr
+
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("aws %s: %w: %s", strings.Join(args[:2], " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return data, nil
+}
+
+// adopted cluster spec from EKS cluster and node group descriptions
+func adoptedClusterSpec(cluster *ekstypes.Cluster, nodeGroups []*ekstypes.Nodegroup) (adoptedCluster, error) {
+	name := aws.ToString(cluster.Name)
+
+	if cluster.Status != "" && cluster.Status != ekstypes.ClusterStatusActive {
+		return adoptedCluster{}, fmt.Errorf("EKS cluster %s is %s, only active clusters can be adopted", name, cluster.Status)
+	}
+
+	spec := adoptedCluster{
+		Name:    name,
+		ARN:     aws.ToString(cluster.Arn),
+		RoleARN: aws.ToString(cluster.RoleArn),
+		Version: aws.ToString(cluster.Version),
+	}
+
+	if cluster.CreatedAt != nil {
+		spec.CreatedAt = cluster.CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	if vpcConfig := cluster.ResourcesVpcConfig; vpcConfig != nil {
+		spec.VpcID = aws.ToString(vpcConfig.VpcId)
+		spec.Subnets = vpcConfig.SubnetIds
+	}
+
+	if cluster.Identity != nil && cluster.Identity.Oidc != nil {
+		spec.OIDCIssuer = aws.ToString(cluster.Identity.Oidc.Issuer)
+	}
+
+	for _, nodeGroup := range nodeGroups {
+		adopted := adoptedNodeGroup{
+			Name:          aws.ToString(nodeGroup.NodegroupName),
+			RoleARN:       aws.ToString(nodeGroup.NodeRole),
+			Subnets:       nodeGroup.Subnets,
+			InstanceTypes: nodeGroup.InstanceTypes,
+		}
+
+		if scaling := nodeGroup.ScalingConfig; scaling != nil {
+			adopted.MinSize = int(aws.ToInt32(scaling.MinSize))
+			adopted.MaxSize = int(aws.ToInt32(scaling.MaxSize))
+			adopted.DesiredSize = int(aws.ToInt32(scaling.DesiredSize))
+		}
+
+		spec.NodeGroups = append(spec.NodeGroups, adopted)
+	}
+
+	return spec, nil
+}
+
+// IAM OIDC provider of a cluster issuer (https://oidc.eks.<region>.amazonaws.com/id/<id>)
+func matchOIDCProvider(providerARNs []string, issuer string) string {
+	if issuer == "" {
+		return ""
+	}
+
+	provider := oidcProviderARN + strings.TrimPrefix(issuer, "https://")
+
+	for _, arn := range providerARNs {
+		if strings.HasSuffix(arn, provider) {
+			return arn
+		}
+	}
+
+	return ""
+}
+
+func (spec adoptedCluster) nodeCount() int {
+	var count int
+
+	for _, nodeGroup := range spec.NodeGroups {
+		count += nodeGroup.DesiredSize
+	}
+
+	return count
+}
+
+// discover an existing EKS cluster, its managed node groups, VPC and OIDC provider
+func discoverCluster(eksCluster string, region string) adoptedCluster {
+	var nodeGroups []*ekstypes.Nodegroup
+
+	fmt.Printf("\n . Discovering EKS cluster %s in %s\n", eksCluster, region)
+
+	sess := getSession()
+
+	inRegion := func(o *awseks.Options) {
+		o.Region = region
+	}
+
+	describeCtx, describeCancel := sess.requestContext()
+	defer describeCancel()
+
+	cluster, err := sess.eks().DescribeCluster(describeCtx, &awseks.DescribeClusterInput{Name: aws.String(eksCluster)}, inRegion)
+	if err != nil {
+		reportErr(err, "describe EKS cluster "+eksCluster)
+	}
+
+	paginator := awseks.NewListNodegroupsPaginator(sess.eks(), &awseks.ListNodegroupsInput{ClusterName: aws.String(eksCluster)})
+
+	for paginator.HasMorePages() {
+		ctx, cancel := sess.requestContext()
+		page, err := paginator.NextPage(ctx, inRegion)
+
+		cancel()
+
+		if err != nil {
+			reportErr(err, "list EKS node groups")
+		}
+
+		for _, name := range page.Nodegroups {
+			ctx, cancel := sess.requestContext()
+			nodeGroup, err := sess.eks().DescribeNodegroup(ctx, &awseks.DescribeNodegroupInput{
+				ClusterName:   aws.String(eksCluster),
+				NodegroupName: aws.String(name),
+			}, inRegion)
+
+			cancel()
+
+			if err != nil {
+				reportErr(err, "describe EKS node group "+name)
+			}
+
+			nodeGroups = append(nodeGroups, nodeGroup.Nodegroup)
+		}
+	}
+
+	spec, err := adoptedClusterSpec(cluster.Cluster, nodeGroups)
+	if err != nil {
+		reportErr(err, "adopt EKS cluster "+eksCluster)
+	}
+
+	ctx, cancel := sess.requestContext()
+	defer cancel()
+
+	vpcs, err := sess.ec2().DescribeVpcs(ctx, &awsec2.DescribeVpcsInput{VpcIds: []string{spec.VpcID}}, func(o *awsec2.Options) {
+		o.Region = region
+	})
+	if err == nil && len(vpcs.Vpcs) == 0 {
+		err = fmt.Errorf("VPC %s not found", spec.VpcID)
+	}
+
+	if err != nil {
+		reportErr(err, "describe cluster VPC "+spec.VpcID)
+	}
+
+	spec.VpcCIDR = aws.ToString(vpcs.Vpcs[0].CidrBlock)
+
+	providers, err := sess.iam().ListOpenIDConnectProviders(ctx, &awsiam.ListOpenIDConnectProvidersInput{})
+	if err != nil {
+		reportErr(err, "list IAM OIDC providers")
+	}
+
+	var providerARNs []string
+
+	for _, provider := range providers.OpenIDConnectProviderList {
+		providerARNs = append(providerARNs, aws.ToString(provider.Arn))
+	}
+
+	spec.OIDCProviderARN = matchOIDCProvider(providerARNs, spec.OIDCIssuer)
+
+	return spec
+}
+
+func printAdoptedCluster(spec adoptedCluster) {
+	fmt.Printf(" EKS cluster: %s (Kubernetes %s)\n", spec.ARN, spec.Version)
+	fmt.Printf(" VPC: %s (%s), retained when the cluster is deleted\n", spec.VpcID, spec.VpcCIDR)
+
+	if spec.OIDCProviderARN != "" {
+		fmt.Printf(" OIDC provider: %s\n", spec.OIDCProviderARN)
+	}
+
+	for _, nodeGroup := range spec.NodeGroups {
+		fmt.Printf(" Node group: %s %v (%d-%d nodes, %d desired)\n",
+			nodeGroup.Name, nodeGroup.InstanceTypes, nodeGroup.MinSize, nodeGroup.MaxSize, nodeGroup.DesiredSize)
+	}
+}
+
+// adopted cluster spec stored with a stack
+func storedAdoption(stackConfig map[string]string) (adoptedCluster, bool) {
+	var spec adoptedCluster
+
+	if stackConfig[adoptedKey] == "" {
+		return spec, false
+	}
+
+	if err := json.Unmarshal([]byte(stackConfig[adoptedKey]), &spec); err != nil {
+		reportErr(err, "read adopted cluster config")
+	}
+
+	return spec, true
+}
+
+// adopted cluster spec and Dispatch metadata of an adopted cluster
+func adoptConfig(event Event, spec adoptedCluster) map[string]string {
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		reportErr(err, "construct adopted cluster config")
+	}
+
+	created := spec.CreatedAt
+	if created == "" {
+		created = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	return map[string]string{
+		adoptedKey:       string(specJSON),
+		adoptedFromKey:   spec.ARN,
+		ownerConfigKey:   event.User,
+		createdConfigKey: created,
+		nodeCountKey:     fmt.Sprint(spec.nodeCount()),
+		versionKey:       spec.Version,
+	}
+}
+
+// pulumi program of an adopted cluster, resources are imported on adoption and node groups scale to zero while asleep
+func deployAdopted(ctx *pulumi.Context, event Event, spec adoptedCluster, asleep bool) error {
+	eksID := strings.ReplaceAll(event.Name, ".", "-")
+
+	importID := func(id string) pulumi.ResourceOption {
+		// resources are only imported once, later updates use the stack state
+		if event.Action != adoptAction {
+			return pulumi.Composite()
+		}
+
+		return pulumi.Import(pulumi.ID(id))
+	}
+
+	_, err := ec2.NewVpc(ctx, eksID+"-vpc", &ec2.VpcArgs{
+		CidrBlock: pulumi.String(spec.VpcCIDR),
+	}, importID(spec.VpcID), pulumi.RetainOnDelete(true), pulumi.IgnoreChanges(adoptedVPCIgnored))
+	if err != nil {
+		return fmt.Errorf("adopt VPC %s: %w", spec.VpcID, err)
+	}
+
+	cluster, err := eks.NewCluster(ctx, eksID, &eks.ClusterArgs{
+		Name:    pulumi.String(spec.Name),
+		RoleArn: pulumi.String(spec.RoleARN),
+		Version: pulumi.String(spec.Version),
+		VpcConfig: eks.ClusterVpcConfigArgs{
+			SubnetIds: pulumi.ToStringArray(spec.Subnets),
+		},
+	}, importID(spec.Name), pulumi.IgnoreChanges(adoptedClusterIgnored))
+	if err != nil {
+		return fmt.Errorf("adopt EKS cluster %s: %w", spec.Name, err)
+	}
+
+	for _, nodeGroup := range spec.NodeGroups {
+		minSize, desiredSize := nodeGroup.MinSize, nodeGroup.DesiredSize
+
+		if asleep {
+			minSize, desiredSize = 0, 0
+		}
+
+		_, err := eks.NewNodeGroup(ctx, eksID+"-"+nodeGroup.Name, &eks.NodeGroupArgs{
+			ClusterName:   cluster.Name,
+			NodeGroupName: pulumi.String(nodeGroup.Name),
+			NodeRoleArn:   pulumi.String(nodeGroup.RoleARN),
+			SubnetIds:     pulumi.ToStringArray(nodeGroup.Subnets),
+			ScalingConfig: eks.NodeGroupScalingConfigArgs{
+				MinSize:     pulumi.Int(minSize),
+				MaxSize:     pulumi.Int(nodeGroup.MaxSize),
+				DesiredSize: pulumi.Int(desiredSize),
+			},
+		}, importID(spec.Name+":"+nodeGroup.Name), pulumi.IgnoreChanges(adoptedNodeGroupIgnored))
+		if err != nil {
+			return fmt.Errorf("adopt EKS node group %s: %w", nodeGroup.Name, err)
+		}
+	}
+
+	if spec.OIDCProviderARN != "" {
+		_, err := iam.NewOpenIdConnectProvider(ctx, eksID+"-oidc", &iam.OpenIdConnectProviderArgs{
+			Url:             pulumi.String(spec.OIDCIssuer),
+			ClientIdLists:   pulumi.ToStringArray([]string{"sts.amazonaws.com"}),
+			ThumbprintLists: pulumi.ToStringArray([]string{}),
+		}, importID(spec.OIDCProviderARN), pulumi.IgnoreChanges(adoptedOIDCIgnored))
+		if err != nil {
+			return fmt.Errorf("adopt OIDC provider %s: %w", spec.OIDCProviderARN, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package dispatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+func TestAdoptedClusterSpec(t *testing.T) {
+	created := time.Date(2022, 6, 1, 10, 30, 0, 0, time.FixedZone("PDT", -7*60*60))
+
+	cluster := &ekstypes.Cluster{
+		Name:      aws.String("legacy"),
+		Arn:       aws.String("arn:aws:eks:us-west-2:123456789012:cluster/legacy"),
+		CreatedAt: &created,
+		Version:   aws.String("1.23"),
+		RoleArn:   aws.String("arn:aws:iam::123456789012:role/legacy-cluster"),
+		Status:    ekstypes.ClusterStatusActive,
+		ResourcesVpcConfig: &ekstypes.VpcConfigResponse{
+			VpcId:     aws.String("vpc-0abc"),
+			SubnetIds: []string{"subnet-a", "subnet-b"},
+		},
+		Identity: &ekstypes.Identity{Oidc: &ekstypes.OIDC{Issuer: aws.String("https://oidc.eks.us-west-2.amazonaws.com/id/ABC123")}},
+	}
+
+	nodeGroup := &ekstypes.Nodegroup{
+		NodegroupName: aws.String("workers"),
+		NodeRole:      aws.String("arn:aws:iam::123456789012:role/legacy-nodes"),
+		Subnets:       []string{"subnet-a"},
+		InstanceTypes: []string{"m5.large"},
+		ScalingConfig: &ekstypes.NodegroupScalingConfig{MinSize: aws.Int32(1), MaxSize: aws.Int32(4), DesiredSize: aws.Int32(3)},
+	}
+
+	want := adoptedCluster{
+		Name:       "legacy",
+		ARN:        "arn:aws:eks:us-west-2:123456789012:cluster/legacy",
+		RoleARN:    "arn:aws:iam::123456789012:role/legacy-cluster",
+		Version:    "1.23",
+		CreatedAt:  "2022-06-01T17:30:00Z",
+		VpcID:      "vpc-0abc",
+		Subnets:    []string{"subnet-a", "subnet-b"},
+		OIDCIssuer: "https://oidc.eks.us-west-2.amazonaws.com/id/ABC123",
+		NodeGroups: []adoptedNodeGroup{
+			{
+				Name:          "workers",
+				RoleARN:       "arn:aws:iam::123456789012:role/legacy-nodes",
+				Subnets:       []string{"subnet-a"},
+				InstanceTypes: []string{"m5.large"},
+				MinSize:       1,
+				MaxSize:       4,
+				DesiredSize:   3,
+			},
+		},
+	}
+
+	spec, err := adoptedClusterSpec(cluster, []*ekstypes.Nodegroup{nodeGroup})
+	if err != nil || !reflect.DeepEqual(spec, want) {
+		t.Errorf("adoptedClusterSpec unit test failure\n got: '%+v', want: '%+v', error: '%v'", spec, want, err)
+	}
+
+	if spec.nodeCount() != 3 {
+		t.Errorf("adoptedCluster nodeCount unit test failure\n got: '%d', want: '3'", spec.nodeCount())
+	}
+
+	cluster.Status = ekstypes.ClusterStatusCreating
+
+	if _, err := adoptedClusterSpec(cluster, nil); err == nil {
+		t.Error("adoptedClusterSpec unit test failure\n expected error for inactive cluster")
+	}
+}
+
+func TestMatchOIDCProvider(t *testing.T) {
+	providers := []string{
+		"arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/OTHER",
+		"arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-west-2.amazonaws.com/id/ABC123",
+	}
+
+	// input cluster OIDC issuer
+	// return matching IAM OIDC provider ARN
+	tests := []struct {
+		name   string
+		issuer string
+		want   string
+	}{
+		{
+			name:   "Match",
+			issuer: "https://oidc.eks.us-west-2.amazonaws.com/id/ABC123",
+			want:   providers[1],
+		},
+		{
+			name:   "No provider",
+			issuer: "https://oidc.eks.us-west-2.amazonaws.com/id/XYZ",
+		},
+		{
+			name: "No issuer",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := matchOIDCProvider(providers, test.issuer); got != test.want {
+				t.Errorf("matchOIDCProvider unit test failure\n got: '%v', want: '%v'", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStoredAdoption(t *testing.T) {
+	spec := adoptedCluster{Name: "legacy", VpcID: "vpc-0abc", NodeGroups: []adoptedNodeGroup{{Name: "workers", DesiredSize: 2}}}
+
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, adopted := storedAdoption(map[string]string{adoptedKey: string(specJSON)})
+	if !adopted || !reflect.DeepEqual(stored, spec) {
+		t.Errorf("storedAdoption unit test failure\n got: '%+v', want: '%+v'", stored, spec)
+	}
+
+	if _, adopted := storedAdoption(map[string]string{}); adopted {
+		t.Error("storedAdoption unit test failure\n native cluster reported as adopted")
+	}
+}
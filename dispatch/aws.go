@@ -3,19 +3,13 @@ package dispatch
 // AWS SDK utilities
 
 import (
-	"context"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
@@ -49,60 +43,38 @@ func setAWSRegion() string {
 	return region
 }
 
-// create and configure AWS SDK client
-func awsClientConfig() *aws.Config {
-	var cfg aws.Config
-
-	var err error
-
-	region := setAWSRegion()
-
-	_, envarCredsSet := os.LookupEnv("AWS_ACCESS_KEY_ID")
-
-	if !envarCredsSet {
-		profile, profileSet := os.LookupEnv("AWS_PROFILE")
-
-		if !profileSet {
-			profile = "default"
-		}
-
-		cfg, err = config.
-			LoadDefaultConfig(
-				context.TODO(), config.WithRegion(region), config.WithSharedConfigProfile(profile),
-			)
-
-		if err != nil {
-			fmt.Println(" ! Failed to find AWS credentials in env vars or credentials file")
-		}
-	} else {
-		cfg, err = config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+// region of a cluster, existing stacks keep the region stored in their config
+func clusterRegion(event Event, summary stackSummary) string {
+	if region := summary.Config[regionConfigKey]; region != "" {
+		return region
+	}
 
-		if err != nil {
-			reportErr(err, "to find AWS credentials")
-		}
+	if event.Region != "" {
+		return event.Region
 	}
 
-	return &cfg
+	return setAWSRegion()
 }
 
-// list account IAM users
-func testIAM(clientConfig aws.Config) {
-	maxCount := 500
-	iamClient := iam.NewFromConfig(clientConfig)
-
-	input := &iam.ListUsersInput{MaxItems: aws.Int32(int32(maxCount))}
+// validate credentials with the caller's STS identity
+func getCallerIdentity(sess *awsSession) *sts.GetCallerIdentityOutput {
+	ctx, cancel := sess.requestContext()
+	defer cancel()
 
-	_, err := iamClient.ListUsers(context.TODO(), input)
+	identity, err := sess.sts().GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
 	if err != nil {
 		reportErr(err, "authenticate with AWS API")
 	}
+
+	return identity
 }
 
 // list account S3 buckets
-func getS3Buckets(clientConfig aws.Config) *s3.ListBucketsOutput {
-	s3Client := s3.NewFromConfig(clientConfig)
+func getS3Buckets(sess *awsSession) *s3.ListBucketsOutput {
+	ctx, cancel := sess.requestContext()
+	defer cancel()
 
-	buckets, err := s3Client.ListBuckets(context.TODO(), nil)
+	buckets, err := sess.s3().ListBuckets(ctx, nil)
 	if err != nil {
 		reportErr(err, "list S3 buckets")
 	}
@@ -114,17 +86,19 @@ func getS3Buckets(clientConfig aws.Config) *s3.ListBucketsOutput {
 func getAvailabilityZones() string {
 	var azs string
 
-	clientConfig := awsClientConfig()
-	ec2Client := ec2.NewFromConfig(*clientConfig)
+	sess := getSession()
+
+	ctx, cancel := sess.requestContext()
+	defer cancel()
 
-	regionValue := []string{clientConfig.Region}
+	regionValue := []string{sess.config.Region}
 	location := &ec2types.Filter{Name: aws.String("region-name"), Values: regionValue}
 	settingFilter := []ec2types.Filter{*location}
 	describeSettings := &ec2.DescribeAvailabilityZonesInput{Filters: settingFilter}
 
-	resp, err := ec2Client.DescribeAvailabilityZones(context.TODO(), describeSettings)
+	resp, err := sess.ec2().DescribeAvailabilityZones(ctx, describeSettings)
 	if err != nil {
-		reportErr(err, "describe "+clientConfig.Region+" availability zones")
+		reportErr(err, "describe "+sess.config.Region+" availability zones")
 	}
 
 	for i := range resp.AvailabilityZones {
@@ -139,23 +113,15 @@ func getAvailabilityZones() string {
 }
 
 func getAccountNumber() string {
-	clientConfig := awsClientConfig()
-
-	input := &sts.GetCallerIdentityInput{}
-
-	stsClient := sts.NewFromConfig(*clientConfig)
-
-	response, err := stsClient.GetCallerIdentity(context.TODO(), input)
-	if err != nil {
-		reportErr(err, "get caller identity")
-	}
-
-	return *response.Account
+	return *getCallerIdentity(getSession()).Account
 }
 
 // create S3 bucket for provisioning state
-func createStateBucket(clientConfig aws.Config, bucketName string) {
-	s3Client := s3.NewFromConfig(clientConfig)
+func createStateBucket(sess *awsSession, bucketName string) {
+	s3Client := sess.s3()
+
+	ctx, cancel := sess.requestContext()
+	defer cancel()
 
 	// create private bucket
 	createSettings := &s3.CreateBucketInput{
@@ -163,15 +129,15 @@ func createStateBucket(clientConfig aws.Config, bucketName string) {
 		ACL:    "private",
 	}
 
-	if clientConfig.Region != defaultRegion {
+	if sess.config.Region != defaultRegion {
 		locationConfig := &s3types.
 			CreateBucketConfiguration{
-			LocationConstraint: s3types.BucketLocationConstraint(clientConfig.Region),
+			LocationConstraint: s3types.BucketLocationConstraint(sess.config.Region),
 		}
 		createSettings.CreateBucketConfiguration = locationConfig
 	}
 
-	_, err := s3Client.CreateBucket(context.TODO(), createSettings)
+	_, err := s3Client.CreateBucket(ctx, createSettings)
 	if err != nil {
 		reportErr(err, "create KOPS S3 bucket")
 	}
@@ -186,7 +152,7 @@ func createStateBucket(clientConfig aws.Config, bucketName string) {
 		ServerSideEncryptionConfiguration: serverConfig,
 	}
 
-	_, err = s3Client.PutBucketEncryption(context.TODO(), encryptionSettings)
+	_, err = s3Client.PutBucketEncryption(ctx, encryptionSettings)
 	if err != nil {
 		reportErr(err, "encrypt KOPS S3 bucket")
 	}
@@ -198,26 +164,33 @@ func createStateBucket(clientConfig aws.Config, bucketName string) {
 		VersioningConfiguration: versionConfig,
 	}
 
-	_, err = s3Client.PutBucketVersioning(context.TODO(), versionSettings)
+	_, err = s3Client.PutBucketVersioning(ctx, versionSettings)
 	if err != nil {
 		reportErr(err, "version KOPS S3 bucket")
 	}
 }
 
-func testAWSCreds(clientConfig aws.Config) {
-	testIAM(clientConfig)
+// state store bucket of a user in an AWS account
+func stateBucketName(user string, account string) string {
+	return user + "-dispatch-state-store-" + account
+}
+
+func testAWSCreds(sess *awsSession, user string) {
+	identity := getCallerIdentity(sess)
+
+	fmt.Printf(" . Valid AWS credentials have been provided for region %s\n", sess.config.Region)
 
-	fmt.Printf(" . Valid AWS credentials have been provided for region %s\n", clientConfig.Region)
+	printPermissionGaps(simulateDispatchPermissions(sess, *identity.Arn, stateBucketName(user, *identity.Account)))
 }
 
-func ensureS3Bucket(clientConfig aws.Config, event Event) string {
+func ensureS3Bucket(sess *awsSession, event Event) string {
 	var bucketExists bool
 
 	accountNumber := getAccountNumber()
 
-	kopsBucket := event.User + "-dispatch-state-store-" + accountNumber
+	kopsBucket := stateBucketName(event.User, accountNumber)
 
-	buckets := getS3Buckets(clientConfig)
+	buckets := getS3Buckets(sess)
 
 	for i := range buckets.Buckets {
 		if *buckets.Buckets[i].Name == kopsBucket {
@@ -239,7 +212,7 @@ func ensureS3Bucket(clientConfig aws.Config, event Event) string {
 		}
 
 		if createBucket == "y" || createBucket == "Y" || event.Verified {
-			createStateBucket(clientConfig, kopsBucket)
+			createStateBucket(sess, kopsBucket)
 		} else {
 			fmt.Print("\n S3 bucket is required for cluster provisioning, exiting.\n\n")
 			os.Exit(0)
@@ -252,93 +225,53 @@ func ensureS3Bucket(clientConfig aws.Config, event Event) string {
 func listExistingClusters(bucket string) []string {
 	var clusters []string
 
-	clientConfig := awsClientConfig()
-
-	s3Client := s3.NewFromConfig(*clientConfig)
-
-	listConfig := &s3.ListObjectsV2Input{
-		Bucket: &bucket,
-		Prefix: aws.String(pulumiStacksPath),
-	}
-
-	objects, err := s3Client.ListObjectsV2(context.TODO(), listConfig)
-	if err != nil {
-		reportErr(err, "list S3 items in KOPS state store")
-	}
-
-	if len(objects.Contents) > 0 {
-		for _, item := range objects.Contents {
-			if !strings.Contains(*item.Key, ".bak") {
-				clusters = append(clusters, *item.Key)
-			}
-		}
+	for _, stack := range listStackObjects(bucket) {
+		clusters = append(clusters, stack.Key)
 	}
 
 	return clusters
 }
 
-func printExistingClusters(bucket string) {
-	clusters := listExistingClusters(bucket)
-
-	if len(clusters) > 0 {
-		fmt.Print(" - Existing stack configurations:\n")
-
-		for _, item := range clusters {
-			fmt.Printf("\t <> %s \n", item)
-		}
-	} else {
-		fmt.Print(" . No existing clusters found\n")
+func setEKSConfig(clusterID string, name string, region string) string {
+	kubeconfigPath, output, err := updateKubeconfig(clusterID, name, region)
+	if err != nil {
+		reportErr(err, "update kubeconfig")
 	}
-}
 
-func getObjectMetadata(bucket string, cluster string) (*s3.HeadObjectOutput, error) {
-	clientConfig := awsClientConfig()
-	s3Client := s3.NewFromConfig(*clientConfig)
+	fmt.Println(output)
 
-	input := &s3.HeadObjectInput{
-		Bucket: &bucket,
-		Key:    aws.String(cluster),
+	if len(sessionCredentials.roleChain) > 0 && kubeconfigRoleARN(sessionCredentials) == "" {
+		fmt.Print(" ! kubectl can't assume roles requiring an external ID or MFA, it uses the AWS credentials of your shell\n")
+		fmt.Print(" ! Assume the role in your shell (e.g. with an AWS credentials file profile) before running kubectl\n")
 	}
 
-	return s3Client.HeadObject(context.TODO(), input)
+	return kubeconfigPath
 }
 
-func setEKSConfig(clusterID string, name string) string {
+// add an EKS cluster to the Dispatch kubeconfig as a context named after the cluster
+func updateKubeconfig(clusterID string, name string, region string) (string, string, error) {
 	home, homeSet := os.LookupEnv("HOME")
 	if !homeSet {
 		fmt.Println("$HOME not set")
 	}
 
-	kubeconfigPath := filepath.Join(home, ".dispatch", ".kube", "config")
+	kubeconfigPath := dispatchKubeconfig(home)
 	os.Setenv("KUBECONFIG", kubeconfigPath)
 
-	region := setAWSRegion()
-
-	cmd := exec.Command(
-		"aws", "eks", "--region", region,
+	kubeconfigArgs := []string{
+		"eks", "--region", region,
 		"update-kubeconfig", "--name", clusterID,
 		"--alias", name,
-	)
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		reportErr(err, "display aws eks cmd stdout")
 	}
 
-	if err := cmd.Start(); err != nil {
-		reportErr(err, "start aws eks update")
+	if roleARN := kubeconfigRoleARN(sessionCredentials); roleARN != "" {
+		kubeconfigArgs = append(kubeconfigArgs, "--role-arn", roleARN)
 	}
 
-	data, err := io.ReadAll(stdout)
+	data, err := awsCLI(kubeconfigArgs...)
 	if err != nil {
-		reportErr(err, "read aws eks stdout")
+		return kubeconfigPath, "", err
 	}
 
-	if err := cmd.Wait(); err != nil {
-		reportErr(err, "update kubeconfig")
-	}
-
-	fmt.Println(string(data))
-
-	return kubeconfigPath
+	return kubeconfigPath, string(data), nil
 }
@@ -83,3 +83,58 @@ func TestSetAWSRegion(t *testing.T) {
 		})
 	}
 }
+
+func TestClusterRegion(t *testing.T) {
+	// return stored, requested or session region
+	west := stackSummary{Name: "west", Config: map[string]string{regionConfigKey: "us-west-2"}}
+	old := stackSummary{Name: "old", Config: map[string]string{}}
+
+	os.Setenv("AWS_REGION", "eu-west-1")
+	defer os.Unsetenv("AWS_REGION")
+
+	tests := []struct {
+		expectedReturn string
+		name           string
+		input          Event
+		summary        stackSummary
+	}{
+		{
+			name:           "Stored region",
+			input:          Event{Name: "west"},
+			summary:        west,
+			expectedReturn: "us-west-2",
+		},
+		{
+			name:           "Stored region ignores flag",
+			input:          Event{Name: "west", Region: "ca-central-1"},
+			summary:        west,
+			expectedReturn: "us-west-2",
+		},
+		{
+			name:           "Region flag",
+			input:          Event{Name: "new", Region: "ca-central-1"},
+			expectedReturn: "ca-central-1",
+		},
+		{
+			name:           "Region flag without stored region",
+			input:          Event{Name: "old", Region: "ca-central-1"},
+			summary:        old,
+			expectedReturn: "ca-central-1",
+		},
+		{
+			name:           "Environment region",
+			input:          Event{Name: "old"},
+			summary:        old,
+			expectedReturn: "eu-west-1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			region := clusterRegion(test.input, test.summary)
+
+			if region != test.expectedReturn {
+				t.Errorf("clusterRegion unit test failure\n got: '%v', want: '%v'", region, test.expectedReturn)
+			}
+		})
+	}
+}
@@ -0,0 +1,385 @@
+package dispatch
+
+// concurrent cluster operations on clusters selected by name pattern, label selector or TUI multi-select
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBulkWorkers int    = 4
+	kubeconfigEnv      string = "DISPATCH_KUBECONFIG"
+	// set for bulk subprocesses, which reuse the dependencies, permission checks and credentials of the parent
+	bulkChildEnv string = "DISPATCH_BULK_CHILD"
+)
+
+// progress of running operations is printed at this interval, a variable so tests can shorten it
+var bulkProgressInterval = 30 * time.Second
+
+type bulkResult struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// cluster name patterns contain glob metacharacters
+func namePattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+func bulkEvent(event Event) bool {
+	return len(event.Names) > 0 || event.Selector != "" || namePattern(event.Name)
+}
+
+// labels of a cluster matched by -selector
+func clusterLabels(summary stackSummary) map[string]string {
+	labels := map[string]string{
+		"name":      summary.Name,
+		"owner":     clusterOwner(summary),
+		"region":    summaryRegion(summary),
+		"size":      summary.Config[nodeSizeKey],
+		"version":   summary.Config[versionKey],
+		"asleep":    strconv.FormatBool(clusterAsleep(summary)),
+		"protected": strconv.FormatBool(clusterProtected(summary)),
+	}
+
+	for key, value := range clusterTags(summary) {
+		labels[tagLabelPrefix+key] = value
+	}
+
+	return labels
+}
+
+// parse a comma separated list of key=value cluster label selectors
+func parseSelector(selector string) (map[string]string, error) {
+	labels := map[string]string{}
+
+	if selector == "" {
+		return labels, nil
+	}
+
+	known := clusterLabels(stackSummary{})
+
+	for _, term := range strings.Split(selector, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(term), "=")
+		if !found || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid selector %s, selectors are key=value pairs (e.g. owner=alice,region=us-east-1)", term)
+		}
+
+		if _, valid := known[key]; !valid && !strings.HasPrefix(key, tagLabelPrefix) {
+			var keys []string
+
+			for label := range known {
+				keys = append(keys, label)
+			}
+
+			sort.Strings(keys)
+
+			return nil, fmt.Errorf("unknown selector label %s, use one of %s or tag:<key>", key, strings.Join(keys, ", "))
+		}
+
+		labels[key] = value
+	}
+
+	return labels, nil
+}
+
+// clusters sorted by name matching a name glob pattern and every selector label, selector values may also be patterns
+func selectClusters(summaries map[string]stackSummary, pattern string, selector map[string]string) ([]stackSummary, error) {
+	var selected []stackSummary
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid cluster name pattern %s: %w", pattern, err)
+	}
+
+	for _, summary := range filterClusters(summaries, "", true) {
+		if matched, _ := path.Match(pattern, summary.Name); pattern != "" && !matched {
+			continue
+		}
+
+		labels := clusterLabels(summary)
+		matched := true
+
+		for key, value := range selector {
+			if ok, err := path.Match(value, labels[key]); err != nil || !ok {
+				matched = false
+			}
+		}
+
+		if matched {
+			selected = append(selected, summary)
+		}
+	}
+
+	return selected, nil
+}
+
+// run an operation for each cluster with a bounded number of workers, results are in completion order
+func runBulk(action string, names []string, workers int, run func(string) error) []bulkResult {
+	var results []bulkResult
+
+	var mutex sync.Mutex
+
+	var wg sync.WaitGroup
+
+	running := map[string]time.Time{}
+	queue := make(chan string)
+	done := make(chan struct{})
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for name := range queue {
+				start := time.Now()
+
+				mutex.Lock()
+				running[name] = start
+				fmt.Printf(" . %s %s started\n", action, name)
+				mutex.Unlock()
+
+				err := run(name)
+
+				mutex.Lock()
+				delete(running, name)
+
+				result := bulkResult{Name: name, Err: err, Duration: time.Since(start).Round(time.Second)}
+				results = append(results, result)
+
+				if err != nil {
+					fmt.Printf(" ! [%d/%d] %s %s failed after %s: %v\n", len(results), len(names), action, name, result.Duration, err)
+				} else {
+					fmt.Printf(" - [%d/%d] %s %s finished in %s\n", len(results), len(names), action, name, result.Duration)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	// aggregated progress of long running stack operations
+	go func() {
+		ticker := time.NewTicker(bulkProgressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mutex.Lock()
+
+				var active []string
+
+				for name, start := range running {
+					active = append(active, fmt.Sprintf("%s (%s)", name, time.Since(start).Round(time.Second)))
+				}
+
+				sort.Strings(active)
+				fmt.Printf(" . %d of %d finished, running: %s\n", len(results), len(names), strings.Join(active, ", "))
+
+				mutex.Unlock()
+			}
+		}
+	}()
+
+	for _, name := range names {
+		queue <- name
+	}
+
+	close(queue)
+	wg.Wait()
+	close(done)
+
+	return results
+}
+
+// per-cluster file under the Dispatch workspace, e.g. the log of a bulk operation
+func bulkPath(dir string, name string) string {
+	home, homeSet := os.LookupEnv("HOME")
+	if !homeSet {
+		return ""
+	}
+
+	return filepath.Join(home, ".dispatch", dir, name)
+}
+
+// running as a bulk operation subprocess
+func bulkChild() bool {
+	return os.Getenv(bulkChildEnv) != ""
+}
+
+// subprocess arguments of a single cluster operation, the region and role flags match the parent session
+func bulkCommandArgs(event Event, name string) []string {
+	args := []string{event.Action, "-name", name, "-yes"}
+
+	flags := []struct{ name, value string }{
+		{"-region", event.Region},
+		{"-role-arn", event.RoleARN},
+		{"-external-id", event.ExternalID},
+		{"-session-name", event.SessionName},
+		{"-mfa-serial", event.MFASerial},
+	}
+
+	for _, option := range flags {
+		if option.value != "" {
+			args = append(args, option.name, option.value)
+		}
+	}
+
+	if event.Action == deleteAction && event.SkipCleanup {
+		args = append(args, "-skip-k8s-cleanup")
+	}
+
+	if event.Action == sleepAction && event.SleepNAT {
+		args = append(args, "-nat")
+	}
+
+	return args
+}
+
+// run a single cluster operation as a Dispatch subprocess, Exec exits on failure and sets process-wide env vars
+// subprocesses inherit the resolved AWS credentials and use their own kubeconfig and log file
+func bulkClusterCommand(event Event, name string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := bulkCommandArgs(event, name)
+
+	logPath := bulkPath("logs", name+"-"+event.Action+".log")
+	ensureDir(filepath.Dir(logPath))
+
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.Env = append(os.Environ(), kubeconfigEnv+"="+bulkPath(".kube", name+"-config"), bulkChildEnv+"=1")
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w, see %s", err, logPath)
+	}
+
+	return nil
+}
+
+func printBulkResults(action string, results []bulkResult) int {
+	failed := 0
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	fmt.Printf("\n - %s results:\n", action)
+
+	for _, result := range results {
+		status := "ok"
+
+		if result.Err != nil {
+			status = "failed: " + result.Err.Error()
+			failed++
+		}
+
+		fmt.Printf("\t <> %-24s %8s  %s\n", result.Name, result.Duration, status)
+	}
+
+	return failed
+}
+
+// clusters of a bulk event, protected clusters are never deleted
+func bulkClusters(event Event, summaries map[string]stackSummary) []stackSummary {
+	var selected []stackSummary
+
+	if len(event.Names) > 0 {
+		for _, name := range event.Names {
+			if summary, found := summaries[name]; found {
+				selected = append(selected, summary)
+			}
+		}
+	} else {
+		selector, err := parseSelector(event.Selector)
+		if err != nil {
+			reportErr(err, "parse cluster selector")
+		}
+
+		if selected, err = selectClusters(summaries, event.Name, selector); err != nil {
+			reportErr(err, "select clusters")
+		}
+	}
+
+	if event.Action != deleteAction {
+		return selected
+	}
+
+	selected, protected := partitionProtected(selected)
+
+	for _, summary := range protected {
+		fmt.Printf(" ! %s is protected from deletion, skipping\n", summary.Name)
+	}
+
+	return selected
+}
+
+// run an operation on every selected cluster in parallel
+func bulkExec(event Event) {
+	var names, details []string
+
+	now := time.Now()
+
+	for _, summary := range bulkClusters(event, getStackSummaries(event.Bucket)) {
+		names = append(names, summary.Name)
+		details = append(details, fmt.Sprintf("%-24s %-16s owner %s, age %s", summary.Name, summaryRegion(summary), clusterOwner(summary), clusterAge(summary, now)))
+	}
+
+	if len(names) == 0 {
+		fmt.Print("\n . No clusters match the selection\n")
+
+		return
+	}
+
+	if !event.Verified {
+		count := strconv.Itoa(len(names))
+
+		if !confirmTyped(event.TUI, fmt.Sprintf("%s %s clusters", event.Action, count), details, count) {
+			fmt.Printf("\n . No clusters were changed, the typed count did not match\n\n")
+			os.Exit(0)
+		}
+	}
+
+	workers := event.Parallel
+	if workers == 0 {
+		workers = defaultBulkWorkers
+	}
+
+	fmt.Printf("\n . Running %s on %d clusters, %d at a time, logs are written to %s\n\n", event.Action, len(names), workers, bulkPath("logs", ""))
+
+	// subprocesses receive Ctrl-C directly and cancel their own operations
+	getSession().releaseInterrupts()
+
+	results := runBulk(event.Action, names, workers, func(name string) error {
+		return bulkClusterCommand(event, name)
+	})
+
+	if failed := printBulkResults(event.Action, results); failed > 0 {
+		fmt.Printf("\n ! %d of %d clusters failed to %s\n\n", failed, len(results), event.Action)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,202 @@
+package dispatch
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     map[string]string
+		err      bool
+	}{
+		{name: "Empty", selector: "", want: map[string]string{}},
+		{name: "Single", selector: "owner=alice", want: map[string]string{"owner": "alice"}},
+		{name: "Multiple", selector: "owner=alice, region=us-east-1", want: map[string]string{"owner": "alice", "region": "us-east-1"}},
+		{name: "Missing value", selector: "owner=", err: true},
+		{name: "Not a pair", selector: "alice", err: true},
+		{name: "Unknown label", selector: "team=infra", err: true},
+		{name: "Tag", selector: "tag:team=infra", want: map[string]string{"tag:team": "infra"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseSelector(test.selector)
+			if (err != nil) != test.err {
+				t.Fatalf("parseSelector unit test failure\n error: '%v'", err)
+			}
+
+			if !test.err && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseSelector unit test failure\n got: '%v', want: '%v'", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSelectClusters(t *testing.T) {
+	summaries := map[string]stackSummary{
+		"pr-101":      {Name: "pr-101", Config: map[string]string{ownerConfigKey: "alice", regionConfigKey: "us-east-1"}},
+		"pr-102":      {Name: "pr-102", Config: map[string]string{ownerConfigKey: "bob", regionConfigKey: "us-east-1"}},
+		"pr-103":      {Name: "pr-103", Config: map[string]string{ownerConfigKey: "alice", regionConfigKey: "us-west-2", defaultTagsKey: `{"tags":{"team":"search"}}`}},
+		"shared-demo": {Name: "shared-demo", Config: map[string]string{ownerConfigKey: "alice", regionConfigKey: "us-east-1"}},
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		selector map[string]string
+		want     []string
+		err      bool
+	}{
+		{name: "Pattern", pattern: "pr-*", want: []string{"pr-101", "pr-102", "pr-103"}},
+		{name: "Selector", selector: map[string]string{"owner": "alice"}, want: []string{"pr-101", "pr-103", "shared-demo"}},
+		{name: "Pattern and selector", pattern: "pr-*", selector: map[string]string{"owner": "alice", "region": "us-east-1"}, want: []string{"pr-101"}},
+		{name: "Selector pattern", selector: map[string]string{"region": "us-*"}, want: []string{"pr-101", "pr-102", "pr-103", "shared-demo"}},
+		{name: "Tag", selector: map[string]string{"tag:team": "search"}, want: []string{"pr-103"}},
+		{name: "No match", pattern: "feature-*"},
+		{name: "Invalid pattern", pattern: "pr-[", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+
+			selected, err := selectClusters(summaries, test.pattern, test.selector)
+			if (err != nil) != test.err {
+				t.Fatalf("selectClusters unit test failure\n error: '%v'", err)
+			}
+
+			for _, summary := range selected {
+				got = append(got, summary.Name)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("selectClusters unit test failure\n got: '%v', want: '%v'", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRunBulk(t *testing.T) {
+	interval := bulkProgressInterval
+	bulkProgressInterval = time.Millisecond
+
+	t.Cleanup(func() {
+		bulkProgressInterval = interval
+	})
+
+	var mutex sync.Mutex
+
+	active, peak := 0, 0
+	names := []string{"pr-1", "pr-2", "pr-3", "pr-4", "pr-5"}
+
+	results := runBulk(deleteAction, names, 2, func(name string) error {
+		mutex.Lock()
+		active++
+
+		if active > peak {
+			peak = active
+		}
+		mutex.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+
+		if name == "pr-3" {
+			return fmt.Errorf("destroy failed")
+		}
+
+		return nil
+	})
+
+	if peak > 2 {
+		t.Errorf("runBulk unit test failure\n got: '%d' concurrent operations, want: '2'", peak)
+	}
+
+	var completed, failed []string
+
+	for _, result := range results {
+		completed = append(completed, result.Name)
+
+		if result.Err != nil {
+			failed = append(failed, result.Name)
+		}
+	}
+
+	sort.Strings(completed)
+
+	if !reflect.DeepEqual(completed, names) || !reflect.DeepEqual(failed, []string{"pr-3"}) {
+		t.Errorf("runBulk unit test failure\n got: '%v' failed: '%v', want: '%v' failed: '[pr-3]'", completed, failed, names)
+	}
+}
+
+func TestBulkClusters(t *testing.T) {
+	summaries := map[string]stackSummary{
+		"pr-1":        {Name: "pr-1", Config: map[string]string{}},
+		"shared-demo": {Name: "shared-demo", Config: map[string]string{protectedKey: "true"}},
+	}
+
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{name: "Protected clusters are not deleted", event: Event{Action: deleteAction, Name: "*"}, want: []string{"pr-1"}},
+		{name: "Protected clusters can sleep", event: Event{Action: sleepAction, Name: "*"}, want: []string{"pr-1", "shared-demo"}},
+		{name: "TUI selection", event: Event{Action: deleteAction, Names: []string{"pr-1", "missing"}}, want: []string{"pr-1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+
+			for _, summary := range bulkClusters(test.event, summaries) {
+				got = append(got, summary.Name)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("bulkClusters unit test failure\n got: '%v', want: '%v'", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBulkCommandArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{
+			name:  "Delete",
+			event: Event{Action: deleteAction, SkipCleanup: true},
+			want:  []string{deleteAction, "-name", "pr-1", "-yes", "-skip-k8s-cleanup"},
+		},
+		{
+			name:  "Region and assumed role",
+			event: Event{Action: sleepAction, Region: "eu-west-1", RoleARN: "arn:aws:iam::222222222222:role/deploy", ExternalID: "my-external-id", SleepNAT: true},
+			want: []string{
+				sleepAction, "-name", "pr-1", "-yes", "-region", "eu-west-1",
+				"-role-arn", "arn:aws:iam::222222222222:role/deploy", "-external-id", "my-external-id", "-nat",
+			},
+		},
+		{
+			name:  "Session name and MFA",
+			event: Event{Action: wakeAction, SessionName: "ci", MFASerial: "arn:aws:iam::111111111111:mfa/alice"},
+			want:  []string{wakeAction, "-name", "pr-1", "-yes", "-session-name", "ci", "-mfa-serial", "arn:aws:iam::111111111111:mfa/alice"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := bulkCommandArgs(test.event, "pr-1"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("bulkCommandArgs unit test failure\n got: '%v', want: '%v'", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package dispatch
+
+// cert-manager IRSA role for ACME DNS01 challenges, optional and scoped to a Route53 hosted zone
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsroute53 "github.com/aws/aws-sdk-go-v2/service/route53"
+	r53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+const (
+	certManagerKey      string = "dispatch:certManager"
+	dnsZoneKey          string = "dispatch:dnsZone"
+	dnsZoneIDKey        string = "dispatch:dnsZoneId"
+	certManagerDisabled string = "disabled"
+	allHostedZones      string = "*"
+)
+
+// cert-manager settings stored with the stack config, clones copy them
+var certManagerKeys = []string{certManagerKey, dnsZoneKey, dnsZoneIDKey}
+
+var dnsZoneName = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]*[a-z0-9])?\.)+[a-z][-a-z0-9]*[a-z0-9]\.?$`)
+
+// cert-manager options of an IRSA spec file, applied by create
+type certManagerSpec struct {
+	Disabled bool   `yaml:"disabled,omitempty"`
+	DNSZone  string `yaml:"dns_zone,omitempty"`
+}
+
+type certManagerSettings struct {
+	Enabled bool
+	Zone    string
+	ZoneID  string
+}
+
+func validateCertManager(event Event) error {
+	if event.NoCertManager && event.DNSZone != "" {
+		return fmt.Errorf("-dns-zone scopes the cert-manager role, it cannot be combined with -no-cert-manager")
+	}
+
+	if event.DNSZone != "" && !dnsZoneName.MatchString(strings.ToLower(event.DNSZone)) {
+		return fmt.Errorf("invalid DNS zone %s (e.g. example.com)", event.DNSZone)
+	}
+
+	return nil
+}
+
+// ID of the public hosted zone of a DNS zone, ACME DNS01 challenges are solved in public zones
+func publicHostedZoneID(zones []r53types.HostedZone, zone string) (string, error) {
+	var ids []string
+
+	name := strings.TrimSuffix(strings.ToLower(zone), ".") + "."
+
+	for _, hostedZone := range zones {
+		if hostedZone.Config != nil && hostedZone.Config.PrivateZone {
+			continue
+		}
+
+		if strings.ToLower(aws.ToString(hostedZone.Name)) == name {
+			ids = append(ids, strings.TrimPrefix(aws.ToString(hostedZone.Id), "/hostedzone/"))
+		}
+	}
+
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("no public Route53 hosted zone %s found", zone)
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("%d public Route53 hosted zones named %s found (%s)", len(ids), zone, strings.Join(ids, ", "))
+	}
+}
+
+// hosted zones are listed in name order starting with the zone
+func lookupHostedZoneID(zone string) (string, error) {
+	sess := getSession()
+
+	ctx, cancel := sess.requestContext()
+	defer cancel()
+
+	zones, err := sess.route53().ListHostedZonesByName(ctx, &awsroute53.ListHostedZonesByNameInput{DNSName: aws.String(zone)})
+	if err != nil {
+		return "", err
+	}
+
+	return publicHostedZoneID(zones.HostedZones, zone)
+}
+
+// clusters created before the role was optional keep a role for every hosted zone
+func storedCertManager(stackConfig map[string]string) certManagerSettings {
+	return certManagerSettings{
+		Enabled: stackConfig[certManagerKey] != certManagerDisabled,
+		Zone:    stackConfig[dnsZoneKey],
+		ZoneID:  stackConfig[dnsZoneIDKey],
+	}
+}
+
+// cert-manager config of a new cluster, clones keep the source cluster's settings unless overridden
+func createCertManagerConfig(event Event, source stackSummary) map[string]string {
+	values := map[string]string{}
+
+	for _, key := range certManagerKeys {
+		if value := source.Config[key]; value != "" {
+			values[key] = value
+		}
+	}
+
+	if event.NoCertManager {
+		values = map[string]string{certManagerKey: certManagerDisabled}
+	}
+
+	if event.DNSZone != "" {
+		zoneID, err := lookupHostedZoneID(event.DNSZone)
+		if err != nil {
+			reportErr(err, "look up Route53 hosted zone "+event.DNSZone)
+		}
+
+		values = map[string]string{dnsZoneKey: strings.ToLower(event.DNSZone), dnsZoneIDKey: zoneID}
+	}
+
+	return values
+}
+
+// ACME DNS01 inline policy of the cert-manager role, record changes are limited to the hosted zone when one is set
+func certManagerPolicy(zoneID string) (string, error) {
+	zone := allHostedZones
+	if zoneID != "" {
+		zone = zoneID
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": iamPolicyVersion,
+		"Statement": []map[string]interface{}{
+			{
+				"Effect": "Allow",
+				"Action": []string{
+					"route53:GetChange",
+				},
+				"Resource": "arn:aws:route53:::change/*",
+			},
+			{
+				"Effect": "Allow",
+				"Action": []string{
+					"route53:ChangeResourceRecordSets",
+					"route53:ListResourceRecordSets",
+				},
+				"Resource": "arn:aws:route53:::hostedzone/" + zone,
+			},
+			{
+				"Effect": "Allow",
+				"Action": []string{
+					"route53:ListHostedZonesByName",
+				},
+				"Resource": "*",
+			},
+		},
+	})
+
+	return string(policy), err
+}
+
+func printCertManager(settings certManagerSettings) {
+	switch {
+	case !settings.Enabled:
+		fmt.Print(" cert-manager role: disabled\n")
+	case settings.Zone != "":
+		fmt.Printf(" cert-manager role: DNS01 records in %s (%s)\n", settings.Zone, settings.ZoneID)
+	default:
+		fmt.Print(" cert-manager role: DNS01 records in every hosted zone\n")
+	}
+}
@@ -0,0 +1,101 @@
+package dispatch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	r53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestValidateCertManager(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		err   bool
+	}{
+		{name: "Default", event: Event{}},
+		{name: "Disabled", event: Event{NoCertManager: true}},
+		{name: "DNS zone", event: Event{DNSZone: "dev.example.com"}},
+		{name: "Invalid DNS zone", event: Event{DNSZone: "example"}, err: true},
+		{name: "Disabled with DNS zone", event: Event{NoCertManager: true, DNSZone: "example.com"}, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateCertManager(test.event); (err != nil) != test.err {
+				t.Errorf("validateCertManager unit test failure\n got: '%v', want error: '%v'", err, test.err)
+			}
+		})
+	}
+}
+
+func TestPublicHostedZoneID(t *testing.T) {
+	zones := []r53types.HostedZone{
+		{Id: aws.String("/hostedzone/Z0PRIVATE"), Name: aws.String("example.com."), Config: &r53types.HostedZoneConfig{PrivateZone: true}},
+		{Id: aws.String("/hostedzone/Z0PUBLIC"), Name: aws.String("example.com."), Config: &r53types.HostedZoneConfig{PrivateZone: false}},
+		{Id: aws.String("/hostedzone/Z0OTHER"), Name: aws.String("example.net.")},
+	}
+
+	if id, err := publicHostedZoneID(zones, "Example.com"); err != nil || id != "Z0PUBLIC" {
+		t.Errorf("publicHostedZoneID unit test failure\n got: '%s', error: '%v', want: 'Z0PUBLIC'", id, err)
+	}
+
+	if _, err := publicHostedZoneID(zones, "example.org"); err == nil {
+		t.Errorf("publicHostedZoneID unit test failure\n missing zones must fail")
+	}
+}
+
+func TestCertManagerPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		zoneID string
+		zone   string
+	}{
+		{name: "Every hosted zone", zoneID: "", zone: "arn:aws:route53:::hostedzone/*"},
+		{name: "Scoped", zoneID: "Z0PUBLIC", zone: "arn:aws:route53:::hostedzone/Z0PUBLIC"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := certManagerPolicy(test.zoneID)
+			if err != nil || !strings.Contains(policy, `"Resource":"`+test.zone+`"`) {
+				t.Errorf("certManagerPolicy unit test failure\n got: '%s', want resource: '%s'", policy, test.zone)
+			}
+		})
+	}
+}
+
+func TestStoredCertManager(t *testing.T) {
+	if settings := storedCertManager(map[string]string{}); !settings.Enabled || settings.ZoneID != "" {
+		t.Errorf("storedCertManager unit test failure\n got: '%v', want: enabled for every hosted zone", settings)
+	}
+
+	if settings := storedCertManager(map[string]string{certManagerKey: certManagerDisabled}); settings.Enabled {
+		t.Errorf("storedCertManager unit test failure\n got: '%v', want: disabled", settings)
+	}
+}
+
+// a disabled role is kept in the stored metadata through later updates and by clones
+func TestCertManagerDisabledPersists(t *testing.T) {
+	stored := mergeStackConfig(map[string]string{}, createCertManagerConfig(Event{NoCertManager: true}, stackSummary{}))
+
+	// updates of other settings merge their changes into the stored metadata
+	stored = mergeStackConfig(stored, map[string]string{protectedKey: "true"})
+
+	metadata, err := encodeStackMetadata(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := applyStackMetadata(stackSummary{Name: "my-cluster"}, stackObject{Key: metadataKey("my-cluster")}, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if settings := storedCertManager(summary.Config); settings.Enabled {
+		t.Errorf("storedCertManager unit test failure\n got: '%v', want: disabled after a metadata round trip", settings)
+	}
+
+	if clone := createCertManagerConfig(Event{}, summary); clone[certManagerKey] != certManagerDisabled {
+		t.Errorf("createCertManagerConfig unit test failure\n got: '%v', want: disabled for a clone", clone)
+	}
+}
@@ -0,0 +1,96 @@
+package dispatch
+
+// create clusters from the stored configuration of an existing cluster
+
+import (
+	"fmt"
+	"os"
+)
+
+const cloneAction string = "clone"
+
+// stack config copied to clones in addition to the cluster spec
+var clonedConfigKeys = []string{
+	scheduleKey,
+	scheduleNATKey,
+}
+
+// fill cluster spec fields not provided as overrides from the source cluster's stored config
+func applyCloneSource(event *Event, source stackSummary) error {
+	if source.Config[adoptedKey] != "" {
+		return fmt.Errorf("%s is an adopted cluster, adopted clusters cannot be cloned", source.Name)
+	}
+
+	if source.Config[nodeCountKey] == "" {
+		return fmt.Errorf("%s has no stored cluster spec, clusters created by earlier Dispatch versions cannot be cloned", source.Name)
+	}
+
+	if event.Size == "" {
+		event.Size = source.Config[nodeSizeKey]
+	}
+
+	if event.Count == "" {
+		// sleeping clusters are cloned at their awake node count
+		event.Count = source.Config[nodeCountKey]
+
+		if clusterAsleep(source) {
+			event.Count = source.Config[wakeNodeCountKey]
+		}
+	}
+
+	if event.Version == "" {
+		event.Version = source.Config[versionKey]
+	}
+
+	if event.Version == "" {
+		event.Version = k8sVersion
+	}
+
+	if event.Region == "" {
+		if region := source.Config[regionConfigKey]; region != "" {
+			event.Region = region
+		}
+	}
+
+	return nil
+}
+
+// stack config of a clone copied from the source cluster
+func cloneConfig(source stackSummary) map[string]string {
+	values := map[string]string{}
+
+	for _, key := range clonedConfigKeys {
+		if value := source.Config[key]; value != "" {
+			values[key] = value
+		}
+	}
+
+	return values
+}
+
+// create a new cluster from an existing cluster's configuration with overrides applied
+func cloneCluster(event *Event) string {
+	summaries := getStackSummaries(event.Bucket)
+
+	source, found := summaries[event.CloneFrom]
+	if !found {
+		fmt.Printf("\n %s was not found, exiting.\n\n", event.CloneFrom)
+		os.Exit(0)
+	}
+
+	if _, exists := summaries[event.Name]; exists {
+		reportErr(fmt.Errorf("cluster %s already exists", event.Name), "clone cluster "+event.CloneFrom)
+	}
+
+	if err := applyCloneSource(event, source); err != nil {
+		reportErr(err, "clone cluster "+event.CloneFrom)
+	}
+
+	if _, err := getNodeSize(event.Size); err != nil {
+		reportErr(err, "clone cluster "+event.CloneFrom)
+	}
+
+	event.Action = createAction
+
+	return Exec(event)
+}
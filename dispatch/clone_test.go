@@ -0,0 +1,86 @@
+package dispatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyCloneSource(t *testing.T) {
+	source := stackSummary{
+		Name: "my-cluster",
+		Config: map[string]string{
+			regionConfigKey: "us-west-2",
+			nodeSizeKey:     "medium",
+			nodeCountKey:    "3",
+			versionKey:      "1.24",
+		},
+	}
+
+	asleep := stackSummary{
+		Name: "sleepy",
+		Config: map[string]string{
+			nodeSizeKey:      "small",
+			nodeCountKey:     "0",
+			wakeNodeCountKey: "2",
+		},
+	}
+
+	// input clone overrides and source cluster
+	// return cluster spec of the clone
+	tests := []struct {
+		name   string
+		event  Event
+		source stackSummary
+		want   Event
+		err    bool
+	}{
+		{
+			name:   "Source spec",
+			source: source,
+			want:   Event{Size: "medium", Count: "3", Version: "1.24", Region: "us-west-2"},
+		},
+		{
+			name:   "Overrides",
+			event:  Event{Size: "large", Count: "5", Region: "eu-west-1"},
+			source: source,
+			want:   Event{Size: "large", Count: "5", Version: "1.24", Region: "eu-west-1"},
+		},
+		{
+			name:   "Sleeping source",
+			source: asleep,
+			want:   Event{Size: "small", Count: "2", Version: k8sVersion},
+		},
+		{
+			name:   "No stored spec",
+			source: stackSummary{Name: "legacy", Config: map[string]string{}},
+			err:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := test.event
+			err := applyCloneSource(&event, test.source)
+
+			if (err != nil) != test.err || (!test.err && !reflect.DeepEqual(event, test.want)) {
+				t.Errorf("applyCloneSource unit test failure\n got: '%+v', want: '%+v', error: '%v'", event, test.want, err)
+			}
+		})
+	}
+}
+
+func TestCloneConfig(t *testing.T) {
+	source := stackSummary{
+		Config: map[string]string{
+			scheduleKey:      "mon-fri 08:00-19:00 UTC",
+			ownerConfigKey:   "alice",
+			expiryConfigKey:  "2022-12-01T18:00:00Z",
+			wakeNodeCountKey: "2",
+		},
+	}
+
+	want := map[string]string{scheduleKey: "mon-fri 08:00-19:00 UTC"}
+
+	if got := cloneConfig(source); !reflect.DeepEqual(got, want) {
+		t.Errorf("cloneConfig unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+}
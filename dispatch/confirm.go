@@ -0,0 +1,107 @@
+package dispatch
+
+// typed-name confirmation of destructive cluster operations
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/christiantragesser/dispatch/tuiconfirm"
+)
+
+// resource types counted on the delete confirmation, in display order
+var confirmationTypes = []struct {
+	label string
+	types []string
+}{
+	{label: "EKS clusters", types: []string{eksClusterType}},
+	{label: "node groups", types: []string{"aws:eks/nodeGroup:NodeGroup", "aws:cloudformation/stack:Stack", "aws:autoscaling/group:Group"}},
+	{label: "VPCs", types: []string{"aws:ec2/vpc:Vpc"}},
+	{label: "subnets", types: []string{"aws:ec2/subnet:Subnet"}},
+	{label: "NAT gateways", types: []string{natGatewayType}},
+	{label: "load balancers", types: loadBalancerTypes},
+	{label: "IAM roles", types: []string{"aws:iam/role:Role"}},
+}
+
+// cluster age rounded to hours, days are shown for clusters older than a day
+func clusterAge(summary stackSummary, now time.Time) string {
+	age := now.Sub(clusterCreated(summary))
+
+	if age < time.Hour {
+		return age.Round(time.Minute).String()
+	}
+
+	hours := int(age.Hours())
+
+	if hours < hoursPerDay {
+		return fmt.Sprintf("%dh", hours)
+	}
+
+	return fmt.Sprintf("%dd %dh", hours/hoursPerDay, hours%hoursPerDay)
+}
+
+// stack resource total with counts of the resource types most affected by a delete
+func resourceCounts(summary stackSummary) string {
+	var counts []string
+
+	for _, counted := range confirmationTypes {
+		count := 0
+
+		for _, resourceType := range counted.types {
+			count += summary.Types[resourceType]
+		}
+
+		if count > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", count, counted.label))
+		}
+	}
+
+	if len(counts) == 0 {
+		return fmt.Sprintf("%d", summary.Resources)
+	}
+
+	return fmt.Sprintf("%d (%s)", summary.Resources, strings.Join(counts, ", "))
+}
+
+// details of a cluster shown before it is deleted and by describe
+func clusterDetails(summary stackSummary, catalog pricingCatalog, now time.Time) []string {
+	details := []string{
+		"Cluster name: " + summary.Name,
+		"Owner: " + clusterOwner(summary),
+		fmt.Sprintf("Age: %s, created %s UTC", clusterAge(summary, now), clusterCreated(summary).UTC().Format("2006-01-02 15:04:05")),
+		"Stack resources: " + resourceCounts(summary),
+	}
+
+	estimate, err := estimateCost(catalog, stackResources(summary))
+	if err != nil {
+		return append(details, fmt.Sprintf("Estimated cost: unavailable, %v", err))
+	}
+
+	return append(details, fmt.Sprintf("Estimated cost: %.2f %s/hour, %.2f %s accrued",
+		estimate.Hourly, catalog.Currency, now.Sub(clusterCreated(summary)).Hours()*estimate.Hourly, catalog.Currency))
+}
+
+// require a value to be typed before a destructive operation, a stray y is not enough
+func confirmTyped(tui bool, title string, details []string, expected string) bool {
+	if tui {
+		return tuiconfirm.Confirm(title, details, expected)
+	}
+
+	var typed string
+
+	fmt.Println()
+
+	for _, detail := range details {
+		fmt.Printf(" %s\n", detail)
+	}
+
+	fmt.Printf("\n ? type %s to %s: ", expected, title)
+	fmt.Scanf("%s", &typed)
+
+	return typed == expected
+}
+
+func confirmDelete(event Event, details []string) bool {
+	return confirmTyped(event.TUI, "delete cluster "+event.Name, details, event.Name)
+}
@@ -0,0 +1,76 @@
+package dispatch
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClusterAge(t *testing.T) {
+	now := time.Date(2022, 12, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		created time.Time
+		age     string
+	}{
+		{name: "Minutes", created: now.Add(-25 * time.Minute), age: "25m0s"},
+		{name: "Hours", created: now.Add(-5*time.Hour - 40*time.Minute), age: "5h"},
+		{name: "Days", created: now.Add(-76 * time.Hour), age: "3d 4h"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			summary := stackSummary{Config: map[string]string{createdConfigKey: test.created.Format(time.RFC3339)}}
+
+			if age := clusterAge(summary, now); age != test.age {
+				t.Errorf("clusterAge unit test failure\n got: '%s', want: '%s'", age, test.age)
+			}
+		})
+	}
+}
+
+func TestDeleteDetails(t *testing.T) {
+	now := time.Date(2022, 12, 10, 12, 0, 0, 0, time.UTC)
+
+	catalog := pricingCatalog{
+		Currency: "USD",
+		Regions: map[string]regionPricing{
+			"us-east-1": {EKSControlPlane: 0.1, NATGateway: 0.1},
+		},
+	}
+
+	summary := stackSummary{
+		Name:      "shared-demo",
+		Resources: 42,
+		Types: map[string]int{
+			eksClusterType:                   1,
+			"aws:cloudformation/stack:Stack": 1,
+			"aws:ec2/subnet:Subnet":          6,
+			natGatewayType:                   1,
+			"aws:ec2/routeTable:RouteTable":  6,
+		},
+		Config: map[string]string{
+			ownerConfigKey:   "tester",
+			createdConfigKey: now.Add(-10 * time.Hour).Format(time.RFC3339),
+			regionConfigKey:  "us-east-1",
+		},
+	}
+
+	want := []string{
+		"Cluster name: shared-demo",
+		"Owner: tester",
+		"Age: 10h, created 2022-12-10 02:00:00 UTC",
+		"Stack resources: 42 (1 EKS clusters, 1 node groups, 6 subnets, 1 NAT gateways)",
+		"Estimated cost: 0.20 USD/hour, 2.00 USD accrued",
+	}
+
+	if got := clusterDetails(summary, catalog, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("clusterDetails unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+
+	summary.Config[regionConfigKey] = "mars-north-1"
+
+	if got := clusterDetails(summary, catalog, now); got[len(got)-1] != "Estimated cost: unavailable, no pricing available for region mars-north-1" {
+		t.Errorf("clusterDetails unit test failure\n got: '%v', want: unavailable estimate", got[len(got)-1])
+	}
+}
@@ -0,0 +1,350 @@
+package dispatch
+
+// EKS control plane endpoint access, logging and secrets encryption
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/kms"
+	"github.com/pulumi/pulumi-eks/sdk/go/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	privateEndpointKey  string        = "dispatch:privateEndpoint"
+	publicCIDRsKey      string        = "dispatch:publicAccessCidrs"
+	logTypesKey         string        = "dispatch:clusterLogTypes"
+	logRetentionKey     string        = "dispatch:clusterLogRetention"
+	kmsKeyKey           string        = "dispatch:secretsKmsKey"
+	eksNameKey          string        = "dispatch:eksClusterName"
+	callerCIDR          string        = "my-ip"
+	allLogTypes         string        = "all"
+	createKMSKey        string        = "create"
+	defaultLogRetention int           = 30
+	kmsDeletionWindow   int           = 7
+	maxEKSNameLength    int           = 100
+	egressIPURL         string        = "https://checkip.amazonaws.com"
+	egressIPTimeout     time.Duration = 10 * time.Second
+)
+
+// control plane settings stored with the stack config, clones copy them
+var controlPlaneKeys = []string{privateEndpointKey, publicCIDRsKey, logTypesKey, logRetentionKey, kmsKeyKey}
+
+var clusterLogTypes = []string{"api", "audit", "authenticator", "controllerManager", "scheduler"}
+
+// retention periods accepted by CloudWatch Logs
+var logRetentionDays = []int{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1827, 2192, 2557, 2922, 3288, 3653}
+
+type controlPlane struct {
+	PrivateEndpoint bool
+	PublicCIDRs     []string
+	LogTypes        []string
+	LogRetention    int
+	KMSKey          string
+	ClusterName     string
+}
+
+func splitList(list string) []string {
+	var values []string
+
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// control plane log types of a comma separated list, all enables every log type
+func parseLogTypes(logTypes string) ([]string, error) {
+	if logTypes == allLogTypes {
+		return clusterLogTypes, nil
+	}
+
+	var parsed []string
+
+	for _, logType := range splitList(logTypes) {
+		valid := false
+
+		for _, known := range clusterLogTypes {
+			if logType == known {
+				valid = true
+			}
+		}
+
+		if !valid {
+			return nil, fmt.Errorf("unknown control plane log type %s, use %s or all", logType, strings.Join(clusterLogTypes, ", "))
+		}
+
+		parsed = append(parsed, logType)
+	}
+
+	return parsed, nil
+}
+
+func validateControlPlane(event Event) error {
+	for _, cidr := range splitList(event.PublicCIDRs) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil && cidr != callerCIDR {
+			return fmt.Errorf("invalid public access CIDR %s, use CIDR blocks or %s", cidr, callerCIDR)
+		}
+	}
+
+	if _, err := parseLogTypes(event.LogTypes); err != nil {
+		return err
+	}
+
+	if event.LogRetention != "" {
+		days, err := strconv.Atoi(event.LogRetention)
+		valid := false
+
+		for _, retention := range logRetentionDays {
+			if err == nil && days == retention {
+				valid = true
+			}
+		}
+
+		if !valid {
+			return fmt.Errorf("invalid log retention %s, CloudWatch Logs retains logs for 1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1827, 2192, 2557, 2922, 3288 or 3653 days", event.LogRetention)
+		}
+
+		if event.LogTypes == "" {
+			return fmt.Errorf("log retention requires control plane log types")
+		}
+	}
+
+	if event.KMSKey != "" && event.KMSKey != createKMSKey && !strings.HasPrefix(event.KMSKey, "arn:") {
+		return fmt.Errorf("invalid KMS key %s, use a KMS key ARN or %s", event.KMSKey, createKMSKey)
+	}
+
+	return nil
+}
+
+// stack config values of the control plane flags of a create event
+func controlPlaneConfig(event Event) map[string]string {
+	values := map[string]string{}
+
+	if event.PrivateEndpoint {
+		values[privateEndpointKey] = "true"
+	}
+
+	// nodes reach a restricted public endpoint from their NAT gateway IPs, the private endpoint keeps them connected
+	if cidrs := splitList(event.PublicCIDRs); len(cidrs) > 0 {
+		values[publicCIDRsKey] = strings.Join(cidrs, ",")
+		values[privateEndpointKey] = "true"
+	}
+
+	if logTypes, _ := parseLogTypes(event.LogTypes); len(logTypes) > 0 {
+		values[logTypesKey] = strings.Join(logTypes, ",")
+		values[logRetentionKey] = strconv.Itoa(defaultLogRetention)
+
+		if event.LogRetention != "" {
+			values[logRetentionKey] = event.LogRetention
+		}
+	}
+
+	if event.KMSKey != "" {
+		values[kmsKeyKey] = event.KMSKey
+	}
+
+	return values
+}
+
+// public IP address of the caller as seen by AWS
+func egressIP() (string, error) {
+	client := &http.Client{Timeout: egressIPTimeout}
+
+	response, err := client.Get(egressIPURL)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(string(data)))
+	if ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("unexpected egress IP address response: %s", strings.TrimSpace(string(data)))
+	}
+
+	return ip.String(), nil
+}
+
+// replace my-ip with the caller's egress IP, the resolved CIDR is stored so later updates keep it
+func resolvePublicCIDRs(cidrs string, lookup func() (string, error)) (string, error) {
+	var resolved []string
+
+	for _, cidr := range splitList(cidrs) {
+		if cidr == callerCIDR {
+			ip, err := lookup()
+			if err != nil {
+				return "", fmt.Errorf("look up egress IP address: %w", err)
+			}
+
+			cidr = ip + "/32"
+		}
+
+		resolved = append(resolved, cidr)
+	}
+
+	return strings.Join(resolved, ","), nil
+}
+
+func storedControlPlane(stackConfig map[string]string) controlPlane {
+	plane := controlPlane{
+		PrivateEndpoint: stackConfig[privateEndpointKey] == "true",
+		PublicCIDRs:     splitList(stackConfig[publicCIDRsKey]),
+		LogTypes:        splitList(stackConfig[logTypesKey]),
+		KMSKey:          stackConfig[kmsKeyKey],
+		ClusterName:     stackConfig[eksNameKey],
+	}
+
+	// clusters restricted by earlier versions gain private access with their next update
+	if len(plane.PublicCIDRs) > 0 {
+		plane.PrivateEndpoint = true
+	}
+
+	plane.LogRetention, _ = strconv.Atoi(stackConfig[logRetentionKey])
+
+	return plane
+}
+
+// control plane config of a new cluster, clones keep the source cluster's settings unless overridden
+func createControlPlaneConfig(event Event, source stackSummary) map[string]string {
+	values := map[string]string{}
+
+	for _, key := range controlPlaneKeys {
+		if value := source.Config[key]; value != "" {
+			values[key] = value
+		}
+	}
+
+	for key, value := range controlPlaneConfig(event) {
+		values[key] = value
+	}
+
+	if cidrs := values[publicCIDRsKey]; cidrs != "" {
+		resolved, err := resolvePublicCIDRs(cidrs, egressIP)
+		if err != nil {
+			reportErr(err, "resolve public access CIDRs")
+		}
+
+		values[publicCIDRsKey] = resolved
+	}
+
+	return values
+}
+
+// name truncated to a maximum length with a short hash suffix, the hash keeps fixed AWS names of different users apart
+func hashedName(name string, unique string, maxLength int) string {
+	hash := sha256.Sum256([]byte(unique))
+	suffix := "-" + hex.EncodeToString(hash[:])[:8]
+
+	if len(name)+len(suffix) > maxLength {
+		name = name[:maxLength-len(suffix)]
+	}
+
+	return name + suffix
+}
+
+// fixed EKS cluster name so its CloudWatch log group can be created ahead of the cluster
+// clusters without logging keep the auto-generated name, changing the name replaces a cluster
+func eksClusterName(eksID string, user string) string {
+	return hashedName(eksID, user, maxEKSNameLength)
+}
+
+// the fixed name of a cluster with control plane logging is computed once and stored,
+// updates and imports by other users keep it
+func clusterNameConfig(eksID string, user string, stackConfig map[string]string) map[string]string {
+	if stackConfig[logTypesKey] == "" || stackConfig[eksNameKey] != "" {
+		return nil
+	}
+
+	return map[string]string{eksNameKey: eksClusterName(eksID, user)}
+}
+
+// apply stored control plane settings to the EKS cluster arguments, returns options for resources the cluster depends on
+// public access is never disabled, the cluster component applies the aws-auth ConfigMap through the Kubernetes API
+// and a new cluster VPC is only reachable from outside AWS through the public endpoint
+func setControlPlaneArgs(ctx *pulumi.Context, args *eks.ClusterArgs, plane controlPlane, eksID string, tags pulumi.StringMap) ([]pulumi.ResourceOption, error) {
+	var opts []pulumi.ResourceOption
+
+	if plane.PrivateEndpoint {
+		args.EndpointPrivateAccess = pulumi.BoolPtr(true)
+	}
+
+	if len(plane.PublicCIDRs) > 0 {
+		args.PublicAccessCidrs = pulumi.ToStringArray(plane.PublicCIDRs)
+	}
+
+	switch plane.KMSKey {
+	case "":
+	case createKMSKey:
+		key, err := kms.NewKey(ctx, eksID+"-secrets", &kms.KeyArgs{
+			Description:          pulumi.Sprintf("Kubernetes secrets encryption of EKS cluster %s", eksID),
+			EnableKeyRotation:    pulumi.Bool(true),
+			DeletionWindowInDays: pulumi.Int(kmsDeletionWindow),
+			Tags:                 tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		args.EncryptionConfigKeyArn = key.Arn
+	default:
+		args.EncryptionConfigKeyArn = pulumi.String(plane.KMSKey)
+	}
+
+	if len(plane.LogTypes) > 0 {
+		name := plane.ClusterName
+		if name == "" {
+			return nil, fmt.Errorf("no EKS cluster name stored for cluster %s with control plane logging", eksID)
+		}
+
+		// EKS creates the log group without retention when it does not exist
+		logGroup, err := cloudwatch.NewLogGroup(ctx, eksID+"-control-plane", &cloudwatch.LogGroupArgs{
+			Name:            pulumi.String("/aws/eks/" + name + "/cluster"),
+			RetentionInDays: pulumi.Int(plane.LogRetention),
+			Tags:            tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		args.Name = pulumi.String(name)
+		args.EnabledClusterLogTypes = pulumi.ToStringArray(plane.LogTypes)
+		opts = append(opts, pulumi.DependsOn([]pulumi.Resource{logGroup}))
+	}
+
+	return opts, nil
+}
+
+func printControlPlane(plane controlPlane) {
+	if plane.PrivateEndpoint {
+		fmt.Print(" API endpoint: public and private\n")
+	}
+
+	if len(plane.PublicCIDRs) > 0 {
+		fmt.Printf(" Public API access: %s\n", strings.Join(plane.PublicCIDRs, ", "))
+	}
+
+	if len(plane.LogTypes) > 0 {
+		fmt.Printf(" Control plane logs: %s, retained %d days\n", strings.Join(plane.LogTypes, ", "), plane.LogRetention)
+	}
+
+	if plane.KMSKey != "" {
+		fmt.Printf(" Secrets encryption KMS key: %s\n", plane.KMSKey)
+	}
+}
@@ -0,0 +1,135 @@
+package dispatch
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateControlPlane(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		err   bool
+	}{
+		{name: "Defaults", event: Event{}},
+		{name: "Public CIDRs", event: Event{PublicCIDRs: "203.0.113.0/24, my-ip"}},
+		{name: "Invalid CIDR", event: Event{PublicCIDRs: "203.0.113.7"}, err: true},
+		{name: "Log types", event: Event{LogTypes: "api,audit", LogRetention: "90"}},
+		{name: "All log types", event: Event{LogTypes: "all"}},
+		{name: "Unknown log type", event: Event{LogTypes: "api,kubelet"}, err: true},
+		{name: "Unsupported retention", event: Event{LogTypes: "api", LogRetention: "10"}, err: true},
+		{name: "Retention without logs", event: Event{LogRetention: "30"}, err: true},
+		{name: "KMS key ARN", event: Event{KMSKey: "arn:aws:kms:us-east-1:123456789012:key/example"}},
+		{name: "Created KMS key", event: Event{KMSKey: "create"}},
+		{name: "Invalid KMS key", event: Event{KMSKey: "alias/eks"}, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateControlPlane(test.event); (err != nil) != test.err {
+				t.Errorf("validateControlPlane unit test failure\n got: '%v', want error: '%v'", err, test.err)
+			}
+		})
+	}
+}
+
+func TestControlPlaneConfig(t *testing.T) {
+	event := Event{PrivateEndpoint: true, PublicCIDRs: "203.0.113.0/24,", LogTypes: "all", KMSKey: "create"}
+
+	want := controlPlane{
+		PrivateEndpoint: true,
+		PublicCIDRs:     []string{"203.0.113.0/24"},
+		LogTypes:        clusterLogTypes,
+		LogRetention:    defaultLogRetention,
+		KMSKey:          "create",
+	}
+
+	if got := storedControlPlane(controlPlaneConfig(event)); !reflect.DeepEqual(got, want) {
+		t.Errorf("controlPlaneConfig unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+
+	if got := controlPlaneConfig(Event{}); len(got) != 0 {
+		t.Errorf("controlPlaneConfig unit test failure\n got: '%v', want: no config", got)
+	}
+	// restricted public access keeps nodes connected through the private endpoint
+	if got := storedControlPlane(controlPlaneConfig(Event{PublicCIDRs: "203.0.113.0/24"})); !got.PrivateEndpoint {
+		t.Errorf("controlPlaneConfig unit test failure\n got: '%v', want private access with public CIDRs", got)
+	}
+
+	if got := storedControlPlane(map[string]string{publicCIDRsKey: "203.0.113.0/24"}); !got.PrivateEndpoint {
+		t.Errorf("storedControlPlane unit test failure\n got: '%v', want private access for stored public CIDRs", got)
+	}
+}
+
+func TestResolvePublicCIDRs(t *testing.T) {
+	lookup := func() (string, error) { return "198.51.100.7", nil }
+
+	got, err := resolvePublicCIDRs("203.0.113.0/24,my-ip", lookup)
+	if err != nil || got != "203.0.113.0/24,198.51.100.7/32" {
+		t.Errorf("resolvePublicCIDRs unit test failure\n got: '%s', error: '%v'", got, err)
+	}
+
+	failed := func() (string, error) { return "", errors.New("timeout") }
+
+	if _, err := resolvePublicCIDRs("10.0.0.0/8", failed); err != nil {
+		t.Errorf("resolvePublicCIDRs unit test failure\n explicit CIDRs must not look up the egress IP: '%v'", err)
+	}
+
+	if _, err := resolvePublicCIDRs("my-ip", failed); err == nil {
+		t.Errorf("resolvePublicCIDRs unit test failure\n lookup errors must be returned")
+	}
+}
+
+func TestEKSClusterName(t *testing.T) {
+	name := eksClusterName("demo", "alice")
+
+	if !strings.HasPrefix(name, "demo-") || len(name) != len("demo-")+8 {
+		t.Errorf("eksClusterName unit test failure\n got: '%s', want: demo-<hash>", name)
+	}
+
+	if name == eksClusterName("demo", "bob") {
+		t.Errorf("eksClusterName unit test failure\n names of different users must not collide")
+	}
+
+	if long := eksClusterName(strings.Repeat("a", 120), "alice"); len(long) != maxEKSNameLength {
+		t.Errorf("eksClusterName unit test failure\n got length: '%d', want: '%d'", len(long), maxEKSNameLength)
+	}
+}
+
+func TestClusterNameConfig(t *testing.T) {
+	logging := map[string]string{logTypesKey: "api"}
+
+	if got := clusterNameConfig("demo", "alice", logging); got[eksNameKey] != eksClusterName("demo", "alice") {
+		t.Errorf("clusterNameConfig unit test failure\n got: '%v', want: '%v'", got, eksClusterName("demo", "alice"))
+	}
+
+	// updates by other users keep the stored name
+	stored := mergeStackConfig(logging, clusterNameConfig("demo", "alice", logging))
+
+	if got := clusterNameConfig("demo", "bob", stored); got != nil {
+		t.Errorf("clusterNameConfig unit test failure\n got: '%v', want the stored name kept", got)
+	}
+
+	if got := storedControlPlane(stored).ClusterName; got != eksClusterName("demo", "alice") {
+		t.Errorf("storedControlPlane unit test failure\n got: '%v', want: '%v'", got, eksClusterName("demo", "alice"))
+	}
+
+	if got := clusterNameConfig("demo", "alice", map[string]string{}); got != nil {
+		t.Errorf("clusterNameConfig unit test failure\n got: '%v', want: no fixed name without logging", got)
+	}
+}
+
+func TestLoggingProgramClusterName(t *testing.T) {
+	stored := eksClusterName("my-cluster", "alice")
+	plane := controlPlane{LogTypes: []string{"api"}, LogRetention: defaultLogRetention, ClusterName: stored}
+
+	// an update by another user runs the program with the stored name
+	event := Event{Name: "my-cluster", User: "bob", Action: accessAction, Size: "small", Count: "2"}
+
+	resources := runClusterProgram(t, event, clusterSettings{plane: plane})
+
+	if name := resources["eks:index:Cluster::my-cluster"]["name"]; !name.IsString() || name.StringValue() != stored {
+		t.Errorf("logging program test failure\n got EKS cluster name: '%v', want: '%v'", name, stored)
+	}
+}
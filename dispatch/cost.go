@@ -0,0 +1,281 @@
+package dispatch
+
+// offline cluster cost estimation
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	hoursPerMonth      float64 = 730
+	nodeVolumeGB       float64 = 20
+	defaultNATGateways int     = 3
+	natGatewayType     string  = "aws:ec2/natGateway:NatGateway"
+)
+
+// load balancer resource types managed by pulumi
+var loadBalancerTypes = []string{
+	"aws:lb/loadBalancer:LoadBalancer",
+	"aws:elb/loadBalancer:LoadBalancer",
+}
+
+// bundled on-demand pricing by region, USD per hour unless noted
+//
+//go:embed pricing.json
+var pricingJSON []byte
+
+type regionPricing struct {
+	EKSControlPlane float64            `json:"eksControlPlane"`
+	NATGateway      float64            `json:"natGateway"`
+	LoadBalancer    float64            `json:"loadBalancer"`
+	EBSGBMonth      float64            `json:"ebsGBMonth"`
+	EC2             map[string]float64 `json:"ec2"`
+}
+
+type pricingCatalog struct {
+	Currency string                   `json:"currency"`
+	Updated  string                   `json:"updated"`
+	Regions  map[string]regionPricing `json:"regions"`
+}
+
+// cluster resources used for cost estimates
+type clusterResources struct {
+	Region        string
+	InstanceType  string
+	Nodes         int
+	NATGateways   int
+	LoadBalancers int
+}
+
+type costItem struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Hourly   float64 `json:"hourly"`
+}
+
+type costEstimate struct {
+	Hourly  float64    `json:"hourly"`
+	Monthly float64    `json:"monthly"`
+	Items   []costItem `json:"items"`
+}
+
+type clusterCost struct {
+	Name     string       `json:"name"`
+	Region   string       `json:"region"`
+	Created  string       `json:"created"`
+	Hours    float64      `json:"hours"`
+	Accrued  float64      `json:"accrued"`
+	Estimate costEstimate `json:"estimate"`
+	Error    string       `json:"error,omitempty"`
+}
+
+func loadPricing() pricingCatalog {
+	var catalog pricingCatalog
+
+	if err := json.Unmarshal(pricingJSON, &catalog); err != nil {
+		reportErr(err, "load pricing catalog")
+	}
+
+	return catalog
+}
+
+// estimated hourly and monthly cost of cluster resources
+func estimateCost(catalog pricingCatalog, resources clusterResources) (costEstimate, error) {
+	var estimate costEstimate
+
+	pricing, found := catalog.Regions[resources.Region]
+	if !found {
+		return estimate, fmt.Errorf("no pricing available for region %s", resources.Region)
+	}
+
+	nodePrice, found := pricing.EC2[resources.InstanceType]
+	if !found && resources.Nodes > 0 {
+		return estimate, fmt.Errorf("no pricing available for instance type %s in %s", resources.InstanceType, resources.Region)
+	}
+
+	nodes := float64(resources.Nodes)
+
+	estimate.Items = []costItem{
+		{Name: "EKS control plane", Quantity: 1, Hourly: pricing.EKSControlPlane},
+		{Name: "EC2 " + resources.InstanceType + " nodes", Quantity: nodes, Hourly: nodes * nodePrice},
+		{Name: "EBS node volumes (GB)", Quantity: nodes * nodeVolumeGB, Hourly: nodes * nodeVolumeGB * pricing.EBSGBMonth / hoursPerMonth},
+		{Name: "NAT gateways", Quantity: float64(resources.NATGateways), Hourly: float64(resources.NATGateways) * pricing.NATGateway},
+		{Name: "Load balancers", Quantity: float64(resources.LoadBalancers), Hourly: float64(resources.LoadBalancers) * pricing.LoadBalancer},
+	}
+
+	for _, item := range estimate.Items {
+		estimate.Hourly += item.Hourly
+	}
+
+	estimate.Monthly = estimate.Hourly * hoursPerMonth
+
+	return estimate, nil
+}
+
+// resources of a new cluster
+func plannedResources(event Event, region string) clusterResources {
+	instanceType, err := getNodeSize(event.Size)
+	if err != nil {
+		reportErr(err, "get node instance type")
+	}
+
+	nodes, err := strconv.Atoi(event.Count)
+	if err != nil {
+		reportErr(err, "get cluster node count")
+	}
+
+	return clusterResources{
+		Region:       region,
+		InstanceType: instanceType,
+		Nodes:        nodes,
+		NATGateways:  defaultNATGateways,
+	}
+}
+
+// resources of an existing cluster from its stack summary
+func stackResources(summary stackSummary) clusterResources {
+	resources := clusterResources{
+		Region:      summaryRegion(summary),
+		NATGateways: summary.Types[natGatewayType],
+	}
+
+	for _, lbType := range loadBalancerTypes {
+		resources.LoadBalancers += summary.Types[lbType]
+	}
+
+	if instanceType, err := getNodeSize(summary.Config[nodeSizeKey]); err == nil {
+		resources.InstanceType = instanceType
+	}
+
+	if nodes, err := strconv.Atoi(summary.Config[nodeCountKey]); err == nil && resources.InstanceType != "" {
+		resources.Nodes = nodes
+	}
+
+	return resources
+}
+
+// cluster creation time, stacks created before creation tracking use their last update
+func clusterCreated(summary stackSummary) time.Time {
+	created, err := time.Parse(time.RFC3339, summary.Config[createdConfigKey])
+	if err != nil {
+		return summary.LastModified
+	}
+
+	return created
+}
+
+// resources of an existing cluster after node and NAT gateway scaling config changes
+func scaledResources(summary stackSummary, stackConfig map[string]string) clusterResources {
+	scaled := summary
+	scaled.Config = stackConfig
+
+	resources := stackResources(scaled)
+
+	switch stackConfig[natGatewaysKey] {
+	case sleepNATStrategy:
+		resources.NATGateways = 1
+	case defaultNATStrategy:
+		resources.NATGateways = defaultNATGateways
+	}
+
+	return resources
+}
+
+// print the estimated cost of cluster resources in an operation preview
+func previewCost(resources clusterResources) {
+	catalog := loadPricing()
+
+	estimate, err := estimateCost(catalog, resources)
+	if err != nil {
+		fmt.Printf(" ! Unable to estimate cluster cost: %v\n", err)
+
+		return
+	}
+
+	fmt.Printf(" Estimated cost: %.2f %s/hour, %.2f %s/month\n",
+		estimate.Hourly, catalog.Currency, estimate.Monthly, catalog.Currency)
+}
+
+// accrued cost of existing clusters, clusters without an estimate keep the error
+func clusterCosts(summaries map[string]stackSummary, name string, catalog pricingCatalog, now time.Time) []clusterCost {
+	costs := []clusterCost{}
+
+	for _, summary := range filterClusters(summaries, "", true) {
+		if name != "" && summary.Name != name {
+			continue
+		}
+
+		created := clusterCreated(summary)
+
+		cost := clusterCost{
+			Name:    summary.Name,
+			Region:  summaryRegion(summary),
+			Created: created.UTC().Format(time.RFC3339),
+		}
+
+		estimate, err := estimateCost(catalog, stackResources(summary))
+		if err != nil {
+			cost.Error = err.Error()
+			costs = append(costs, cost)
+
+			continue
+		}
+
+		cost.Hours = now.Sub(created).Hours()
+		cost.Accrued = cost.Hours * estimate.Hourly
+		cost.Estimate = estimate
+
+		costs = append(costs, cost)
+	}
+
+	return costs
+}
+
+// estimate accrued cost of existing clusters
+func reportClusterCosts(event Event) {
+	var estimated []clusterCost
+
+	catalog := loadPricing()
+	costs := clusterCosts(getStackSummaries(event.Bucket), event.Name, catalog, time.Now())
+
+	// clusters without an estimate are reported with their error in JSON reports
+	if event.Output == jsonOutput {
+		report, err := json.MarshalIndent(costs, "", "  ")
+		if err != nil {
+			reportErr(err, "create cost report")
+		}
+
+		fmt.Fprintln(reportOutput, string(report))
+
+		return
+	}
+
+	for _, cost := range costs {
+		if cost.Error != "" {
+			fmt.Printf(" ! Unable to estimate cost of %s: %s\n", cost.Name, cost.Error)
+
+			continue
+		}
+
+		estimated = append(estimated, cost)
+	}
+
+	costs = estimated
+
+	if len(costs) == 0 {
+		fmt.Print("\n . No cluster costs to report\n")
+
+		return
+	}
+
+	fmt.Printf("\n - Estimated cluster costs (%s, on-demand pricing as of %s):\n", catalog.Currency, catalog.Updated)
+
+	for _, cost := range costs {
+		fmt.Printf("\t <> %-24s %-16s %8.2f/hour %10.2f/month %10.2f accrued over %.0f hours\n",
+			cost.Name, cost.Region, cost.Estimate.Hourly, cost.Estimate.Monthly, cost.Accrued, cost.Hours)
+	}
+}
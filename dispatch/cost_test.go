@@ -0,0 +1,175 @@
+package dispatch
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEstimateCost(t *testing.T) {
+	catalog := pricingCatalog{
+		Currency: "USD",
+		Regions: map[string]regionPricing{
+			"us-east-1": {
+				EKSControlPlane: 0.1,
+				NATGateway:      0.045,
+				LoadBalancer:    0.0225,
+				EBSGBMonth:      0.073,
+				EC2:             map[string]float64{"t2.medium": 0.0464},
+			},
+		},
+	}
+
+	// input cluster resources
+	// return hourly cost and error
+	tests := []struct {
+		name      string
+		resources clusterResources
+		hourly    float64
+		err       bool
+	}{
+		{
+			name:      "Planned",
+			resources: clusterResources{Region: "us-east-1", InstanceType: "t2.medium", Nodes: 2, NATGateways: 3},
+			hourly:    0.1 + 2*0.0464 + 2*20*0.073/730 + 3*0.045,
+		},
+		{
+			name:      "LoadBalancers",
+			resources: clusterResources{Region: "us-east-1", InstanceType: "t2.medium", Nodes: 1, NATGateways: 1, LoadBalancers: 2},
+			hourly:    0.1 + 0.0464 + 20*0.073/730 + 0.045 + 2*0.0225,
+		},
+		{
+			name:      "Unknown nodes",
+			resources: clusterResources{Region: "us-east-1", NATGateways: 1},
+			hourly:    0.1 + 0.045,
+		},
+		{
+			name:      "Unknown region",
+			resources: clusterResources{Region: "mars-north-1", InstanceType: "t2.medium", Nodes: 2},
+			err:       true,
+		},
+		{
+			name:      "Unknown instance type",
+			resources: clusterResources{Region: "us-east-1", InstanceType: "x1.32xlarge", Nodes: 2},
+			err:       true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			estimate, err := estimateCost(catalog, test.resources)
+
+			if (err != nil) != test.err {
+				t.Fatalf("estimateCost unit test failure\n unexpected error: '%v'", err)
+			}
+
+			if math.Abs(estimate.Hourly-test.hourly) > 1e-9 || math.Abs(estimate.Monthly-test.hourly*hoursPerMonth) > 1e-6 {
+				t.Errorf("estimateCost unit test failure\n got: '%v', want: '%v'", estimate.Hourly, test.hourly)
+			}
+		})
+	}
+}
+
+func TestPricingCatalog(t *testing.T) {
+	catalog := loadPricing()
+
+	for _, region := range []string{defaultRegion, "us-west-2", "eu-west-1"} {
+		for _, size := range []string{smallEC2, mediumEC2, largeEC2} {
+			if _, found := catalog.Regions[region].EC2[size]; !found {
+				t.Errorf("pricing catalog unit test failure\n no %s price in %s", size, region)
+			}
+		}
+	}
+}
+
+func TestStackResources(t *testing.T) {
+	summary := stackSummary{
+		Types: map[string]int{
+			natGatewayType:                      3,
+			"aws:lb/loadBalancer:LoadBalancer":  1,
+			"aws:elb/loadBalancer:LoadBalancer": 1,
+		},
+		Config: map[string]string{
+			regionConfigKey: "us-west-2",
+			nodeSizeKey:     "medium",
+			nodeCountKey:    "4",
+		},
+	}
+
+	want := clusterResources{Region: "us-west-2", InstanceType: mediumEC2, Nodes: 4, NATGateways: 3, LoadBalancers: 2}
+
+	if got := stackResources(summary); got != want {
+		t.Errorf("stackResources unit test failure\n got: '%+v', want: '%+v'", got, want)
+	}
+}
+
+func TestClusterCreated(t *testing.T) {
+	modified := time.Date(2022, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		summary stackSummary
+		want    time.Time
+	}{
+		{
+			name:    "Created",
+			summary: stackSummary{LastModified: modified, Config: map[string]string{createdConfigKey: "2022-11-30T08:00:00Z"}},
+			want:    time.Date(2022, 11, 30, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "Untracked",
+			summary: stackSummary{LastModified: modified, Config: map[string]string{}},
+			want:    modified,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := clusterCreated(test.summary); !got.Equal(test.want) {
+				t.Errorf("clusterCreated unit test failure\n got: '%v', want: '%v'", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScaledResources(t *testing.T) {
+	summary := stackSummary{
+		Types:  map[string]int{natGatewayType: 3},
+		Config: map[string]string{regionConfigKey: defaultRegion, nodeSizeKey: "small", nodeCountKey: "2"},
+	}
+
+	asleep := map[string]string{regionConfigKey: defaultRegion, nodeSizeKey: "small", nodeCountKey: "0", natGatewaysKey: sleepNATStrategy}
+
+	want := clusterResources{Region: defaultRegion, InstanceType: smallEC2, Nodes: 0, NATGateways: 1}
+
+	if got := scaledResources(summary, asleep); got != want {
+		t.Errorf("scaledResources unit test failure\n got: '%+v', want: '%+v'", got, want)
+	}
+}
+
+func TestClusterCosts(t *testing.T) {
+	now := time.Date(2022, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	summaries := map[string]stackSummary{
+		"dev": {Name: "dev", Config: map[string]string{
+			regionConfigKey:  "us-west-2",
+			nodeSizeKey:      "small",
+			nodeCountKey:     "2",
+			createdConfigKey: "2022-12-01T02:00:00Z",
+		}},
+		"legacy": {Name: "legacy", LastModified: now, Config: map[string]string{}},
+	}
+
+	costs := clusterCosts(summaries, "", loadPricing(), now)
+
+	// clusters without an estimate are kept with their error for JSON reports
+	if len(costs) != 2 || costs[0].Name != "dev" || costs[0].Error != "" || costs[0].Hours != 10 || costs[0].Accrued != 10*costs[0].Estimate.Hourly {
+		t.Errorf("clusterCosts unit test failure\n got: '%+v'", costs)
+	}
+
+	if len(costs) == 2 && (costs[1].Name != "legacy" || costs[1].Error == "" || costs[1].Accrued != 0) {
+		t.Errorf("clusterCosts unit test failure\n got: '%+v', want an estimate error for legacy", costs[1])
+	}
+
+	if costs := clusterCosts(summaries, "dev", loadPricing(), now); len(costs) != 1 || costs[0].Name != "dev" {
+		t.Errorf("clusterCosts unit test failure\n got: '%+v', want dev only", costs)
+	}
+}
@@ -0,0 +1,153 @@
+package dispatch
+
+// AWS credential resolution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const credentialExpiryWarning time.Duration = 30 * time.Minute
+
+type credentialOptions struct {
+	roleChain   []string
+	externalID  string
+	sessionName string
+	mfaSerial   string
+}
+
+var sessionCredentials credentialOptions
+
+// split a comma separated list of role ARNs assumed in order
+func parseRoleChain(roleARNs string) []string {
+	var chain []string
+
+	for _, role := range strings.Split(roleARNs, ",") {
+		role = strings.TrimSpace(role)
+
+		if role != "" {
+			chain = append(chain, role)
+		}
+	}
+
+	return chain
+}
+
+func roleSessionName(event Event) string {
+	if event.SessionName != "" {
+		return event.SessionName
+	}
+
+	if event.User != "" {
+		return "dispatch-" + event.User
+	}
+
+	return "dispatch"
+}
+
+// set the credential options used when the AWS SDK config is loaded
+func setCredentialOptions(event Event) {
+	sessionCredentials = credentialOptions{
+		roleChain:   parseRoleChain(event.RoleARN),
+		externalID:  event.ExternalID,
+		sessionName: roleSessionName(event),
+		mfaSerial:   event.MFASerial,
+	}
+}
+
+// wrap base credentials with each role of the assume role chain
+// MFA is provided for the first hop, the external ID for the last
+func assumeRoleChain(clientConfig aws.Config, options credentialOptions) aws.CredentialsProvider {
+	provider := clientConfig.Credentials
+
+	for i, roleARN := range options.roleChain {
+		hop := i
+		stsClient := sts.NewFromConfig(clientConfig, func(o *sts.Options) {
+			o.Credentials = provider
+		})
+
+		assumeRole := stscreds.NewAssumeRoleProvider(stsClient, roleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = options.sessionName
+
+			if options.mfaSerial != "" && hop == 0 {
+				o.SerialNumber = aws.String(options.mfaSerial)
+				o.TokenProvider = stscreds.StdinTokenProvider
+			}
+
+			if options.externalID != "" && hop == len(options.roleChain)-1 {
+				o.ExternalID = aws.String(options.externalID)
+			}
+		})
+
+		provider = aws.NewCredentialsCache(assumeRole)
+	}
+
+	return provider
+}
+
+// role assumed by kubectl when requesting EKS tokens, kubectl can't provide an external ID or MFA token
+// so those roles are left to the credentials kubectl runs with
+func kubeconfigRoleARN(options credentialOptions) string {
+	if len(options.roleChain) == 0 || options.externalID != "" || options.mfaSerial != "" {
+		return ""
+	}
+
+	return options.roleChain[len(options.roleChain)-1]
+}
+
+// retrieve credentials once so SSO and assume role failures are reported up front
+func verifyCredentials(ctx context.Context, clientConfig aws.Config, profile string) aws.Credentials {
+	var ssoErr *ssocreds.InvalidTokenError
+
+	creds, err := clientConfig.Credentials.Retrieve(ctx)
+	if errors.As(err, &ssoErr) {
+		fmt.Printf(" ! The AWS SSO session for profile '%s' has expired or is invalid\n", profile)
+		fmt.Printf(" ! Run 'aws sso login --profile %s' and try again\n", profile)
+		os.Exit(1)
+	}
+
+	if err != nil {
+		reportErr(err, "find AWS credentials in env vars, credentials file or assumed role")
+	}
+
+	if creds.CanExpire && time.Until(creds.Expires) < credentialExpiryWarning {
+		fmt.Printf(" ! AWS credentials expire at %s, long running operations may fail\n", creds.Expires.Local().Format(time.Kitchen))
+	}
+
+	return creds
+}
+
+// provide resolved credentials to pulumi and aws CLI subprocesses
+func exportCredentials(creds aws.Credentials) {
+	if _, envarCredsSet := os.LookupEnv("AWS_ACCESS_KEY_ID"); envarCredsSet && len(sessionCredentials.roleChain) == 0 {
+		return
+	}
+
+	os.Setenv("AWS_ACCESS_KEY_ID", creds.AccessKeyID)
+	os.Setenv("AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey)
+	os.Setenv("AWS_SESSION_TOKEN", creds.SessionToken)
+}
+
+// re-export session credentials for long running processes, assumed role credentials expire
+func refreshCredentials() {
+	sess := getSession()
+
+	ctx, cancel := sess.requestContext()
+	defer cancel()
+
+	creds, err := sess.config.Credentials.Retrieve(ctx)
+	if err != nil {
+		reportErr(err, "refresh AWS credentials")
+	}
+
+	exportCredentials(creds)
+}
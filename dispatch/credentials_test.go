@@ -0,0 +1,99 @@
+package dispatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoleChain(t *testing.T) {
+	// input comma separated role ARNs
+	// return ordered role chain
+	tests := []struct {
+		expectedReturn []string
+		name           string
+		input          string
+	}{
+		{
+			name:           "No role",
+			input:          "",
+			expectedReturn: nil,
+		},
+		{
+			name:           "Single role",
+			input:          "arn:aws:iam::111111111111:role/deploy",
+			expectedReturn: []string{"arn:aws:iam::111111111111:role/deploy"},
+		},
+		{
+			name:  "Role chain",
+			input: "arn:aws:iam::111111111111:role/tooling, arn:aws:iam::222222222222:role/deploy,",
+			expectedReturn: []string{
+				"arn:aws:iam::111111111111:role/tooling",
+				"arn:aws:iam::222222222222:role/deploy",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chain := parseRoleChain(test.input)
+
+			if !reflect.DeepEqual(chain, test.expectedReturn) {
+				t.Errorf("parseRoleChain unit test failure\n got: '%v', want: '%v'", chain, test.expectedReturn)
+			}
+		})
+	}
+}
+
+func TestRoleSessionName(t *testing.T) {
+	tests := []struct {
+		expectedReturn string
+		name           string
+		input          Event
+	}{
+		{
+			name:           "Default",
+			input:          Event{},
+			expectedReturn: "dispatch",
+		},
+		{
+			name:           "User ID",
+			input:          Event{User: "alice"},
+			expectedReturn: "dispatch-alice",
+		},
+		{
+			name:           "Session name flag",
+			input:          Event{User: "alice", SessionName: "ci-deploy"},
+			expectedReturn: "ci-deploy",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := roleSessionName(test.input)
+
+			if session != test.expectedReturn {
+				t.Errorf("roleSessionName unit test failure\n got: '%v', want: '%v'", session, test.expectedReturn)
+			}
+		})
+	}
+}
+
+func TestKubeconfigRoleARN(t *testing.T) {
+	chain := []string{"arn:aws:iam::111111111111:role/hop", "arn:aws:iam::222222222222:role/deploy"}
+
+	tests := []struct {
+		name    string
+		options credentialOptions
+		want    string
+	}{
+		{name: "No role", options: credentialOptions{}, want: ""},
+		{name: "Role chain", options: credentialOptions{roleChain: chain}, want: chain[1]},
+		{name: "External ID", options: credentialOptions{roleChain: chain, externalID: "my-external-id"}, want: ""},
+		{name: "MFA", options: credentialOptions{roleChain: chain, mfaSerial: "arn:aws:iam::111111111111:mfa/alice"}, want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := kubeconfigRoleARN(test.options); got != test.want {
+				t.Errorf("kubeconfigRoleARN unit test failure\n got: '%s', want: '%s'", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package dispatch
+
+// cluster details from the stored stack configuration and outputs
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const describeAction string = "describe"
+
+func describeCluster(event Event) {
+	summary, found := getStackSummaries(event.Bucket)[event.Name]
+	if !found {
+		fmt.Printf("\n %s was not found, exiting.\n\n", event.Name)
+		os.Exit(0)
+	}
+
+	now := time.Now()
+
+	fmt.Println()
+
+	for _, detail := range clusterDetails(summary, loadPricing(), now) {
+		fmt.Printf(" %s\n", detail)
+	}
+
+	fmt.Printf(" AWS region: %s\n", summaryRegion(summary))
+	fmt.Printf(" Last updated: %s UTC\n", summary.LastModified.UTC().Format("2006-01-02 15:04:05"))
+
+	if version := summary.Config[versionKey]; version != "" {
+		fmt.Printf(" Kubernetes version: %s\n", version)
+	}
+
+	if size := summary.Config[nodeSizeKey]; size != "" {
+		fmt.Printf(" Cluster node size: %s\n", size)
+	}
+
+	if clusterAsleep(summary) {
+		fmt.Printf(" Cluster node count: asleep, wakes to %s nodes\n", summary.Config[wakeNodeCountKey])
+		fmt.Printf(" Sleep billing: %s\n", sleepBilling(stackResources(summary)))
+	} else if count := summary.Config[nodeCountKey]; count != "" {
+		fmt.Printf(" Cluster node count: %s\n", count)
+	}
+
+	if schedule := summary.Config[scheduleKey]; schedule != "" {
+		fmt.Printf(" Scheduled awake: %s\n", schedule)
+	}
+
+	if expiry, found := clusterExpiry(summary); found {
+		fmt.Printf(" Expires: %s UTC %s\n", expiry.UTC().Format("2006-01-02 15:04:05"), expiryStatus(summary, now))
+	}
+
+	if clusterProtected(summary) {
+		fmt.Print(" Deletion protection: enabled\n")
+	}
+
+	if tags := clusterTags(summary); len(tags) > 0 {
+		fmt.Printf(" Cluster tags: %s\n", formatTags(tags))
+	}
+
+	printControlPlane(storedControlPlane(summary.Config))
+
+	if access := storedAccessMappings(summary.Config); len(access) > 0 {
+		fmt.Print(" Access mappings:\n")
+		printAccessMappings(access)
+	}
+
+	if _, isAdopted := storedAdoption(summary.Config); !isAdopted {
+		printCertManager(storedCertManager(summary.Config))
+	}
+
+	arns := irsaRoleARNs(summary)
+
+	if roles := clusterServiceAccountRoles(storedServiceAccountRoles(summary.Config), arns); len(roles) > 0 {
+		fmt.Print(" Service account roles:\n")
+		printServiceAccountRoles(roles, arns)
+	}
+
+	fmt.Println()
+}
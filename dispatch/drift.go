@@ -0,0 +1,262 @@
+package dispatch
+
+// detect resources changed outside Dispatch by comparing stack state with live AWS resources
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	driftAction   string = "drift"
+	driftStatus   int    = 2
+	driftModified string = "modified"
+	driftDeleted  string = "deleted"
+)
+
+type driftedResource struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	URN        string   `json:"urn"`
+	Change     string   `json:"change"`
+	Properties []string `json:"properties,omitempty"`
+}
+
+type driftReport struct {
+	Name      string            `json:"name"`
+	Region    string            `json:"region"`
+	Drifted   bool              `json:"drifted"`
+	Resources []driftedResource `json:"resources"`
+}
+
+// number of resources a refresh changed in the stack state
+func refreshedChanges(summary auto.UpdateSummary) int {
+	if summary.ResourceChanges == nil {
+		return 0
+	}
+
+	changes := *summary.ResourceChanges
+
+	return changes[string(apitype.OpUpdate)] + changes[string(apitype.OpDelete)]
+}
+
+// top level outputs that differ between the stack state and the refreshed state
+func changedProperties(stored map[string]interface{}, refreshed map[string]interface{}) []string {
+	var changed []string
+
+	for key, value := range stored {
+		if refreshedValue, found := refreshed[key]; !found || !reflect.DeepEqual(value, refreshedValue) {
+			changed = append(changed, key)
+		}
+	}
+
+	for key := range refreshed {
+		if _, found := stored[key]; !found {
+			changed = append(changed, key)
+		}
+	}
+
+	sort.Strings(changed)
+
+	return changed
+}
+
+// drift of a single refresh step, component and provider resources are never refreshed
+func stepDrift(step apitype.StepEventMetadata) (driftedResource, bool) {
+	if step.Old == nil || !step.Old.Custom || strings.HasPrefix(step.Type, "pulumi:providers:") {
+		return driftedResource{}, false
+	}
+
+	resource := driftedResource{
+		Name: step.URN[strings.LastIndex(step.URN, "::")+2:],
+		Type: step.Type,
+		URN:  step.URN,
+	}
+
+	switch {
+	case step.Op == apitype.OpDelete || step.New == nil:
+		resource.Change = driftDeleted
+	case step.Op == apitype.OpSame:
+		return driftedResource{}, false
+	default:
+		resource.Properties = changedProperties(step.Old.Outputs, step.New.Outputs)
+		if len(resource.Properties) == 0 {
+			return driftedResource{}, false
+		}
+
+		resource.Change = driftModified
+	}
+
+	return resource, true
+}
+
+// drifted resources from a pulumi engine event log, one JSON event per line
+func parseDriftEvents(data []byte) ([]driftedResource, error) {
+	drifted := []driftedResource{}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var event apitype.EngineEvent
+
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, err
+		}
+
+		var step apitype.StepEventMetadata
+
+		switch {
+		case event.ResOutputsEvent != nil:
+			step = event.ResOutputsEvent.Metadata
+		case event.ResourcePreEvent != nil:
+			step = event.ResourcePreEvent.Metadata
+		default:
+			continue
+		}
+
+		if seen[step.URN] {
+			continue
+		}
+
+		if resource, found := stepDrift(step); found {
+			seen[step.URN] = true
+			drifted = append(drifted, resource)
+		}
+	}
+
+	return drifted, scanner.Err()
+}
+
+// preview a refresh of a cluster's stack without updating the stack state
+func previewRefresh(event Event, summary stackSummary) []driftedResource {
+	region := clusterRegion(event, summary)
+	ctx := context.Background()
+
+	// refreshes read resource state only, the cluster program is not run
+	stackConfig := mergeStackConfig(summary.Config, map[string]string{regionConfigKey: region})
+
+	s := selectPulumiStack(ctx, event, region, stackConfig, func(ctx *pulumi.Context) error { return nil })
+
+	eventLog := filepath.Join(s.Workspace().WorkDir(), "drift-events.json")
+
+	var output bytes.Buffer
+
+	cmd := exec.Command("pulumi", "refresh", "--preview-only", "--non-interactive",
+		"--event-log", eventLog, "--stack", s.Name())
+	cmd.Dir = s.Workspace().WorkDir()
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		reportErr(fmt.Errorf("%w: %s", err, strings.TrimSpace(output.String())), "preview refresh of cluster "+event.Name)
+	}
+
+	data, err := os.ReadFile(eventLog)
+	if err != nil {
+		reportErr(err, "read refresh events of cluster "+event.Name)
+	}
+
+	drifted, err := parseDriftEvents(data)
+	if err != nil {
+		reportErr(err, "parse refresh events of cluster "+event.Name)
+	}
+
+	return drifted
+}
+
+func printDriftReports(reports []driftReport, output string) {
+	if output == jsonOutput {
+		report, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			reportErr(err, "create drift report")
+		}
+
+		fmt.Fprintln(reportOutput, string(report))
+
+		return
+	}
+
+	for _, report := range reports {
+		if !report.Drifted {
+			fmt.Fprintf(reportOutput, "\n . No drift detected in cluster %s (%s)\n", report.Name, report.Region)
+
+			continue
+		}
+
+		fmt.Fprintf(reportOutput, "\n - Drift detected in cluster %s (%s):\n", report.Name, report.Region)
+
+		for _, resource := range report.Resources {
+			if resource.Change == driftDeleted {
+				fmt.Fprintf(reportOutput, "\t - deleted  %s %s\n", resource.Type, resource.Name)
+
+				continue
+			}
+
+			fmt.Fprintf(reportOutput, "\t ~ modified %s %s (%s)\n", resource.Type, resource.Name, strings.Join(resource.Properties, ", "))
+		}
+	}
+}
+
+// report resources changed outside Dispatch, exits with driftStatus when drift is found
+func detectDrift(event Event) {
+	var reports []driftReport
+
+	summaries := getStackSummaries(event.Bucket)
+	clusters := filterClusters(summaries, "", true)
+
+	if !event.All {
+		summary, found := summaries[event.Name]
+		if !found {
+			fmt.Printf("\n %s was not found, exiting.\n\n", event.Name)
+			os.Exit(1)
+		}
+
+		clusters = []stackSummary{summary}
+	}
+
+	drifted := false
+
+	for _, summary := range clusters {
+		clusterEvent := event
+		clusterEvent.Name = summary.Name
+
+		resources := previewRefresh(clusterEvent, summary)
+
+		reports = append(reports, driftReport{
+			Name:      summary.Name,
+			Region:    summaryRegion(summary),
+			Drifted:   len(resources) > 0,
+			Resources: resources,
+		})
+
+		drifted = drifted || len(resources) > 0
+	}
+
+	if reports == nil {
+		reports = []driftReport{}
+	}
+
+	printDriftReports(reports, event.Output)
+
+	if drifted {
+		os.Exit(driftStatus)
+	}
+}
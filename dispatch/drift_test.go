@@ -0,0 +1,102 @@
+package dispatch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+func TestChangedProperties(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    map[string]interface{}
+		refreshed map[string]interface{}
+		want      []string
+	}{
+		{
+			name:      "Unchanged",
+			stored:    map[string]interface{}{"desiredSize": 2.0, "tags": map[string]interface{}{"Owner": "tester"}},
+			refreshed: map[string]interface{}{"desiredSize": 2.0, "tags": map[string]interface{}{"Owner": "tester"}},
+		},
+		{
+			name:      "Modified and removed",
+			stored:    map[string]interface{}{"desiredSize": 2.0, "tags": map[string]interface{}{"Owner": "tester"}},
+			refreshed: map[string]interface{}{"desiredSize": 5.0},
+			want:      []string{"desiredSize", "tags"},
+		},
+		{
+			name:      "Added",
+			stored:    map[string]interface{}{},
+			refreshed: map[string]interface{}{"ingress": []interface{}{"0.0.0.0/0"}},
+			want:      []string{"ingress"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := changedProperties(test.stored, test.refreshed); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("changedProperties unit test failure\n got: '%v', want: '%v'", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseDriftEvents(t *testing.T) {
+	// engine event log of a refresh preview, one JSON event per line
+	events := []byte(`{"sequence":0,"timestamp":1,"preludeEvent":{"config":{}}}
+{"sequence":1,"timestamp":1,"resOutputsEvent":{"metadata":{"op":"refresh","urn":"urn:pulumi:test-eks::tester-dispatch::eks:index:NodeGroup$aws:eks/nodeGroup:NodeGroup::test-ng","type":"aws:eks/nodeGroup:NodeGroup","old":{"type":"aws:eks/nodeGroup:NodeGroup","urn":"","custom":true,"id":"ng","parent":"","provider":"","inputs":{},"outputs":{"scalingConfig":{"desiredSize":2}}},"new":{"type":"aws:eks/nodeGroup:NodeGroup","urn":"","custom":true,"id":"ng","parent":"","provider":"","inputs":{},"outputs":{"scalingConfig":{"desiredSize":5}}},"provider":""},"planning":true}}
+{"sequence":2,"timestamp":1,"resOutputsEvent":{"metadata":{"op":"refresh","urn":"urn:pulumi:test-eks::tester-dispatch::aws:ec2/securityGroup:SecurityGroup::test-sg","type":"aws:ec2/securityGroup:SecurityGroup","old":{"type":"aws:ec2/securityGroup:SecurityGroup","urn":"","custom":true,"id":"sg","parent":"","provider":"","inputs":{},"outputs":{"ingress":[]}},"new":{"type":"aws:ec2/securityGroup:SecurityGroup","urn":"","custom":true,"id":"sg","parent":"","provider":"","inputs":{},"outputs":{"ingress":[]}},"provider":""},"planning":true}}
+{"sequence":3,"timestamp":1,"resOutputsEvent":{"metadata":{"op":"refresh","urn":"urn:pulumi:test-eks::tester-dispatch::aws:ec2/eip:Eip::test-eip","type":"aws:ec2/eip:Eip","old":{"type":"aws:ec2/eip:Eip","urn":"","custom":true,"id":"eip","parent":"","provider":"","inputs":{},"outputs":{}},"new":null,"provider":""},"planning":true}}
+{"sequence":4,"timestamp":1,"resOutputsEvent":{"metadata":{"op":"refresh","urn":"urn:pulumi:test-eks::tester-dispatch::pulumi:providers:aws::default","type":"pulumi:providers:aws","old":{"type":"pulumi:providers:aws","urn":"","custom":true,"id":"p","parent":"","provider":"","inputs":{},"outputs":{"region":"us-east-1"}},"new":{"type":"pulumi:providers:aws","urn":"","custom":true,"id":"p","parent":"","provider":"","inputs":{},"outputs":{"region":"us-west-2"}},"provider":""},"planning":true}}
+{"sequence":5,"timestamp":1,"summaryEvent":{"maybeCorrupt":false,"durationSeconds":1,"resourceChanges":{},"PolicyPacks":{}}}
+`)
+
+	want := []driftedResource{
+		{
+			Name:       "test-ng",
+			Type:       "aws:eks/nodeGroup:NodeGroup",
+			URN:        "urn:pulumi:test-eks::tester-dispatch::eks:index:NodeGroup$aws:eks/nodeGroup:NodeGroup::test-ng",
+			Change:     driftModified,
+			Properties: []string{"scalingConfig"},
+		},
+		{
+			Name:   "test-eip",
+			Type:   "aws:ec2/eip:Eip",
+			URN:    "urn:pulumi:test-eks::tester-dispatch::aws:ec2/eip:Eip::test-eip",
+			Change: driftDeleted,
+		},
+	}
+
+	got, err := parseDriftEvents(events)
+	if err != nil {
+		t.Fatalf("parseDriftEvents unit test failure\n error: '%v'", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDriftEvents unit test failure\n got: '%+v', want: '%+v'", got, want)
+	}
+
+	if _, err := parseDriftEvents([]byte("not json\n")); err == nil {
+		t.Errorf("parseDriftEvents unit test failure\n expected an error for an invalid event log")
+	}
+}
+
+func TestRefreshedChanges(t *testing.T) {
+	changes := map[string]int{"same": 40, "update": 2, "delete": 1}
+
+	tests := []struct {
+		name    string
+		summary auto.UpdateSummary
+		want    int
+	}{
+		{name: "Drift refreshed", summary: auto.UpdateSummary{ResourceChanges: &changes}, want: 3},
+		{name: "No changes reported", summary: auto.UpdateSummary{}, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := refreshedChanges(test.summary); got != test.want {
+				t.Errorf("refreshedChanges unit test failure\n got: '%v', want: '%v'", got, test.want)
+			}
+		})
+	}
+}
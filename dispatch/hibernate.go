@@ -0,0 +1,111 @@
+package dispatch
+
+// cluster sleep and wake by scaling node groups to zero
+
+import "fmt"
+
+const (
+	sleepAction        string = "sleep"
+	wakeAction         string = "wake"
+	natGatewaysKey     string = "dispatch:natGateways"
+	wakeNodeCountKey   string = "dispatch:wakeNodeCount"
+	wakeNATGatewaysKey string = "dispatch:wakeNatGateways"
+	// awsx requires a NAT gateway for private subnets, sleeping clusters keep a single gateway
+	sleepNATStrategy   string = "Single"
+	defaultNATStrategy string = "OnePerAz"
+)
+
+func clusterAsleep(summary stackSummary) bool {
+	return summary.Config[wakeNodeCountKey] != ""
+}
+
+// stack config of a sleeping cluster, the current node count is recorded for wake
+func sleepConfig(stackConfig map[string]string, nat bool) (map[string]string, error) {
+	if stackConfig[wakeNodeCountKey] != "" {
+		return nil, fmt.Errorf("cluster is already asleep")
+	}
+
+	if stackConfig[nodeCountKey] == "" {
+		return nil, fmt.Errorf("cluster node count is not stored with the stack, clusters created by earlier Dispatch versions cannot sleep")
+	}
+
+	values := map[string]string{
+		wakeNodeCountKey: stackConfig[nodeCountKey],
+		nodeCountKey:     "0",
+	}
+
+	if nat {
+		strategy := stackConfig[natGatewaysKey]
+		if strategy == "" {
+			strategy = defaultNATStrategy
+		}
+
+		values[wakeNATGatewaysKey] = strategy
+		values[natGatewaysKey] = sleepNATStrategy
+	}
+
+	return values, nil
+}
+
+// running resources billed while a cluster sleeps, awsx keeps at least one NAT gateway for the private subnets
+func sleepBilling(resources clusterResources) string {
+	gateways := fmt.Sprintf("%d NAT gateways keep", resources.NATGateways)
+	if resources.NATGateways == 1 {
+		gateways = "1 NAT gateway keeps"
+	}
+
+	return fmt.Sprintf("the EKS control plane and %s running and billed while the cluster sleeps", gateways)
+}
+
+// stack config of a woken cluster, empty values are removed from the stack config
+func wakeConfig(stackConfig map[string]string) (map[string]string, error) {
+	if stackConfig[wakeNodeCountKey] == "" {
+		return nil, fmt.Errorf("cluster is not asleep")
+	}
+
+	values := map[string]string{
+		nodeCountKey:     stackConfig[wakeNodeCountKey],
+		wakeNodeCountKey: "",
+	}
+
+	if strategy := stackConfig[wakeNATGatewaysKey]; strategy != "" {
+		values[natGatewaysKey] = strategy
+		values[wakeNATGatewaysKey] = ""
+	}
+
+	return values, nil
+}
+
+// node size, count and version of an existing cluster from its stored spec
+func applyStoredSpec(event *Event, stackConfig map[string]string) {
+	if size := stackConfig[nodeSizeKey]; size != "" {
+		event.Size = size
+	}
+
+	if count := stackConfig[nodeCountKey]; count != "" {
+		event.Count = count
+	}
+
+	if version := stackConfig[versionKey]; version != "" {
+		event.Version = version
+	}
+}
+
+// stack config of a sleep or wake event
+func hibernationConfig(event Event, summary stackSummary) map[string]string {
+	var values map[string]string
+
+	var err error
+
+	if event.Action == sleepAction {
+		values, err = sleepConfig(summary.Config, event.SleepNAT)
+	} else {
+		values, err = wakeConfig(summary.Config)
+	}
+
+	if err != nil {
+		reportErr(err, event.Action+" cluster "+event.Name)
+	}
+
+	return values
+}
@@ -0,0 +1,128 @@
+package dispatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSleepConfig(t *testing.T) {
+	// input stack config and NAT gateway option
+	// return sleep config values and error
+	tests := []struct {
+		name        string
+		stackConfig map[string]string
+		nat         bool
+		want        map[string]string
+		err         bool
+	}{
+		{
+			name:        "Nodes",
+			stackConfig: map[string]string{nodeCountKey: "3"},
+			want:        map[string]string{nodeCountKey: "0", wakeNodeCountKey: "3"},
+		},
+		{
+			name:        "NAT gateways",
+			stackConfig: map[string]string{nodeCountKey: "2"},
+			nat:         true,
+			want: map[string]string{
+				nodeCountKey:       "0",
+				wakeNodeCountKey:   "2",
+				natGatewaysKey:     sleepNATStrategy,
+				wakeNATGatewaysKey: defaultNATStrategy,
+			},
+		},
+		{
+			name:        "Asleep",
+			stackConfig: map[string]string{nodeCountKey: "0", wakeNodeCountKey: "2"},
+			err:         true,
+		},
+		{
+			name:        "No stored spec",
+			stackConfig: map[string]string{},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := sleepConfig(test.stackConfig, test.nat)
+
+			if (err != nil) != test.err || (!test.err && !reflect.DeepEqual(values, test.want)) {
+				t.Errorf("sleepConfig unit test failure\n got: '%v', want: '%v', error: '%v'", values, test.want, err)
+			}
+		})
+	}
+}
+
+func TestWakeConfig(t *testing.T) {
+	// input stack config
+	// return wake config values and error
+	tests := []struct {
+		name        string
+		stackConfig map[string]string
+		want        map[string]string
+		err         bool
+	}{
+		{
+			name:        "Nodes",
+			stackConfig: map[string]string{nodeCountKey: "0", wakeNodeCountKey: "3"},
+			want:        map[string]string{nodeCountKey: "3", wakeNodeCountKey: ""},
+		},
+		{
+			name: "NAT gateways",
+			stackConfig: map[string]string{
+				nodeCountKey:       "0",
+				wakeNodeCountKey:   "2",
+				natGatewaysKey:     sleepNATStrategy,
+				wakeNATGatewaysKey: defaultNATStrategy,
+			},
+			want: map[string]string{
+				nodeCountKey:       "2",
+				wakeNodeCountKey:   "",
+				natGatewaysKey:     defaultNATStrategy,
+				wakeNATGatewaysKey: "",
+			},
+		},
+		{
+			name:        "Awake",
+			stackConfig: map[string]string{nodeCountKey: "2"},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := wakeConfig(test.stackConfig)
+
+			if (err != nil) != test.err || (!test.err && !reflect.DeepEqual(values, test.want)) {
+				t.Errorf("wakeConfig unit test failure\n got: '%v', want: '%v', error: '%v'", values, test.want, err)
+			}
+		})
+	}
+}
+
+func TestApplyStoredSpec(t *testing.T) {
+	event := Event{Size: "small", Count: "2", Version: k8sVersion}
+
+	applyStoredSpec(&event, map[string]string{nodeSizeKey: "large", nodeCountKey: "0"})
+
+	if event.Size != "large" || event.Count != "0" || event.Version != k8sVersion {
+		t.Errorf("applyStoredSpec unit test failure\n got: '%+v'", event)
+	}
+}
+
+func TestSleepBilling(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources clusterResources
+		want      string
+	}{
+		{name: "Single NAT gateway", resources: clusterResources{NATGateways: 1}, want: "the EKS control plane and 1 NAT gateway keeps running and billed while the cluster sleeps"},
+		{name: "NAT gateway per AZ", resources: clusterResources{NATGateways: 3}, want: "the EKS control plane and 3 NAT gateways keep running and billed while the cluster sleeps"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sleepBilling(test.resources); got != test.want {
+				t.Errorf("sleepBilling unit test failure\n got: '%s', want: '%s'", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package dispatch
+
+// IAM policy generation and permission simulation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+const (
+	iamPolicyVersion string = "2012-10-17"
+	stateBucketARN   string = "arn:aws:s3:::*-dispatch-state-store-*"
+	eksAMIParameter  string = "arn:aws:ssm:*::parameter/aws/service/eks/*"
+)
+
+type policyStatement struct {
+	Sid      string   `json:"Sid"`
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+// statements for the AWS API calls made by Dispatch and the Pulumi providers it drives
+func dispatchPolicyStatements() []policyStatement {
+	return []policyStatement{
+		{
+			Sid:    "DispatchIdentity",
+			Effect: "Allow",
+			Action: []string{
+				"sts:GetCallerIdentity",
+				"iam:SimulatePrincipalPolicy",
+				"ec2:DescribeAvailabilityZones",
+				"s3:ListAllMyBuckets",
+			},
+			Resource: []string{"*"},
+		},
+		{
+			Sid:    "DispatchStateStore",
+			Effect: "Allow",
+			Action: []string{
+				"s3:CreateBucket",
+				"s3:GetBucketLocation",
+				"s3:ListBucket",
+				"s3:PutBucketVersioning",
+				"s3:PutEncryptionConfiguration",
+			},
+			Resource: []string{stateBucketARN},
+		},
+		{
+			Sid:    "DispatchStateObjects",
+			Effect: "Allow",
+			Action: []string{
+				"s3:DeleteObject",
+				"s3:GetObject",
+				"s3:PutObject",
+			},
+			Resource: []string{stateBucketARN + "/*"},
+		},
+		{
+			Sid:    "DispatchNetwork",
+			Effect: "Allow",
+			Action: []string{
+				"ec2:AllocateAddress",
+				"ec2:AssociateRouteTable",
+				"ec2:AttachInternetGateway",
+				"ec2:AuthorizeSecurityGroupEgress",
+				"ec2:AuthorizeSecurityGroupIngress",
+				"ec2:CreateInternetGateway",
+				"ec2:CreateNatGateway",
+				"ec2:CreateRoute",
+				"ec2:CreateRouteTable",
+				"ec2:CreateSecurityGroup",
+				"ec2:CreateSubnet",
+				"ec2:CreateTags",
+				"ec2:CreateVpc",
+				"ec2:DeleteInternetGateway",
+				"ec2:DeleteNatGateway",
+				"ec2:DeleteRoute",
+				"ec2:DeleteRouteTable",
+				"ec2:DeleteSecurityGroup",
+				"ec2:DeleteSubnet",
+				"ec2:DeleteTags",
+				"ec2:DeleteVpc",
+				"ec2:Describe*",
+				"ec2:DetachInternetGateway",
+				"ec2:DisassociateAddress",
+				"ec2:DisassociateRouteTable",
+				"ec2:ModifySubnetAttribute",
+				"ec2:ModifyVpcAttribute",
+				"ec2:ReleaseAddress",
+				"ec2:RevokeSecurityGroupEgress",
+				"ec2:RevokeSecurityGroupIngress",
+			},
+			Resource: []string{"*"},
+		},
+		{
+			Sid:    "DispatchCluster",
+			Effect: "Allow",
+			Action: []string{
+				"eks:CreateCluster",
+				"eks:DeleteCluster",
+				"eks:DeleteNodegroup",
+				"eks:DescribeCluster",
+				"eks:DescribeNodegroup",
+				"eks:DescribeUpdate",
+				"eks:ListClusters",
+				"eks:ListNodegroups",
+				"eks:TagResource",
+				"eks:UntagResource",
+				"eks:UpdateClusterConfig",
+				"eks:UpdateClusterVersion",
+				"eks:UpdateNodegroupConfig",
+			},
+			Resource: []string{"*"},
+		},
+		{
+			Sid:    "DispatchNodeGroups",
+			Effect: "Allow",
+			Action: []string{
+				"autoscaling:CreateAutoScalingGroup",
+				"autoscaling:CreateLaunchConfiguration",
+				"autoscaling:CreateOrUpdateTags",
+				"autoscaling:DeleteAutoScalingGroup",
+				"autoscaling:DeleteLaunchConfiguration",
+				"autoscaling:DeleteTags",
+				"autoscaling:Describe*",
+				"autoscaling:SetDesiredCapacity",
+				"autoscaling:UpdateAutoScalingGroup",
+				"cloudformation:CreateStack",
+				"cloudformation:DeleteStack",
+				"cloudformation:DescribeStackEvents",
+				"cloudformation:DescribeStackResources",
+				"cloudformation:DescribeStacks",
+				"cloudformation:GetTemplate",
+				"cloudformation:UpdateStack",
+				"ec2:CreateLaunchTemplate",
+				"ec2:CreateLaunchTemplateVersion",
+				"ec2:DeleteLaunchTemplate",
+				"ec2:ModifyLaunchTemplate",
+				"ec2:RunInstances",
+				"ec2:TerminateInstances",
+			},
+			Resource: []string{"*"},
+		},
+		{
+			Sid:    "DispatchNodeImages",
+			Effect: "Allow",
+			Action: []string{
+				"ssm:GetParameter",
+			},
+			Resource: []string{eksAMIParameter},
+		},
+		{
+			Sid:    "DispatchOrphans",
+			Effect: "Allow",
+			Action: []string{
+				"tag:GetResources",
+				"iam:ListRoles",
+				"iam:ListRoleTags",
+				"ec2:DeleteNetworkInterface",
+				"elasticloadbalancing:DeleteLoadBalancer",
+				"elasticloadbalancing:DescribeLoadBalancers",
+			},
+			Resource: []string{"*"},
+		},
+		{
+			Sid:    "DispatchControlPlane",
+			Effect: "Allow",
+			Action: []string{
+				"kms:CreateGrant",
+				"kms:CreateKey",
+				"kms:DescribeKey",
+				"kms:EnableKeyRotation",
+				"kms:GetKeyPolicy",
+				"kms:GetKeyRotationStatus",
+				"kms:ListResourceTags",
+				"kms:ScheduleKeyDeletion",
+				"kms:TagResource",
+				"logs:CreateLogGroup",
+				"logs:DeleteLogGroup",
+				"logs:DescribeLogGroups",
+				"logs:ListTagsForResource",
+				"logs:ListTagsLogGroup",
+				"logs:PutRetentionPolicy",
+				"logs:TagLogGroup",
+				"logs:TagResource",
+			},
+			Resource: []string{"*"},
+		},
+		{
+			Sid:    "DispatchDNSZones",
+			Effect: "Allow",
+			Action: []string{
+				"route53:ListHostedZonesByName",
+			},
+			Resource: []string{"*"},
+		},
+		{
+			Sid:    "DispatchIAMRoles",
+			Effect: "Allow",
+			Action: []string{
+				"iam:AddRoleToInstanceProfile",
+				"iam:AttachRolePolicy",
+				"iam:CreateInstanceProfile",
+				"iam:CreateOpenIDConnectProvider",
+				"iam:CreateRole",
+				"iam:CreateServiceLinkedRole",
+				"iam:DeleteInstanceProfile",
+				"iam:DeleteOpenIDConnectProvider",
+				"iam:DeleteRole",
+				"iam:DeleteRolePolicy",
+				"iam:DetachRolePolicy",
+				"iam:GetInstanceProfile",
+				"iam:GetOpenIDConnectProvider",
+				"iam:GetRole",
+				"iam:GetRolePolicy",
+				"iam:ListAttachedRolePolicies",
+				"iam:ListInstanceProfilesForRole",
+				"iam:ListOpenIDConnectProviders",
+				"iam:ListRolePolicies",
+				"iam:PassRole",
+				"iam:PutRolePolicy",
+				"iam:RemoveRoleFromInstanceProfile",
+				"iam:TagOpenIDConnectProvider",
+				"iam:TagRole",
+				"iam:UntagRole",
+				"iam:UpdateAssumeRolePolicy",
+			},
+			Resource: []string{"*"},
+		},
+	}
+}
+
+// minimal IAM policy document for Dispatch operations
+func dispatchPolicyJSON() string {
+	policy := policyDocument{
+		Version:   iamPolicyVersion,
+		Statement: dispatchPolicyStatements(),
+	}
+
+	policyJSON, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		reportErr(err, "create Dispatch IAM policy")
+	}
+
+	return string(policyJSON)
+}
+
+// statement actions which can be evaluated by the IAM policy simulator
+func simulatedActions(statement policyStatement) []string {
+	var actions []string
+
+	for _, action := range statement.Action {
+		if !strings.Contains(action, "*") {
+			actions = append(actions, action)
+		}
+	}
+
+	return actions
+}
+
+// concrete ARNs of a statement's resource patterns, the policy simulator matches resource ARNs literally
+// so grants scoped to the caller's state store bucket would not match the wildcard pattern
+func simulationResources(resources []string, bucket string, region string) []string {
+	var arns []string
+
+	for _, resource := range resources {
+		switch resource {
+		case stateBucketARN:
+			resource = "arn:aws:s3:::" + bucket
+		case stateBucketARN + "/*":
+			resource = "arn:aws:s3:::" + bucket + "/" + stackKey("dispatch")
+		case eksAMIParameter:
+			resource = "arn:aws:ssm:" + region + "::parameter/aws/service/eks/optimized-ami/" + k8sVersion + "/amazon-linux-2/recommended/image_id"
+		}
+
+		arns = append(arns, resource)
+	}
+
+	return arns
+}
+
+// simulations of each Dispatch policy statement with its own resources, so grants scoped to Dispatch resources are evaluated
+func simulationInputs(principal string, bucket string, region string) []*iam.SimulatePrincipalPolicyInput {
+	var inputs []*iam.SimulatePrincipalPolicyInput
+
+	for _, statement := range dispatchPolicyStatements() {
+		actions := simulatedActions(statement)
+		if len(actions) == 0 {
+			continue
+		}
+
+		inputs = append(inputs, &iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(principal),
+			ActionNames:     actions,
+			ResourceArns:    simulationResources(statement.Resource, bucket, region),
+		})
+	}
+
+	return inputs
+}
+
+// convert an STS caller ARN to the IAM principal ARN accepted by the policy simulator
+// assumed roles are looked up for their path (e.g. /aws-reserved/sso.amazonaws.com/), which session ARNs omit
+func principalARN(callerARN string, roleARN func(name string) (string, error)) string {
+	// arn:aws:sts::<account>:assumed-role/<role>/<session>
+	arnParts := strings.SplitN(callerARN, ":", 6)
+	if len(arnParts) < 6 || arnParts[2] != "sts" {
+		return callerARN
+	}
+
+	resource := strings.Split(arnParts[5], "/")
+	if resource[0] != "assumed-role" || len(resource) < 3 {
+		return callerARN
+	}
+
+	if arn, err := roleARN(resource[1]); err == nil && arn != "" {
+		return arn
+	}
+
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", arnParts[1], arnParts[4], resource[1])
+}
+
+// ARN of an IAM role including its path
+func getRoleARN(sess *awsSession, name string) (string, error) {
+	ctx, cancel := sess.requestContext()
+	defer cancel()
+
+	resp, err := sess.iam().GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(name)})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(resp.Role.Arn), nil
+}
+
+// report Dispatch actions the caller is not allowed to perform
+func simulateDispatchPermissions(sess *awsSession, callerARN string, bucket string) []string {
+	var denied []string
+
+	principal := principalARN(callerARN, func(name string) (string, error) {
+		return getRoleARN(sess, name)
+	})
+
+	for _, input := range simulationInputs(principal, bucket, sess.config.Region) {
+		paginator := iam.NewSimulatePrincipalPolicyPaginator(sess.iam(), input)
+
+		for paginator.HasMorePages() {
+			ctx, cancel := sess.requestContext()
+			page, err := paginator.NextPage(ctx)
+
+			cancel()
+
+			if err != nil {
+				fmt.Printf(" ! Unable to simulate IAM permissions: %v\n", err)
+
+				return nil
+			}
+
+			for _, result := range page.EvaluationResults {
+				if result.EvalDecision != iamtypes.PolicyEvaluationDecisionTypeAllowed {
+					denied = append(denied, *result.EvalActionName)
+				}
+			}
+		}
+	}
+
+	return denied
+}
+
+func printPermissionGaps(denied []string) {
+	if len(denied) == 0 {
+		return
+	}
+
+	fmt.Printf(" ! AWS credentials are missing %d permissions required by Dispatch:\n", len(denied))
+
+	for _, action := range denied {
+		fmt.Printf("\t - %s\n", action)
+	}
+
+	fmt.Print(" ! Run 'dispatch policy' for the required IAM policy document\n")
+}
@@ -0,0 +1,135 @@
+package dispatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrincipalARN(t *testing.T) {
+	// input STS caller ARN
+	// return IAM principal ARN, roles keep the path returned by IAM
+	roles := map[string]string{
+		"AWSReservedSSO_Admin_1a2b": "arn:aws:iam::123456789012:role/aws-reserved/sso.amazonaws.com/AWSReservedSSO_Admin_1a2b",
+	}
+
+	roleARN := func(name string) (string, error) {
+		if arn, found := roles[name]; found {
+			return arn, nil
+		}
+
+		return "", errors.New("AccessDenied: iam:GetRole")
+	}
+
+	tests := []struct {
+		expectedReturn string
+		name           string
+		input          string
+	}{
+		{
+			name:           "IAM user",
+			input:          "arn:aws:iam::123456789012:user/dev",
+			expectedReturn: "arn:aws:iam::123456789012:user/dev",
+		},
+		{
+			name:           "Assumed role",
+			input:          "arn:aws:sts::123456789012:assumed-role/developer/session-1",
+			expectedReturn: "arn:aws:iam::123456789012:role/developer",
+		},
+		{
+			name:           "SSO role path",
+			input:          "arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_Admin_1a2b/alice@example.com",
+			expectedReturn: "arn:aws:iam::123456789012:role/aws-reserved/sso.amazonaws.com/AWSReservedSSO_Admin_1a2b",
+		},
+		{
+			name:           "GovCloud assumed role",
+			input:          "arn:aws-us-gov:sts::123456789012:assumed-role/developer/session-1",
+			expectedReturn: "arn:aws-us-gov:iam::123456789012:role/developer",
+		},
+		{
+			name:           "Federated user",
+			input:          "arn:aws:sts::123456789012:federated-user/dev",
+			expectedReturn: "arn:aws:sts::123456789012:federated-user/dev",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			arn := principalARN(test.input, roleARN)
+
+			if arn != test.expectedReturn {
+				t.Errorf("principalARN unit test failure\n got: '%v', want: '%v'", arn, test.expectedReturn)
+			}
+		})
+	}
+}
+
+func TestSimulationInputs(t *testing.T) {
+	principal := "arn:aws:iam::123456789012:role/developer"
+	bucket := stateBucketName("alice", "123456789012")
+	inputs := simulationInputs(principal, bucket, "eu-west-1")
+
+	resources := map[string][]string{}
+
+	for _, input := range inputs {
+		if *input.PolicySourceArn != principal || len(input.ActionNames) == 0 || len(input.ResourceArns) == 0 {
+			t.Errorf("simulationInputs unit test failure\n got: '%+v'", input)
+		}
+
+		for _, action := range input.ActionNames {
+			if strings.Contains(action, "*") {
+				t.Errorf("simulationInputs unit test failure\n wildcard action '%v' can not be simulated", action)
+			}
+
+			resources[action] = input.ResourceArns
+		}
+	}
+
+	// actions are simulated against the concrete resources of their own statement
+	want := map[string][]string{
+		"s3:ListBucket":    {"arn:aws:s3:::alice-dispatch-state-store-123456789012"},
+		"s3:PutObject":     {"arn:aws:s3:::alice-dispatch-state-store-123456789012/" + stackKey("dispatch")},
+		"ssm:GetParameter": {"arn:aws:ssm:eu-west-1::parameter/aws/service/eks/optimized-ami/" + k8sVersion + "/amazon-linux-2/recommended/image_id"},
+		"ec2:CreateVpc":    {"*"},
+	}
+
+	for action, arns := range want {
+		if !reflect.DeepEqual(resources[action], arns) {
+			t.Errorf("simulationInputs unit test failure\n got %s resources: '%v', want: '%v'", action, resources[action], arns)
+		}
+	}
+
+	simulated := map[string]bool{}
+
+	for _, statement := range dispatchPolicyStatements() {
+		for _, action := range simulatedActions(statement) {
+			simulated[action] = true
+		}
+	}
+
+	if len(resources) != len(simulated) {
+		t.Errorf("simulationInputs unit test failure\n got %d simulated actions, want: %d", len(resources), len(simulated))
+	}
+}
+
+func TestDispatchPolicyJSON(t *testing.T) {
+	var policy policyDocument
+
+	err := json.Unmarshal([]byte(dispatchPolicyJSON()), &policy)
+	if err != nil {
+		t.Fatalf("dispatchPolicyJSON unit test failure\n invalid JSON: %v", err)
+	}
+
+	if policy.Version != iamPolicyVersion {
+		t.Errorf("dispatchPolicyJSON unit test failure\n got: '%v', want: '%v'", policy.Version, iamPolicyVersion)
+	}
+
+	for _, statement := range policy.Statement {
+		for _, action := range statement.Action {
+			if strings.HasSuffix(action, ":*") {
+				t.Errorf("dispatchPolicyJSON unit test failure\n service wildcard '%v' in statement %s", action, statement.Sid)
+			}
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package dispatch
+
+// IAM roles for Kubernetes service accounts (IRSA) trusted through the cluster OIDC provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	irsaAction                string = "irsa"
+	irsaAdd                   string = "add"
+	irsaRemove                string = "remove"
+	serviceAccountsKey        string = "dispatch:serviceAccounts"
+	irsaNameSeedKey           string = "dispatch:irsaNameSeed"
+	irsaRolesOutput           string = "irsa-role-arns"
+	certManagerRoleOutput     string = "cert-manager-role-arn"
+	certManagerServiceAccount string = "cert-manager/cert-manager"
+	maxRoleNameLength         int    = 64
+	maxManagedPolicies        int    = 10
+)
+
+var serviceAccountName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?/[a-z0-9]([-.a-z0-9]*[a-z0-9])?$`)
+
+var managedPolicyARN = regexp.MustCompile(`^arn:aws[a-z-]*:iam::(aws|\d{12}):policy/[\w+=,.@/-]+$`)
+
+// service account granted an IAM role with managed policies and an optional inline policy document
+type serviceAccountRole struct {
+	ServiceAccount string   `yaml:"service_account" json:"serviceAccount"`
+	PolicyARNs     []string `yaml:"policy_arns,omitempty" json:"policyArns,omitempty"`
+	Policy         string   `yaml:"policy,omitempty" json:"policy,omitempty"`
+}
+
+// IRSA spec file
+type irsaSpec struct {
+	ServiceAccounts []serviceAccountRole `yaml:"service_accounts"`
+	CertManager     *certManagerSpec     `yaml:"cert_manager,omitempty"`
+}
+
+// repeatable string flag
+type repeatedFlag []string
+
+func (r *repeatedFlag) String() string {
+	return strings.Join(*r, ",")
+}
+
+func (r *repeatedFlag) Set(value string) error {
+	*r = append(*r, value)
+
+	return nil
+}
+
+func loadIRSASpec(file string) (irsaSpec, error) {
+	var spec irsaSpec
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return spec, err
+	}
+
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return spec, fmt.Errorf("parse IRSA spec %s: %w", file, err)
+	}
+
+	if len(spec.ServiceAccounts) == 0 && spec.CertManager == nil {
+		return spec, fmt.Errorf("IRSA spec %s declares no service_accounts", file)
+	}
+
+	return spec, nil
+}
+
+func validateServiceAccountRoles(roles []serviceAccountRole) error {
+	for _, role := range roles {
+		if !serviceAccountName.MatchString(role.ServiceAccount) {
+			return fmt.Errorf("invalid service account %s, use namespace/name (e.g. kube-system/ebs-csi-controller-sa)", role.ServiceAccount)
+		}
+
+		if role.ServiceAccount == certManagerServiceAccount {
+			return fmt.Errorf("the %s role is managed by Dispatch", certManagerServiceAccount)
+		}
+
+		if len(role.PolicyARNs) == 0 && role.Policy == "" {
+			return fmt.Errorf("service account %s has no managed policy ARNs or inline policy", role.ServiceAccount)
+		}
+
+		if len(role.PolicyARNs) > maxManagedPolicies {
+			return fmt.Errorf("service account %s has %d managed policies, IAM roles allow %d", role.ServiceAccount, len(role.PolicyARNs), maxManagedPolicies)
+		}
+
+		for _, arn := range role.PolicyARNs {
+			if !managedPolicyARN.MatchString(arn) {
+				return fmt.Errorf("invalid managed policy ARN %s of service account %s", arn, role.ServiceAccount)
+			}
+		}
+
+		if role.Policy != "" {
+			var document map[string]interface{}
+
+			if err := json.Unmarshal([]byte(role.Policy), &document); err != nil || document["Statement"] == nil {
+				return fmt.Errorf("inline policy of service account %s is not an IAM policy document with statements", role.ServiceAccount)
+			}
+		}
+	}
+
+	return nil
+}
+
+// later roles of a service account replace earlier ones, sorted by service account
+func mergeServiceAccountRoles(roleSets ...[]serviceAccountRole) []serviceAccountRole {
+	merged := map[string]serviceAccountRole{}
+
+	for _, roles := range roleSets {
+		for _, role := range roles {
+			merged[role.ServiceAccount] = role
+		}
+	}
+
+	var sorted []serviceAccountRole
+
+	for _, role := range merged {
+		sorted = append(sorted, role)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ServiceAccount < sorted[j].ServiceAccount })
+
+	return sorted
+}
+
+func removeServiceAccountRole(roles []serviceAccountRole, serviceAccount string) ([]serviceAccountRole, error) {
+	var remaining []serviceAccountRole
+
+	for _, role := range roles {
+		if role.ServiceAccount != serviceAccount {
+			remaining = append(remaining, role)
+		}
+	}
+
+	if len(remaining) == len(roles) {
+		return nil, fmt.Errorf("service account %s has no IRSA role", serviceAccount)
+	}
+
+	return remaining, nil
+}
+
+// service account roles stored with the stack config
+func storedServiceAccountRoles(stackConfig map[string]string) []serviceAccountRole {
+	var roles []serviceAccountRole
+
+	if stackConfig[serviceAccountsKey] == "" {
+		return roles
+	}
+
+	if err := json.Unmarshal([]byte(stackConfig[serviceAccountsKey]), &roles); err != nil {
+		reportErr(err, "read stored service account roles")
+	}
+
+	return roles
+}
+
+// service account roles of a cluster after an irsa add or remove event
+func changedServiceAccountRoles(event Event, stored []serviceAccountRole) ([]serviceAccountRole, error) {
+	if event.Subcommand == irsaRemove {
+		return removeServiceAccountRole(stored, event.ServiceAccount)
+	}
+
+	return mergeServiceAccountRoles(stored, event.ServiceAccounts), nil
+}
+
+// stack config of service account roles, no roles remove the stored value
+func serviceAccountsConfig(roles []serviceAccountRole) map[string]string {
+	if len(roles) == 0 {
+		return map[string]string{serviceAccountsKey: ""}
+	}
+
+	value, err := json.Marshal(roles)
+	if err != nil {
+		reportErr(err, "create service account roles")
+	}
+
+	return map[string]string{serviceAccountsKey: string(value)}
+}
+
+// fixed IAM role name of a service account, IAM role names are limited to 64 characters
+func irsaRoleName(eksID string, seed string, serviceAccount string) string {
+	namespace, name, _ := strings.Cut(serviceAccount, "/")
+
+	return hashedName(eksID+"-"+namespace+"-"+name, seed+"/"+eksID+"/"+serviceAccount, maxRoleNameLength)
+}
+
+// resource name of a role policy attachment, policies of different accounts or paths can share a base name
+// so the name carries a hash of the full policy ARN
+func policyAttachmentName(resourceName string, arn string) string {
+	return hashedName(resourceName+"-"+path.Base(arn), arn, math.MaxInt)
+}
+
+// role names are hashed from the user who created the cluster, the stored seed keeps role ARNs
+// of service account annotations across updates and imports by other users
+func irsaNameConfig(user string, stackConfig map[string]string) map[string]string {
+	if stackConfig[irsaNameSeedKey] != "" {
+		return nil
+	}
+
+	return map[string]string{irsaNameSeedKey: user}
+}
+
+// IAM role assumable by a single Kubernetes service account through the cluster OIDC provider
+func newServiceAccountRole(ctx *pulumi.Context, resourceName string, args *iam.RoleArgs, serviceAccount string, oidcARN pulumi.StringOutput, oidcURL pulumi.StringOutput) (*iam.Role, error) {
+	namespace, name, _ := strings.Cut(serviceAccount, "/")
+
+	trustPolicy := iam.GetPolicyDocumentOutput(ctx, iam.GetPolicyDocumentOutputArgs{
+		Statements: iam.GetPolicyDocumentStatementArray{
+			iam.GetPolicyDocumentStatementArgs{
+				Effect: pulumi.String("Allow"),
+				Principals: iam.GetPolicyDocumentStatementPrincipalArray{
+					iam.GetPolicyDocumentStatementPrincipalArgs{
+						Type:        pulumi.String("Federated"),
+						Identifiers: pulumi.StringArray{oidcARN},
+					},
+				},
+				Actions: pulumi.ToStringArray([]string{"sts:AssumeRoleWithWebIdentity"}),
+				Conditions: iam.GetPolicyDocumentStatementConditionArray{
+					iam.GetPolicyDocumentStatementConditionArgs{
+						Test:     pulumi.String("StringEquals"),
+						Variable: pulumi.Sprintf("%s:sub", oidcURL),
+						Values:   pulumi.ToStringArray([]string{"system:serviceaccount:" + namespace + ":" + name}),
+					},
+					iam.GetPolicyDocumentStatementConditionArgs{
+						Test:     pulumi.String("StringEquals"),
+						Variable: pulumi.Sprintf("%s:aud", oidcURL),
+						Values:   pulumi.ToStringArray([]string{"sts.amazonaws.com"}),
+					},
+				},
+			},
+		},
+	})
+
+	args.AssumeRolePolicy = trustPolicy.Json()
+
+	return iam.NewRole(ctx, resourceName, args)
+}
+
+// roles of user declared service accounts, returns role ARNs by service account
+func deployServiceAccountRoles(ctx *pulumi.Context, eksID string, seed string, roles []serviceAccountRole, oidcARN pulumi.StringOutput, oidcURL pulumi.StringOutput, tags pulumi.StringMap) (pulumi.StringMap, error) {
+	arns := pulumi.StringMap{}
+
+	if seed == "" && len(roles) > 0 {
+		return nil, fmt.Errorf("no service account role name seed stored for cluster %s", eksID)
+	}
+
+	for _, declared := range roles {
+		resourceName := eksID + "-irsa-" + strings.ReplaceAll(declared.ServiceAccount, "/", "-")
+
+		role, err := newServiceAccountRole(ctx, resourceName, &iam.RoleArgs{
+			Name: pulumi.String(irsaRoleName(eksID, seed, declared.ServiceAccount)),
+			Tags: tags,
+		}, declared.ServiceAccount, oidcARN, oidcURL)
+		if err != nil {
+			return nil, err
+		}
+
+		baseNames := map[string]int{}
+
+		for _, arn := range declared.PolicyARNs {
+			baseNames[path.Base(arn)]++
+		}
+
+		for _, arn := range declared.PolicyARNs {
+			var opts []pulumi.ResourceOption
+
+			// attachments named by policy base name before the ARN hash was added are kept rather than replaced
+			if baseNames[path.Base(arn)] == 1 {
+				opts = append(opts, pulumi.Aliases([]pulumi.Alias{{Name: pulumi.String(resourceName + "-" + path.Base(arn))}}))
+			}
+
+			_, err := iam.NewRolePolicyAttachment(ctx, policyAttachmentName(resourceName, arn), &iam.RolePolicyAttachmentArgs{
+				Role:      role.Name,
+				PolicyArn: pulumi.String(arn),
+			}, opts...)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if declared.Policy != "" {
+			_, err := iam.NewRolePolicy(ctx, resourceName+"-inline", &iam.RolePolicyArgs{
+				Role:   role.Name,
+				Policy: pulumi.String(declared.Policy),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		arns[declared.ServiceAccount] = role.Arn
+	}
+
+	return arns, nil
+}
+
+// role ARNs by service account exported by the cluster stack, earlier stacks only export the cert-manager role
+func irsaRoleARNs(summary stackSummary) map[string]string {
+	arns := map[string]string{}
+
+	if exported, found := summary.Outputs[irsaRolesOutput].(map[string]interface{}); found {
+		for serviceAccount, arn := range exported {
+			arns[serviceAccount], _ = arn.(string)
+		}
+	}
+
+	if arn, found := summary.Outputs[certManagerRoleOutput].(string); found {
+		arns[certManagerServiceAccount] = arn
+	}
+
+	return arns
+}
+
+// role ARNs by service account of a stack update's outputs
+func updatedRoleARNs(outputs auto.OutputMap) map[string]string {
+	exported := map[string]interface{}{}
+
+	for key, output := range outputs {
+		exported[key] = output.Value
+	}
+
+	return irsaRoleARNs(stackSummary{Outputs: exported})
+}
+
+// declared service account roles with the cert-manager role when the cluster has one
+func clusterServiceAccountRoles(roles []serviceAccountRole, arns map[string]string) []serviceAccountRole {
+	if arns[certManagerServiceAccount] == "" {
+		return roles
+	}
+
+	return append([]serviceAccountRole{{ServiceAccount: certManagerServiceAccount}}, roles...)
+}
+
+func printServiceAccountRoles(roles []serviceAccountRole, arns map[string]string) {
+	for _, role := range roles {
+		var policies []string
+
+		for _, arn := range role.PolicyARNs {
+			policies = append(policies, path.Base(arn))
+		}
+
+		if role.Policy != "" {
+			policies = append(policies, "inline policy")
+		}
+
+		if len(policies) > 0 {
+			fmt.Printf("\t <> %s: %s\n", role.ServiceAccount, strings.Join(policies, ", "))
+		} else {
+			fmt.Printf("\t <> %s\n", role.ServiceAccount)
+		}
+
+		if arn := arns[role.ServiceAccount]; arn != "" {
+			fmt.Printf("\t    %s\n", arn)
+		}
+	}
+}
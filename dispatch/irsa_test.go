@@ -0,0 +1,204 @@
+package dispatch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateServiceAccountRoles(t *testing.T) {
+	ebsPolicy := "arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy"
+	inline := `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`
+
+	tests := []struct {
+		name string
+		role serviceAccountRole
+		err  bool
+	}{
+		{name: "Managed policy", role: serviceAccountRole{ServiceAccount: "kube-system/ebs-csi-controller-sa", PolicyARNs: []string{ebsPolicy}}},
+		{name: "Inline policy", role: serviceAccountRole{ServiceAccount: "apps/billing", Policy: inline}},
+		{name: "Missing namespace", role: serviceAccountRole{ServiceAccount: "billing", Policy: inline}, err: true},
+		{name: "No policies", role: serviceAccountRole{ServiceAccount: "apps/billing"}, err: true},
+		{name: "Invalid policy ARN", role: serviceAccountRole{ServiceAccount: "apps/billing", PolicyARNs: []string{"AmazonS3ReadOnlyAccess"}}, err: true},
+		{name: "Invalid inline policy", role: serviceAccountRole{ServiceAccount: "apps/billing", Policy: `{"Version": "2012-10-17"}`}, err: true},
+		{name: "cert-manager", role: serviceAccountRole{ServiceAccount: certManagerServiceAccount, PolicyARNs: []string{ebsPolicy}}, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateServiceAccountRoles([]serviceAccountRole{test.role}); (err != nil) != test.err {
+				t.Errorf("validateServiceAccountRoles unit test failure\n got: '%v', want error: '%v'", err, test.err)
+			}
+		})
+	}
+}
+
+func TestChangedServiceAccountRoles(t *testing.T) {
+	stored := []serviceAccountRole{
+		{ServiceAccount: "kube-system/external-dns", PolicyARNs: []string{"arn:aws:iam::123456789012:policy/external-dns"}},
+	}
+
+	karpenter := serviceAccountRole{ServiceAccount: "karpenter/karpenter", PolicyARNs: []string{"arn:aws:iam::123456789012:policy/karpenter"}}
+
+	added, err := changedServiceAccountRoles(Event{Subcommand: irsaAdd, ServiceAccounts: []serviceAccountRole{karpenter}}, stored)
+	if want := []serviceAccountRole{karpenter, stored[0]}; err != nil || !reflect.DeepEqual(added, want) {
+		t.Errorf("changedServiceAccountRoles unit test failure\n got: '%v', want: '%v'", added, want)
+	}
+
+	removed, err := changedServiceAccountRoles(Event{Subcommand: irsaRemove, ServiceAccount: "kube-system/external-dns"}, stored)
+	if err != nil || len(removed) != 0 {
+		t.Errorf("changedServiceAccountRoles unit test failure\n got: '%v', error: '%v'", removed, err)
+	}
+
+	if _, err := changedServiceAccountRoles(Event{Subcommand: irsaRemove, ServiceAccount: "apps/billing"}, stored); err == nil {
+		t.Errorf("changedServiceAccountRoles unit test failure\n removing an unknown service account must fail")
+	}
+}
+
+func TestIRSARoleName(t *testing.T) {
+	name := irsaRoleName("demo", "alice", "kube-system/ebs-csi-controller-sa")
+
+	if !strings.HasPrefix(name, "demo-kube-system-ebs-csi-controller-sa-") {
+		t.Errorf("irsaRoleName unit test failure\n got: '%s'", name)
+	}
+
+	if long := irsaRoleName(strings.Repeat("c", 40), "alice", "kube-system/ebs-csi-controller-sa"); len(long) != maxRoleNameLength {
+		t.Errorf("irsaRoleName unit test failure\n got length: '%d', want: '%d'", len(long), maxRoleNameLength)
+	}
+}
+
+func TestIRSARoleARNs(t *testing.T) {
+	summary := stackSummary{Outputs: map[string]interface{}{
+		certManagerRoleOutput: "arn:aws:iam::123456789012:role/cm",
+		irsaRolesOutput: map[string]interface{}{
+			"kube-system/external-dns": "arn:aws:iam::123456789012:role/dns",
+		},
+	}}
+
+	want := map[string]string{
+		certManagerServiceAccount:  "arn:aws:iam::123456789012:role/cm",
+		"kube-system/external-dns": "arn:aws:iam::123456789012:role/dns",
+	}
+
+	if got := irsaRoleARNs(summary); !reflect.DeepEqual(got, want) {
+		t.Errorf("irsaRoleARNs unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+}
+
+func TestLoadIRSASpec(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "irsa.yaml")
+
+	data := `cert_manager:
+  dns_zone: example.com
+service_accounts:
+  - service_account: kube-system/ebs-csi-controller-sa
+    policy_arns:
+      - arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy
+  - service_account: apps/billing
+    policy: |
+      {"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}
+`
+
+	if err := os.WriteFile(spec, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadIRSASpec(spec)
+	if err != nil || len(loaded.ServiceAccounts) != 2 {
+		t.Fatalf("loadIRSASpec unit test failure\n got: '%v', error: '%v'", loaded, err)
+	}
+
+	if loaded.CertManager == nil || loaded.CertManager.DNSZone != "example.com" || loaded.CertManager.Disabled {
+		t.Errorf("loadIRSASpec unit test failure\n got cert_manager: '%v', want: dns_zone example.com", loaded.CertManager)
+	}
+
+	if err := validateServiceAccountRoles(loaded.ServiceAccounts); err != nil {
+		t.Errorf("loadIRSASpec unit test failure\n spec roles are invalid: '%v'", err)
+	}
+}
+
+func TestIRSAUpdateProgram(t *testing.T) {
+	ebsPolicy := "arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy"
+	stackConfig := map[string]string{nodeSizeKey: "small", nodeCountKey: "0", versionKey: k8sVersion, irsaNameSeedKey: "alice"}
+
+	// service account role updates carry no node spec of their own, sleeping clusters stay at zero nodes
+	event := Event{Name: "my-cluster", User: "alice", Action: irsaAction, Subcommand: irsaAdd, ServiceAccount: "kube-system/ebs-csi-controller-sa"}
+	event.ServiceAccounts = []serviceAccountRole{{ServiceAccount: event.ServiceAccount, PolicyARNs: []string{ebsPolicy}}}
+
+	roles, err := changedServiceAccountRoles(event, storedServiceAccountRoles(stackConfig))
+	if err != nil {
+		t.Fatalf("irsa update program test failure\n error: '%v'", err)
+	}
+
+	applyStoredSpec(&event, mergeStackConfig(stackConfig, serviceAccountsConfig(roles)))
+
+	resources := runClusterProgram(t, event, clusterSettings{serviceAccounts: roles, irsaNameSeed: stackConfig[irsaNameSeedKey]})
+
+	nodes, found := resources["eks:index:NodeGroupV2::my-cluster-nodes"]
+	if !found {
+		t.Fatalf("irsa update program test failure\n no node group in: '%v'", resources)
+	}
+
+	if size := nodes["desiredCapacity"]; !size.IsNumber() || size.NumberValue() != 0 {
+		t.Errorf("irsa update program test failure\n got desired capacity: '%v', want the stored node count 0", size)
+	}
+
+	role, found := resources["aws:iam/role:Role::my-cluster-irsa-kube-system-ebs-csi-controller-sa"]
+	if !found {
+		t.Fatalf("irsa update program test failure\n no service account role in: '%v'", resources)
+	}
+
+	if name := role["name"]; !name.IsString() || name.StringValue() != irsaRoleName("my-cluster", "alice", event.ServiceAccount) {
+		t.Errorf("irsa update program test failure\n got role name: '%v'", name)
+	}
+
+	attachment := resources["aws:iam/rolePolicyAttachment:RolePolicyAttachment::"+policyAttachmentName("my-cluster-irsa-kube-system-ebs-csi-controller-sa", ebsPolicy)]
+	if arn := attachment["policyArn"]; !arn.IsString() || arn.StringValue() != ebsPolicy {
+		t.Errorf("irsa update program test failure\n got policy attachment: '%v', want: '%v'", attachment, ebsPolicy)
+	}
+}
+
+// policies sharing a base name are attached as separate resources
+func TestIRSAPolicyAttachments(t *testing.T) {
+	policies := []string{"arn:aws:iam::aws:policy/ReadOnlyAccess", "arn:aws:iam::111111111111:policy/team/ReadOnlyAccess"}
+	roles := []serviceAccountRole{{ServiceAccount: "default/app", PolicyARNs: policies}}
+	event := Event{Name: "my-cluster", User: "alice", Action: irsaAction, Size: "small", Count: "2"}
+
+	resources := runClusterProgram(t, event, clusterSettings{serviceAccounts: roles, irsaNameSeed: "alice"})
+
+	for _, policy := range policies {
+		attachment, found := resources["aws:iam/rolePolicyAttachment:RolePolicyAttachment::"+policyAttachmentName("my-cluster-irsa-default-app", policy)]
+		if !found {
+			t.Fatalf("irsa policy attachments test failure\n no attachment of %s in: '%v'", policy, resources)
+		}
+
+		if arn := attachment["policyArn"]; !arn.IsString() || arn.StringValue() != policy {
+			t.Errorf("irsa policy attachments test failure\n got policy attachment: '%v', want: '%v'", attachment, policy)
+		}
+	}
+}
+
+// role names of a cluster stay the same when another user updates it
+func TestIRSARoleNamesOtherUser(t *testing.T) {
+	roles := []serviceAccountRole{{ServiceAccount: "kube-system/ebs-csi-controller-sa"}}
+	stackConfig := mergeStackConfig(map[string]string{nodeSizeKey: "small", nodeCountKey: "2"}, irsaNameConfig("alice", map[string]string{}))
+
+	names := map[string]string{}
+
+	for _, user := range []string{"alice", "bob"} {
+		event := Event{Name: "my-cluster", User: user, Action: irsaAction}
+
+		stored := mergeStackConfig(stackConfig, irsaNameConfig(user, stackConfig))
+
+		applyStoredSpec(&event, stored)
+
+		resources := runClusterProgram(t, event, clusterSettings{serviceAccounts: roles, irsaNameSeed: stored[irsaNameSeedKey]})
+
+		names[user] = resources["aws:iam/role:Role::my-cluster-irsa-kube-system-ebs-csi-controller-sa"]["name"].StringValue()
+	}
+
+	if names["alice"] != names["bob"] || names["bob"] != irsaRoleName("my-cluster", "alice", "kube-system/ebs-csi-controller-sa") {
+		t.Errorf("irsa role names test failure\n got: '%v', want the names of the creating user", names)
+	}
+}
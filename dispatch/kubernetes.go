@@ -0,0 +1,402 @@
+package dispatch
+
+// pre-destroy removal of cloud resources created by in-cluster controllers, using the Kubernetes API directly
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const kubernetesRequestTimeout time.Duration = 30 * time.Second
+
+// cleanup waits are variables so tests can shorten them
+var (
+	kubernetesCleanupPoll    = 10 * time.Second
+	kubernetesCleanupTimeout = 10 * time.Minute
+)
+
+// kubeconfig fields needed to reach a cluster's API server
+type kubeconfigContexts struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token string `yaml:"token"`
+			Exec  *struct {
+				Command string   `yaml:"command"`
+				Args    []string `yaml:"args"`
+				Env     []struct {
+					Name  string `yaml:"name"`
+					Value string `yaml:"value"`
+				} `yaml:"env"`
+			} `yaml:"exec"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+type kubeClient struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+// Kubernetes object list, only the fields used by the cleanup are decoded
+type kubeObjectList struct {
+	Items []kubeObject `json:"items"`
+}
+
+type kubeObject struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		Type                          string `json:"type"`
+		PersistentVolumeReclaimPolicy string `json:"persistentVolumeReclaimPolicy"`
+		ClaimRef                      *struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"claimRef"`
+		Volumes []struct {
+			PersistentVolumeClaim *struct {
+				ClaimName string `json:"claimName"`
+			} `json:"persistentVolumeClaim"`
+		} `json:"volumes"`
+	} `json:"spec"`
+}
+
+// a namespaced resource type and the objects of it deleted before destroy, waited objects must be removed before destroy
+type kubeCleanup struct {
+	kind     string
+	apiPath  string
+	resource string
+	wait     bool
+	matches  func(kubeObject) bool
+}
+
+var kubernetesCleanups = []kubeCleanup{
+	{
+		kind:     "service",
+		apiPath:  "/api/v1",
+		resource: "services",
+		wait:     true,
+		matches:  func(object kubeObject) bool { return object.Spec.Type == "LoadBalancer" },
+	},
+	{
+		kind:     "ingress",
+		apiPath:  "/apis/networking.k8s.io/v1",
+		resource: "ingresses",
+		wait:     true,
+		matches:  func(object kubeObject) bool { return true },
+	},
+	{
+		kind:     "persistentvolumeclaim",
+		apiPath:  "/api/v1",
+		resource: "persistentvolumeclaims",
+		wait:     true,
+		matches:  func(object kubeObject) bool { return true },
+	},
+	{
+		// claims are protected from deletion while pods mount them, replacement pods stay pending
+		kind:     "pod",
+		apiPath:  "/api/v1",
+		resource: "pods",
+		matches: func(object kubeObject) bool {
+			for _, volume := range object.Spec.Volumes {
+				if volume.PersistentVolumeClaim != nil {
+					return true
+				}
+			}
+
+			return false
+		},
+	},
+}
+
+// bearer token from an exec credential plugin, e.g. aws eks get-token
+func execCredentialToken(command string, args []string, env []string) (string, error) {
+	var credential struct {
+		Status struct {
+			Token string `json:"token"`
+		} `json:"status"`
+	}
+
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(os.Environ(), env...)
+
+	data, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("kubeconfig credential command %s: %w", command, err)
+	}
+
+	if err := json.Unmarshal(data, &credential); err != nil {
+		return "", err
+	}
+
+	return credential.Status.Token, nil
+}
+
+// Kubernetes API client for a kubeconfig context
+func newKubeClient(kubeconfigPath string, contextName string) (*kubeClient, error) {
+	var config kubeconfigContexts
+
+	data, err := os.ReadFile(kubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+
+	var clusterName, userName string
+
+	for _, context := range config.Contexts {
+		if context.Name == contextName {
+			clusterName, userName = context.Context.Cluster, context.Context.User
+		}
+	}
+
+	if clusterName == "" {
+		return nil, fmt.Errorf("kubeconfig context %s not found in %s", contextName, kubeconfigPath)
+	}
+
+	kube := &kubeClient{}
+	certPool := x509.NewCertPool()
+
+	for _, cluster := range config.Clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+
+		kube.server = strings.TrimSuffix(cluster.Cluster.Server, "/")
+
+		ca, err := base64.StdEncoding.DecodeString(cluster.Cluster.CertificateAuthorityData)
+		if err != nil || !certPool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid certificate authority data for kubeconfig cluster %s", clusterName)
+		}
+	}
+
+	if kube.server == "" {
+		return nil, fmt.Errorf("kubeconfig cluster %s has no server", clusterName)
+	}
+
+	for _, user := range config.Users {
+		if user.Name != userName {
+			continue
+		}
+
+		kube.token = user.User.Token
+
+		if user.User.Exec != nil {
+			var env []string
+
+			for _, variable := range user.User.Exec.Env {
+				env = append(env, variable.Name+"="+variable.Value)
+			}
+
+			if kube.token, err = execCredentialToken(user.User.Exec.Command, user.User.Exec.Args, env); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	kube.client = &http.Client{
+		Timeout:   kubernetesRequestTimeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: certPool, MinVersion: tls.VersionTLS12}},
+	}
+
+	return kube, nil
+}
+
+func (k *kubeClient) request(method string, path string, body []byte, output interface{}) error {
+	request, err := http.NewRequest(method, k.server+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+k.token)
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := k.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	// objects already deleted are not errors
+	if response.StatusCode == http.StatusNotFound && method == http.MethodDelete {
+		return nil
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s %s: %s: %s", method, path, response.Status, strings.TrimSpace(string(data)))
+	}
+
+	if output == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, output)
+}
+
+// objects of a cleanup that match its filter
+func (k *kubeClient) cleanupObjects(cleanup kubeCleanup) ([]kubeObject, error) {
+	var list kubeObjectList
+
+	var objects []kubeObject
+
+	if err := k.request(http.MethodGet, cleanup.apiPath+"/"+cleanup.resource, nil, &list); err != nil {
+		return nil, err
+	}
+
+	for _, object := range list.Items {
+		if cleanup.matches(object) {
+			objects = append(objects, object)
+		}
+	}
+
+	return objects, nil
+}
+
+func (k *kubeClient) deleteObject(cleanup kubeCleanup, object kubeObject) error {
+	path := fmt.Sprintf("%s/namespaces/%s/%s/%s", cleanup.apiPath, object.Metadata.Namespace, cleanup.resource, object.Metadata.Name)
+
+	return k.request(http.MethodDelete, path, []byte(`{"propagationPolicy":"Foreground"}`), nil)
+}
+
+// persistent volumes whose cloud volume is deleted with them, they remain until the volume is gone
+func (k *kubeClient) reclaimedVolumes() ([]kubeObject, error) {
+	var list kubeObjectList
+
+	var volumes []kubeObject
+
+	if err := k.request(http.MethodGet, "/api/v1/persistentvolumes", nil, &list); err != nil {
+		return nil, err
+	}
+
+	for _, volume := range list.Items {
+		if volume.Spec.PersistentVolumeReclaimPolicy == "Delete" && volume.Spec.ClaimRef != nil {
+			volumes = append(volumes, volume)
+		}
+	}
+
+	return volumes, nil
+}
+
+// number of cleanup objects and reclaimed volumes that still exist
+func (k *kubeClient) pendingCleanup() (int, error) {
+	pending := 0
+
+	for _, cleanup := range kubernetesCleanups {
+		if !cleanup.wait {
+			continue
+		}
+
+		objects, err := k.cleanupObjects(cleanup)
+		if err != nil {
+			return 0, err
+		}
+
+		pending += len(objects)
+	}
+
+	volumes, err := k.reclaimedVolumes()
+	if err != nil {
+		return 0, err
+	}
+
+	return pending + len(volumes), nil
+}
+
+// delete LoadBalancer services, ingresses, volume claims and the pods mounting them, then wait for them and their volumes to be removed
+// controllers only release finalizers once the load balancer or EBS volume behind an object is deleted
+func (k *kubeClient) cleanupCloudResources() error {
+	for _, cleanup := range kubernetesCleanups {
+		objects, err := k.cleanupObjects(cleanup)
+		if err != nil {
+			return err
+		}
+
+		for _, object := range objects {
+			fmt.Printf(" - deleting %s %s/%s\n", cleanup.kind, object.Metadata.Namespace, object.Metadata.Name)
+
+			if err := k.deleteObject(cleanup, object); err != nil {
+				return err
+			}
+		}
+	}
+
+	for waited := time.Duration(0); ; waited += kubernetesCleanupPoll {
+		pending, err := k.pendingCleanup()
+		if err != nil {
+			return err
+		}
+
+		if pending == 0 {
+			return nil
+		}
+
+		if waited >= kubernetesCleanupTimeout {
+			return fmt.Errorf("%d Kubernetes objects were not removed after %s", pending, kubernetesCleanupTimeout)
+		}
+
+		fmt.Printf(" . waiting for %d load balancers and volumes to be removed\n", pending)
+
+		time.Sleep(kubernetesCleanupPoll)
+	}
+}
+
+// remove cloud resources created inside a cluster before its stack is destroyed, failures fall back to destroy retries
+func cleanupClusterWorkloads(event Event, eksName string, region string) {
+	fmt.Printf("\n . Removing Kubernetes load balancers and volumes from cluster %s\n", event.Name)
+
+	kubeconfigPath, _, err := updateKubeconfig(eksName, event.Name, region)
+	if err == nil {
+		var kube *kubeClient
+
+		if kube, err = newKubeClient(kubeconfigPath, event.Name); err == nil {
+			err = kube.cleanupCloudResources()
+		}
+	}
+
+	if err != nil {
+		fmt.Printf(" ! Kubernetes cleanup of %s failed, continuing with destroy: %v\n", event.Name, err)
+	}
+}
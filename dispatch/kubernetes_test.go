@@ -0,0 +1,259 @@
+package dispatch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// in-memory Kubernetes API server, deleting a claim removes its bound volume like the EBS CSI controller
+type fakeKubeAPI struct {
+	mu        sync.Mutex
+	objects   map[string][]map[string]interface{}
+	deleted   []string
+	stuckKind string
+}
+
+func kubeTestObject(namespace string, name string, spec map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{"name": name, "namespace": namespace},
+		"spec":     spec,
+	}
+}
+
+func (f *fakeKubeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		if items, found := f.objects[r.URL.Path]; found {
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	// DELETE <api>/namespaces/<namespace>/<resource>/<name>
+	path := strings.Split(r.URL.Path, "/namespaces/")
+	object := strings.Split(path[len(path)-1], "/")
+
+	if r.Method != http.MethodDelete || len(path) != 2 || len(object) != 3 {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	namespace, resource, name := object[0], object[1], object[2]
+	f.deleted = append(f.deleted, resource+" "+namespace+"/"+name)
+
+	if resource == f.stuckKind {
+		return
+	}
+
+	listPath := path[0] + "/" + resource
+	f.objects[listPath] = removeKubeObjects(f.objects[listPath], func(item map[string]interface{}) bool {
+		metadata := item["metadata"].(map[string]interface{})
+
+		return metadata["namespace"] == namespace && metadata["name"] == name
+	})
+
+	if resource == "persistentvolumeclaims" {
+		f.objects["/api/v1/persistentvolumes"] = removeKubeObjects(f.objects["/api/v1/persistentvolumes"], func(item map[string]interface{}) bool {
+			claim := item["spec"].(map[string]interface{})["claimRef"].(map[string]interface{})
+
+			return claim["namespace"] == namespace && claim["name"] == name
+		})
+	}
+}
+
+func removeKubeObjects(items []map[string]interface{}, remove func(map[string]interface{}) bool) []map[string]interface{} {
+	kept := []map[string]interface{}{}
+
+	for _, item := range items {
+		if !remove(item) {
+			kept = append(kept, item)
+		}
+	}
+
+	return kept
+}
+
+func newFakeKubeAPI() *fakeKubeAPI {
+	claimVolume := []interface{}{map[string]interface{}{"persistentVolumeClaim": map[string]interface{}{"claimName": "db"}}}
+
+	return &fakeKubeAPI{objects: map[string][]map[string]interface{}{
+		"/api/v1/services": {
+			kubeTestObject("web", "frontend", map[string]interface{}{"type": "LoadBalancer"}),
+			kubeTestObject("web", "api", map[string]interface{}{"type": "ClusterIP"}),
+		},
+		"/apis/networking.k8s.io/v1/ingresses": {
+			kubeTestObject("web", "site", map[string]interface{}{}),
+		},
+		"/api/v1/persistentvolumeclaims": {
+			kubeTestObject("data", "db", map[string]interface{}{}),
+		},
+		"/api/v1/pods": {
+			kubeTestObject("data", "db-0", map[string]interface{}{"volumes": claimVolume}),
+			kubeTestObject("web", "api-1", map[string]interface{}{}),
+		},
+		"/api/v1/persistentvolumes": {
+			kubeTestObject("", "pvc-1", map[string]interface{}{
+				"persistentVolumeReclaimPolicy": "Delete",
+				"claimRef":                      map[string]interface{}{"namespace": "data", "name": "db"},
+			}),
+			kubeTestObject("", "pvc-2", map[string]interface{}{
+				"persistentVolumeReclaimPolicy": "Retain",
+				"claimRef":                      map[string]interface{}{"namespace": "archive", "name": "old"},
+			}),
+		},
+	}}
+}
+
+// kubeconfig for a test server with a static token
+func writeTestKubeconfig(t *testing.T, server *httptest.Server) string {
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	config := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: other
+clusters:
+- name: arn:aws:eks:us-east-1:123456789012:cluster/test-eksCluster
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+contexts:
+- name: test
+  context:
+    cluster: arn:aws:eks:us-east-1:123456789012:cluster/test-eksCluster
+    user: test-user
+users:
+- name: test-user
+  user:
+    token: test-token
+`, server.URL, base64.StdEncoding.EncodeToString(ca))
+
+	path := filepath.Join(t.TempDir(), "config")
+
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatalf("kubeconfig unit test failure\n error: '%v'", err)
+	}
+
+	return path
+}
+
+func shortKubernetesCleanup(t *testing.T) {
+	poll, timeout := kubernetesCleanupPoll, kubernetesCleanupTimeout
+	kubernetesCleanupPoll, kubernetesCleanupTimeout = time.Millisecond, 5*time.Millisecond
+
+	t.Cleanup(func() {
+		kubernetesCleanupPoll, kubernetesCleanupTimeout = poll, timeout
+	})
+}
+
+func TestCleanupCloudResources(t *testing.T) {
+	shortKubernetesCleanup(t)
+
+	api := newFakeKubeAPI()
+	server := httptest.NewTLSServer(api)
+
+	defer server.Close()
+
+	kube, err := newKubeClient(writeTestKubeconfig(t, server), "test")
+	if err != nil {
+		t.Fatalf("newKubeClient unit test failure\n error: '%v'", err)
+	}
+
+	if err := kube.cleanupCloudResources(); err != nil {
+		t.Fatalf("cleanupCloudResources unit test failure\n error: '%v'", err)
+	}
+
+	want := []string{
+		"ingresses web/site",
+		"persistentvolumeclaims data/db",
+		"pods data/db-0",
+		"services web/frontend",
+	}
+
+	sort.Strings(api.deleted)
+
+	if !reflect.DeepEqual(api.deleted, want) {
+		t.Errorf("cleanupCloudResources unit test failure\n got: '%v', want: '%v'", api.deleted, want)
+	}
+
+	if len(api.objects["/api/v1/services"]) != 1 || len(api.objects["/api/v1/persistentvolumes"]) != 1 {
+		t.Errorf("cleanupCloudResources unit test failure\n ClusterIP services and retained volumes must be kept, got: '%v'", api.objects)
+	}
+}
+
+func TestCleanupCloudResourcesTimeout(t *testing.T) {
+	shortKubernetesCleanup(t)
+
+	// a load balancer finalizer that is never released
+	api := newFakeKubeAPI()
+	api.stuckKind = "services"
+	server := httptest.NewTLSServer(api)
+
+	defer server.Close()
+
+	kube, err := newKubeClient(writeTestKubeconfig(t, server), "test")
+	if err != nil {
+		t.Fatalf("newKubeClient unit test failure\n error: '%v'", err)
+	}
+
+	if err := kube.cleanupCloudResources(); err == nil || !strings.Contains(err.Error(), "1 Kubernetes objects") {
+		t.Errorf("cleanupCloudResources unit test failure\n expected a timeout for the stuck service, got: '%v'", err)
+	}
+}
+
+func TestNewKubeClient(t *testing.T) {
+	server := httptest.NewTLSServer(newFakeKubeAPI())
+
+	defer server.Close()
+
+	path := writeTestKubeconfig(t, server)
+
+	tests := []struct {
+		name    string
+		context string
+		path    string
+		err     bool
+	}{
+		{name: "Named context", context: "test", path: path},
+		{name: "Missing context", context: "missing", path: path, err: true},
+		{name: "Current context not found", context: "", path: path, err: true},
+		{name: "Missing kubeconfig", context: "test", path: filepath.Join(t.TempDir(), "missing"), err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kube, err := newKubeClient(test.path, test.context)
+			if (err != nil) != test.err {
+				t.Fatalf("newKubeClient unit test failure\n error: '%v'", err)
+			}
+
+			if err == nil && (kube.server != server.URL || kube.token != "test-token") {
+				t.Errorf("newKubeClient unit test failure\n got: '%s %s', want: '%s test-token'", kube.server, kube.token, server.URL)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package dispatch
+
+// cluster lifetime and expiry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	expiryConfigKey  string        = "dispatch:expiry"
+	ownerConfigKey   string        = "dispatch:owner"
+	createdConfigKey string        = "dispatch:created"
+	extendedByKey    string        = "dispatch:extendedBy"
+	extendedAtKey    string        = "dispatch:extendedAt"
+	expiryWarning    time.Duration = time.Hour
+	hoursPerDay      int           = 24
+	jsonOutput       string        = "json"
+)
+
+type reapRecord struct {
+	Name   string `json:"name"`
+	Owner  string `json:"owner"`
+	Region string `json:"region"`
+	Expiry string `json:"expiry"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// parse a time-to-live duration, days are supported with the 'd' suffix (e.g. 2d)
+func parseTTL(ttl string) (time.Duration, error) {
+	if strings.HasSuffix(ttl, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(ttl, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid time-to-live: %s", ttl)
+		}
+
+		return time.Duration(days*hoursPerDay) * time.Hour, nil
+	}
+
+	duration, err := time.ParseDuration(ttl)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid time-to-live: %s", ttl)
+	}
+
+	return duration, nil
+}
+
+// stored expiry of a cluster
+func clusterExpiry(summary stackSummary) (time.Time, bool) {
+	expiry, err := time.Parse(time.RFC3339, summary.Config[expiryConfigKey])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return expiry, true
+}
+
+func clusterOwner(summary stackSummary) string {
+	if owner := summary.Config[ownerConfigKey]; owner != "" {
+		return owner
+	}
+
+	return "unknown"
+}
+
+// clusters with an expiry before the provided time
+func expiredClusters(summaries map[string]stackSummary, now time.Time) []stackSummary {
+	var expired []stackSummary
+
+	for _, summary := range filterClusters(summaries, "", true) {
+		if expiry, found := clusterExpiry(summary); found && !expiry.After(now) {
+			expired = append(expired, summary)
+		}
+	}
+
+	return expired
+}
+
+// list warning for expired clusters and clusters nearing expiry
+func expiryStatus(summary stackSummary, now time.Time) string {
+	expiry, found := clusterExpiry(summary)
+	if !found {
+		return ""
+	}
+
+	remaining := expiry.Sub(now)
+
+	switch {
+	case remaining <= 0:
+		return fmt.Sprintf("! expired %s ago", (-remaining).Round(time.Minute))
+	case remaining <= expiryWarning:
+		return fmt.Sprintf("! expires in %s", remaining.Round(time.Minute))
+	default:
+		return ""
+	}
+}
+
+// maximum cluster lifetime from the Dispatch config file, zero when unlimited
+func maxLifetime(event Event) time.Duration {
+	if event.MaxLifetime == "" {
+		return 0
+	}
+
+	lifetime, err := parseTTL(event.MaxLifetime)
+	if err != nil {
+		reportErr(err, "set maximum cluster lifetime from config file")
+	}
+
+	return lifetime
+}
+
+// new expiry of a lease extension, limited by the maximum cluster lifetime
+func extendedExpiry(summary stackSummary, by time.Duration, lifetime time.Duration, now time.Time) (time.Time, error) {
+	base := now
+
+	if expiry, found := clusterExpiry(summary); found && expiry.After(now) {
+		base = expiry
+	}
+
+	expiry := base.Add(by)
+
+	if lifetime > 0 {
+		created, err := time.Parse(time.RFC3339, summary.Config[createdConfigKey])
+		if err != nil {
+			created = now
+		}
+
+		if expiry.Sub(created) > lifetime {
+			return expiry, fmt.Errorf("expiry %s exceeds the maximum cluster lifetime of %s from creation",
+				expiry.UTC().Format(time.RFC3339), lifetime)
+		}
+	}
+
+	return expiry, nil
+}
+
+// push out a cluster's expiry without updating its infrastructure
+func extendCluster(event Event) {
+	summary, found := getStackSummaries(event.Bucket)[event.Name]
+	if !found {
+		fmt.Printf("\n %s was not found, exiting.\n\n", event.Name)
+		os.Exit(0)
+	}
+
+	by, err := parseTTL(event.ExtendBy)
+	if err != nil {
+		reportErr(err, "parse cluster extension")
+	}
+
+	now := time.Now().UTC()
+
+	expiry, err := extendedExpiry(summary, by, maxLifetime(event), now)
+	if err != nil {
+		reportErr(err, "extend cluster "+event.Name)
+	}
+
+	updateStackMetadata(event.Bucket, event.Name, map[string]string{
+		expiryConfigKey: expiry.UTC().Format(time.RFC3339),
+		extendedByKey:   event.User,
+		extendedAtKey:   now.Format(time.RFC3339),
+	})
+
+	fmt.Printf("\n - %s expiry extended to %s UTC by %s\n", event.Name, expiry.UTC().Format("2006-01-02 15:04:05"), event.User)
+}
+
+// owner, creation and expiry metadata of a new cluster
+func lifecycleConfig(event Event, summary stackSummary) map[string]string {
+	now := time.Now().UTC()
+	lifetime := maxLifetime(event)
+
+	values := map[string]string{}
+
+	if summary.Config[ownerConfigKey] == "" {
+		values[ownerConfigKey] = event.User
+	}
+
+	if summary.Config[createdConfigKey] == "" {
+		values[createdConfigKey] = now.Format(time.RFC3339)
+	}
+
+	if event.Protect {
+		values[protectedKey] = "true"
+	}
+
+	if event.TTL != "" {
+		ttl, err := parseTTL(event.TTL)
+		if err != nil {
+			reportErr(err, "set cluster time-to-live")
+		}
+
+		if lifetime > 0 && ttl > lifetime {
+			reportErr(fmt.Errorf("time-to-live %s exceeds the maximum cluster lifetime of %s", event.TTL, lifetime), "set cluster time-to-live")
+		}
+
+		values[expiryConfigKey] = now.Add(ttl).Format(time.RFC3339)
+	} else if lifetime > 0 && summary.Config[expiryConfigKey] == "" {
+		// clusters are limited to the maximum lifetime when no time-to-live is provided
+		values[expiryConfigKey] = now.Add(lifetime).Format(time.RFC3339)
+	}
+
+	return values
+}
+
+func printReapReport(records []reapRecord, output string) {
+	if output == jsonOutput {
+		report, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			reportErr(err, "create reap report")
+		}
+
+		fmt.Fprintln(reportOutput, string(report))
+
+		return
+	}
+
+	for _, record := range records {
+		fmt.Printf(" - %s: cluster %s (%s) owned by %s expired at %s\n",
+			record.Action, record.Name, record.Region, record.Owner, record.Expiry)
+
+		if record.Error != "" {
+			fmt.Printf("\t ! %s\n", record.Error)
+		}
+	}
+}
+
+// record the outcome of each reaped cluster, returns the number of failed deletes
+func applyReapResults(records []reapRecord, results []bulkResult) int {
+	failed := 0
+
+	outcomes := map[string]error{}
+
+	for _, result := range results {
+		outcomes[result.Name] = result.Err
+	}
+
+	for i, record := range records {
+		err, reaped := outcomes[record.Name]
+		if !reaped {
+			continue
+		}
+
+		if err != nil {
+			records[i].Action = "delete failed"
+			records[i].Error = err.Error()
+			failed++
+
+			continue
+		}
+
+		records[i].Action = "destroyed"
+	}
+
+	return failed
+}
+
+// destroy clusters whose expiry has passed
+func reapClusters(event Event) {
+	var records []reapRecord
+
+	expired := expiredClusters(getStackSummaries(event.Bucket), time.Now())
+
+	if len(expired) == 0 {
+		if event.Output == jsonOutput {
+			printReapReport([]reapRecord{}, event.Output)
+		} else {
+			fmt.Print("\n . No expired clusters found\n")
+		}
+
+		return
+	}
+
+	action := "destroy"
+	if event.DryRun {
+		action = "would destroy"
+	}
+
+	// protected clusters are reported but never destroyed
+	reapable, protected := partitionProtected(expired)
+
+	for _, summary := range append(reapable, protected...) {
+		expiry, _ := clusterExpiry(summary)
+
+		record := reapRecord{
+			Name:   summary.Name,
+			Owner:  clusterOwner(summary),
+			Region: summaryRegion(summary),
+			Expiry: expiry.Format(time.RFC3339),
+			Action: action,
+		}
+
+		if clusterProtected(summary) {
+			record.Action = "skip protected"
+		}
+
+		records = append(records, record)
+	}
+
+	if event.DryRun || len(reapable) == 0 {
+		printReapReport(records, event.Output)
+
+		return
+	}
+
+	if !event.Verified {
+		var approve string
+
+		printReapReport(records, "")
+
+		fmt.Printf("\n ? destroy %d expired clusters (y/n): ", len(reapable))
+		fmt.Scanf("%s", &approve)
+
+		if approve != "Y" && approve != "y" {
+			os.Exit(0)
+		}
+	}
+
+	names := make([]string, 0, len(reapable))
+
+	for _, summary := range reapable {
+		names = append(names, summary.Name)
+	}
+
+	reapEvent := event
+	reapEvent.Action = deleteAction
+
+	// each delete runs as a Dispatch subprocess, Exec exits on failure so one failed delete does not stop the reap
+	getSession().releaseInterrupts()
+
+	results := runBulk(deleteAction, names, 1, func(name string) error {
+		return bulkClusterCommand(reapEvent, name)
+	})
+
+	failed := applyReapResults(records, results)
+
+	printReapReport(records, event.Output)
+
+	if failed > 0 {
+		fmt.Printf("\n ! %d of %d expired clusters failed to delete\n\n", failed, len(names))
+		os.Exit(1)
+	}
+}
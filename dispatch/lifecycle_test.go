@@ -0,0 +1,180 @@
+package dispatch
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTTL(t *testing.T) {
+	// input time-to-live string
+	// return duration and error
+	tests := []struct {
+		expectedReturn time.Duration
+		name           string
+		input          string
+		err            error
+	}{
+		{
+			name:           "Hours",
+			input:          "8h",
+			expectedReturn: 8 * time.Hour,
+		},
+		{
+			name:           "Days",
+			input:          "2d",
+			expectedReturn: 48 * time.Hour,
+		},
+		{
+			name:  "Invalid",
+			input: "soon",
+			err:   fmt.Errorf("invalid time-to-live: soon"),
+		},
+		{
+			name:  "Negative",
+			input: "-1h",
+			err:   fmt.Errorf("invalid time-to-live: -1h"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ttl, err := parseTTL(test.input)
+
+			if ttl != test.expectedReturn || (err == nil) != (test.err == nil) {
+				t.Errorf("parseTTL unit test failure\n got: '%v', want: '%v', error: '%v'", ttl, test.expectedReturn, err)
+			}
+		})
+	}
+}
+
+func TestExpiredClusters(t *testing.T) {
+	now := time.Date(2022, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	summaries := map[string]stackSummary{
+		"expired":   {Name: "expired", Config: map[string]string{expiryConfigKey: "2022-12-01T11:00:00Z"}},
+		"active":    {Name: "active", Config: map[string]string{expiryConfigKey: "2022-12-01T13:00:00Z"}},
+		"no-expiry": {Name: "no-expiry", Config: map[string]string{}},
+	}
+
+	expired := expiredClusters(summaries, now)
+
+	if len(expired) != 1 || expired[0].Name != "expired" {
+		t.Errorf("expiredClusters unit test failure\n got: '%v', want: 'expired'", expired)
+	}
+}
+
+func TestExpiryStatus(t *testing.T) {
+	now := time.Date(2022, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expectedReturn string
+		name           string
+		expiry         string
+	}{
+		{
+			name:           "Expired",
+			expiry:         "2022-12-01T10:00:00Z",
+			expectedReturn: "! expired 2h0m0s ago",
+		},
+		{
+			name:           "Nearing expiry",
+			expiry:         "2022-12-01T12:45:00Z",
+			expectedReturn: "! expires in 45m0s",
+		},
+		{
+			name:           "Active",
+			expiry:         "2022-12-02T12:00:00Z",
+			expectedReturn: "",
+		},
+		{
+			name:           "No expiry",
+			expiry:         "",
+			expectedReturn: "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			summary := stackSummary{Config: map[string]string{expiryConfigKey: test.expiry}}
+
+			status := expiryStatus(summary, now)
+
+			if status != test.expectedReturn {
+				t.Errorf("expiryStatus unit test failure\n got: '%v', want: '%v'", status, test.expectedReturn)
+			}
+		})
+	}
+}
+
+func TestExtendedExpiry(t *testing.T) {
+	now := time.Date(2022, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expectedReturn time.Time
+		name           string
+		config         map[string]string
+		lifetime       time.Duration
+		err            bool
+	}{
+		{
+			name:           "Extend active lease",
+			config:         map[string]string{expiryConfigKey: "2022-12-01T14:00:00Z"},
+			expectedReturn: time.Date(2022, 12, 1, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "Extend expired lease",
+			config:         map[string]string{expiryConfigKey: "2022-12-01T08:00:00Z"},
+			expectedReturn: time.Date(2022, 12, 1, 16, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "Within maximum lifetime",
+			config:         map[string]string{createdConfigKey: "2022-12-01T10:00:00Z", expiryConfigKey: "2022-12-01T14:00:00Z"},
+			lifetime:       8 * time.Hour,
+			expectedReturn: time.Date(2022, 12, 1, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "Exceeds maximum lifetime",
+			config:         map[string]string{createdConfigKey: "2022-12-01T08:00:00Z", expiryConfigKey: "2022-12-01T14:00:00Z"},
+			lifetime:       8 * time.Hour,
+			expectedReturn: time.Date(2022, 12, 1, 18, 0, 0, 0, time.UTC),
+			err:            true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			summary := stackSummary{Config: test.config}
+
+			expiry, err := extendedExpiry(summary, 4*time.Hour, test.lifetime, now)
+
+			if !expiry.Equal(test.expectedReturn) || (err != nil) != test.err {
+				t.Errorf("extendedExpiry unit test failure\n got: '%v', want: '%v', error: '%v'", expiry, test.expectedReturn, err)
+			}
+		})
+	}
+}
+
+func TestApplyReapResults(t *testing.T) {
+	records := []reapRecord{
+		{Name: "broken", Action: "destroy"},
+		{Name: "scratch", Action: "destroy"},
+		{Name: "shared-demo", Action: "skip protected"},
+	}
+
+	// a failed delete is recorded without stopping the remaining deletes
+	results := []bulkResult{
+		{Name: "scratch"},
+		{Name: "broken", Err: fmt.Errorf("exit status 1, see broken-delete.log")},
+	}
+
+	failed := applyReapResults(records, results)
+
+	want := []reapRecord{
+		{Name: "broken", Action: "delete failed", Error: "exit status 1, see broken-delete.log"},
+		{Name: "scratch", Action: "destroyed"},
+		{Name: "shared-demo", Action: "skip protected"},
+	}
+
+	if failed != 1 || !reflect.DeepEqual(records, want) {
+		t.Errorf("applyReapResults unit test failure\n got: '%+v' with %d failed, want: '%+v' with 1 failed", records, failed, want)
+	}
+}
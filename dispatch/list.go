@@ -0,0 +1,107 @@
+package dispatch
+
+// cluster listing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const unknownRegion string = "unknown region"
+
+func summaryRegion(summary stackSummary) string {
+	region := summary.Config[regionConfigKey]
+	if region == "" {
+		return unknownRegion
+	}
+
+	return region
+}
+
+// cluster summaries sorted by name, limited to a region unless all regions are requested
+func filterClusters(summaries map[string]stackSummary, region string, allRegions bool) []stackSummary {
+	var clusters []stackSummary
+
+	for _, summary := range summaries {
+		if allRegions || summaryRegion(summary) == region {
+			clusters = append(clusters, summary)
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+
+	return clusters
+}
+
+func printExistingClusters(bucket string) {
+	clusters := filterClusters(getStackSummaries(bucket), "", true)
+
+	if len(clusters) > 0 {
+		fmt.Print(" - Existing stack configurations:\n")
+
+		for _, summary := range clusters {
+			fmt.Printf("\t <> %s (%s)\n", summary.Key, summaryRegion(summary))
+		}
+	} else {
+		fmt.Print(" . No existing clusters found\n")
+	}
+}
+
+func printClusterList(event Event) {
+	region := event.Region
+	if region == "" {
+		region = setAWSRegion()
+	}
+
+	clusters := filterTagged(filterClusters(getStackSummaries(event.Bucket), region, event.AllRegions), event.Tags)
+
+	if len(clusters) == 0 {
+		if event.AllRegions {
+			fmt.Print("\n . No existing clusters found\n")
+		} else {
+			fmt.Printf("\n . No existing clusters found in %s, use -all-regions to list every region\n", region)
+		}
+
+		return
+	}
+
+	if event.AllRegions {
+		fmt.Print("\n - Clusters in all regions:\n")
+	} else {
+		fmt.Printf("\n - Clusters in %s:\n", region)
+	}
+
+	now := time.Now()
+
+	for _, summary := range clusters {
+		fmt.Printf("\t <> %-24s %-16s last updated %s UTC\n",
+			summary.Name, summaryRegion(summary), summary.LastModified.UTC().Format("2006-01-02 15:04:05"))
+
+		if schedule := summary.Config[scheduleKey]; schedule != "" {
+			fmt.Printf("\t    scheduled awake %s\n", schedule)
+		}
+
+		if tags := clusterTags(summary); len(tags) > 0 {
+			fmt.Printf("\t    tags %s\n", formatTags(tags))
+		}
+
+		if clusterProtected(summary) {
+			fmt.Print("\t    protected from deletion\n")
+		}
+
+		if clusterAsleep(summary) {
+			fmt.Printf("\t    asleep, wakes to %s nodes\n", summary.Config[wakeNodeCountKey])
+		}
+
+		if expiry, found := clusterExpiry(summary); found {
+			fmt.Printf("\t    expires %s UTC %s\n", expiry.UTC().Format("2006-01-02 15:04:05"), expiryStatus(summary, now))
+		}
+
+		if failure, found := storedDeleteFailure(summary.Config); found {
+			fmt.Printf("\t    delete failed at %s with %d resources remaining, run delete to resume\n", failure.FailedAt, len(failure.Remaining))
+		}
+	}
+}
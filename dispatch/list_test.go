@@ -0,0 +1,51 @@
+package dispatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterClusters(t *testing.T) {
+	summaries := map[string]stackSummary{
+		"b-west": {Name: "b-west", Config: map[string]string{regionConfigKey: "us-west-2"}},
+		"a-west": {Name: "a-west", Config: map[string]string{regionConfigKey: "us-west-2"}},
+		"east":   {Name: "east", Config: map[string]string{regionConfigKey: "us-east-1"}},
+	}
+
+	tests := []struct {
+		expectedReturn []string
+		name           string
+		region         string
+		allRegions     bool
+	}{
+		{
+			name:           "Single region",
+			region:         "us-west-2",
+			expectedReturn: []string{"a-west", "b-west"},
+		},
+		{
+			name:           "All regions",
+			region:         "us-west-2",
+			allRegions:     true,
+			expectedReturn: []string{"a-west", "b-west", "east"},
+		},
+		{
+			name:           "Empty region",
+			region:         "eu-west-1",
+			expectedReturn: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var names []string
+
+			for _, summary := range filterClusters(summaries, test.region, test.allRegions) {
+				names = append(names, summary.Name)
+			}
+
+			if !reflect.DeepEqual(names, test.expectedReturn) {
+				t.Errorf("filterClusters unit test failure\n got: '%v', want: '%v'", names, test.expectedReturn)
+			}
+		})
+	}
+}
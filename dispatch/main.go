@@ -1,8 +1,11 @@
 package dispatch
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 
 	"github.com/christiantragesser/dispatch/tuiaction"
@@ -23,17 +26,66 @@ const (
 	defaultRegion    string = "us-east-1"
 	defaultScale     int    = 2
 	pulumiStacksPath string = ".pulumi/stacks/"
+	listAction       string = "list"
+	reapAction       string = "reap"
+	extendAction     string = "extend"
+	regionConfigKey  string = "aws:region"
+	costAction       string = "cost"
+	nodeSizeKey      string = "dispatch:nodeSize"
+	nodeCountKey     string = "dispatch:nodeCount"
+	versionKey       string = "dispatch:version"
 )
 
 type Event struct {
-	Action   string
-	Bucket   string
-	Count    string
-	Name     string
-	Size     string
-	User     string
-	Version  string
-	Verified bool
+	Action          string
+	Subcommand      string
+	Bucket          string
+	CloneFrom       string
+	DNSZone         string
+	Count           string
+	EKSCluster      string
+	ExtendBy        string
+	ExternalID      string
+	File            string
+	MaxLifetime     string
+	Interval        string
+	KMSKey          string
+	LogRetention    string
+	LogTypes        string
+	MFASerial       string
+	Name            string
+	Output          string
+	PrincipalARN    string
+	PublicCIDRs     string
+	Region          string
+	Selector        string
+	ServiceAccount  string
+	RoleARN         string
+	Schedule        string
+	SessionName     string
+	Size            string
+	TTL             string
+	User            string
+	Version         string
+	Verified        bool
+	All             bool
+	Cleanup         bool
+	AllRegions      bool
+	DryRun          bool
+	SkipCleanup     bool
+	SleepNAT        bool
+	Once            bool
+	Protect         bool
+	NoCertManager   bool
+	PrivateEndpoint bool
+	TUI             bool
+	Names           []string
+	Parallel        int
+	Tags            map[string]string
+	DefaultTags     map[string]string
+	AccessMappings  []accessMapping
+	DefaultAccess   []accessMapping
+	ServiceAccounts []serviceAccountRole
 }
 
 func (e Event) getTUIAction() string {
@@ -44,12 +96,30 @@ func (e Event) tuiCreate() []string {
 	return tuicreate.Create()
 }
 
-func (e Event) tuiDelete(clusters []map[string]string) string {
-	selection := tuidelete.SelectCluster(clusters)
+// cluster name of a TUI selected stack key
+func selectedCluster(selection string) string {
 	clusterName := strings.TrimPrefix(selection, pulumiStacksPath)
-	clusterName = strings.TrimSuffix(clusterName, "-eks.json")
 
-	return clusterName
+	return strings.TrimSuffix(clusterName, "-eks.json")
+}
+
+func (e Event) tuiDelete(clusters []map[string]string) []string {
+	var names []string
+
+	for _, selection := range tuidelete.SelectClusters(clusters) {
+		names = append(names, selectedCluster(selection))
+	}
+
+	return names
+}
+
+func (e Event) tuiClone(clusters []map[string]string) (string, []string) {
+	source := selectedCluster(tuidelete.SelectCluster(clusters))
+	if source == "" {
+		return "", nil
+	}
+
+	return source, tuicreate.Clone(source)
 }
 
 func (e Event) getClusters(bucket string) []string {
@@ -57,12 +127,16 @@ func (e Event) getClusters(bucket string) []string {
 }
 
 func (e Event) getClusterCreationDate(bucket string, cluster string) string {
-	metadata, err := getObjectMetadata(bucket, cluster)
-	if err != nil {
+	summary, found := getStackSummaries(bucket)[stackClusterName(cluster)]
+	if !found {
 		return notFound
 	}
 
-	return metadata.LastModified.Format("2006-01-02 15:04:05") + " UTC"
+	return summary.LastModified.UTC().Format("2006-01-02 15:04:05") + " UTC"
+}
+
+func (e Event) isClusterProtected(bucket string, cluster string) bool {
+	return stackProtected(bucket, stackClusterName(cluster))
 }
 
 func (e Event) vpcZones() string {
@@ -73,7 +147,88 @@ func (e Event) ec2Type(sizeName string) (string, error) {
 	return getNodeSize(sizeName)
 }
 
+// Run executes a Dispatch event
+func Run(event *Event) string {
+	switch event.Action {
+	case listAction:
+		printClusterList(*event)
+
+		return ""
+	case reapAction:
+		reapClusters(*event)
+
+		return ""
+	case extendAction:
+		extendCluster(*event)
+
+		return ""
+	case costAction:
+		reportClusterCosts(*event)
+
+		return ""
+	case driftAction:
+		detectDrift(*event)
+
+		return ""
+	case orphansAction:
+		reportOrphans(*event)
+
+		return ""
+	case cloneAction:
+		return cloneCluster(event)
+	case adoptAction:
+		return Exec(event)
+	case exportAction:
+		exportCluster(*event)
+
+		return ""
+	case importAction:
+		importCluster(*event)
+
+		return ""
+	case scheduleAction:
+		scheduleCluster(*event)
+
+		return ""
+	case protectAction, unprotectAction:
+		protectCluster(*event)
+
+		return ""
+	case schedulerAction:
+		runScheduler(*event)
+
+		return ""
+	case describeAction:
+		describeCluster(*event)
+
+		return ""
+	case accessAction:
+		if event.Subcommand == accessList {
+			listAccess(*event)
+
+			return ""
+		}
+
+		return Exec(event)
+	case deleteAction, sleepAction, wakeAction:
+		if bulkEvent(*event) {
+			bulkExec(*event)
+
+			return ""
+		}
+
+		return Exec(event)
+	default:
+		return Exec(event)
+	}
+}
+
 func reportErr(err error, activity string) {
+	if errors.Is(err, context.Canceled) {
+		fmt.Printf(" ! Cancelled while attempting to %s\n\n", activity)
+		os.Exit(interruptStatus)
+	}
+
 	fmt.Printf(" ! Failed to %s\n\n", activity)
 	log.Fatalln(err)
 }
@@ -0,0 +1,180 @@
+package dispatch
+
+// Dispatch cluster metadata stored beside each stack checkpoint in the state store
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	dispatchStacksPath string = ".dispatch/stacks/"
+	metadataVersion    int    = 1
+)
+
+// Dispatch metadata of a stack (.dispatch/stacks/<stack>.json)
+// pulumi keeps stack config in the temporary inline workspace and rewrites checkpoints on every update,
+// so cluster settings are stored in their own object
+type stackMetadata struct {
+	Version int               `json:"version"`
+	Config  map[string]string `json:"config"`
+}
+
+// state store key of a cluster's Dispatch metadata
+func metadataKey(name string) string {
+	return dispatchStacksPath + name + "-eks.json"
+}
+
+// cluster name of a metadata object key
+func metadataClusterName(key string) string {
+	return stackClusterName(strings.TrimPrefix(key, dispatchStacksPath))
+}
+
+// config values merged into stored config, empty values are removed
+func mergeStackConfig(stored map[string]string, values map[string]string) map[string]string {
+	merged := map[string]string{}
+
+	for key, value := range stored {
+		merged[key] = value
+	}
+
+	for key, value := range values {
+		if value == "" {
+			delete(merged, key)
+
+			continue
+		}
+
+		merged[key] = value
+	}
+
+	return merged
+}
+
+func parseStackMetadata(data []byte) (map[string]string, error) {
+	var metadata stackMetadata
+
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+
+	if metadata.Config == nil {
+		return map[string]string{}, nil
+	}
+
+	return metadata.Config, nil
+}
+
+func encodeStackMetadata(stackConfig map[string]string) ([]byte, error) {
+	return json.MarshalIndent(stackMetadata{Version: metadataVersion, Config: stackConfig}, "", "    ")
+}
+
+// config of checkpoints edited by earlier Dispatch versions, used until the stack has a metadata object
+func checkpointConfig(data []byte) map[string]string {
+	var checkpoint stackCheckpoint
+
+	stackConfig := map[string]string{}
+
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return stackConfig
+	}
+
+	for key, value := range checkpoint.Checkpoint.Config {
+		// secure values are encrypted with the stack passphrase and not used by Dispatch
+		if configValue, ok := value.(string); ok {
+			stackConfig[key] = configValue
+		}
+	}
+
+	return stackConfig
+}
+
+// read a state store object, found is false when the key does not exist
+func getStateObject(bucket string, key string) ([]byte, bool, error) {
+	var noKey *s3types.NoSuchKey
+
+	sess := getSession()
+
+	ctx, cancel := sess.requestContext()
+	defer cancel()
+
+	resp, err := sess.stateStore(bucket).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    aws.String(key),
+	})
+	if errors.As(err, &noKey) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+
+	return data, true, err
+}
+
+// stored Dispatch config of a cluster
+func readStackMetadata(bucket string, name string) map[string]string {
+	data, found, err := getStateObject(bucket, metadataKey(name))
+	if err != nil {
+		reportErr(err, "read stack metadata")
+	}
+
+	if !found {
+		return checkpointConfig(readStackCheckpoint(bucket, name))
+	}
+
+	stackConfig, err := parseStackMetadata(data)
+	if err != nil {
+		reportErr(err, "read stack metadata")
+	}
+
+	return stackConfig
+}
+
+// replace the stored Dispatch config of a cluster
+func writeStackMetadata(bucket string, name string, stackConfig map[string]string) {
+	data, err := encodeStackMetadata(stackConfig)
+	if err != nil {
+		reportErr(err, "construct stack metadata")
+	}
+
+	putStateObject(bucket, metadataKey(name), data, "write stack metadata")
+
+	delete(loadedSummaries, bucket)
+}
+
+// merge config values into the stored Dispatch config of a cluster, empty values are removed
+func updateStackMetadata(bucket string, name string, values map[string]string) map[string]string {
+	stackConfig := mergeStackConfig(readStackMetadata(bucket, name), values)
+
+	writeStackMetadata(bucket, name, stackConfig)
+
+	return stackConfig
+}
+
+func deleteStackMetadata(bucket string, name string) {
+	sess := getSession()
+
+	ctx, cancel := sess.requestContext()
+	defer cancel()
+
+	_, err := sess.stateStore(bucket).DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &bucket,
+		Key:    aws.String(metadataKey(name)),
+	})
+	if err != nil {
+		reportErr(err, "delete stack metadata")
+	}
+
+	delete(loadedSummaries, bucket)
+}
@@ -0,0 +1,80 @@
+package dispatch
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// stack config set in the inline workspace is not part of the checkpoint written by an update,
+// the stored metadata keeps cluster settings across updates
+func TestStackMetadataCheckpointRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("pulumi"); err != nil {
+		t.Skip("pulumi CLI not installed")
+	}
+
+	backend := t.TempDir()
+
+	t.Setenv("PULUMI_BACKEND_URL", "file://"+backend)
+	t.Setenv("PULUMI_CONFIG_PASSPHRASE", "Hello1234")
+	t.Setenv("PULUMI_SKIP_UPDATE_CHECK", "true")
+	t.Setenv("PULUMI_AUTOMATION_API_SKIP_VERSION_CHECK", "true")
+
+	ctx := context.Background()
+
+	stackConfig := map[string]string{protectedKey: "true", certManagerKey: certManagerDisabled}
+
+	program := func(ctx *pulumi.Context) error {
+		ctx.Export("kubeconfig", pulumi.String("{}"))
+
+		return nil
+	}
+
+	s, err := auto.UpsertStackInlineSource(ctx, "my-cluster-eks", pulumiProject("alice"), program)
+	if err != nil {
+		t.Fatalf("checkpoint round trip test failure\n error: '%v'", err)
+	}
+
+	restoreStackConfig(ctx, s, stackConfig)
+
+	if _, err := s.Up(ctx); err != nil {
+		t.Fatalf("checkpoint round trip test failure\n error: '%v'", err)
+	}
+
+	checkpoint, err := os.ReadFile(filepath.Join(backend, stackKey("my-cluster")))
+	if err != nil {
+		t.Fatalf("checkpoint round trip test failure\n error: '%v'", err)
+	}
+
+	summary, err := summarizeCheckpoint(stackObject{Key: stackKey("my-cluster")}, checkpoint)
+	if err != nil {
+		t.Fatalf("checkpoint round trip test failure\n error: '%v'", err)
+	}
+
+	if len(summary.Config) != 0 {
+		t.Errorf("checkpoint round trip test failure\n config unexpectedly kept in the checkpoint: '%v'", summary.Config)
+	}
+
+	metadata, err := encodeStackMetadata(stackConfig)
+	if err != nil {
+		t.Fatalf("checkpoint round trip test failure\n error: '%v'", err)
+	}
+
+	summary, err = applyStackMetadata(summary, stackObject{Key: metadataKey("my-cluster")}, metadata)
+	if err != nil {
+		t.Fatalf("checkpoint round trip test failure\n error: '%v'", err)
+	}
+
+	if !clusterProtected(summary) || storedCertManager(summary.Config).Enabled {
+		t.Errorf("checkpoint round trip test failure\n stored settings lost: '%v'", summary.Config)
+	}
+
+	if summary.Outputs["kubeconfig"] != "{}" {
+		t.Errorf("checkpoint round trip test failure\n outputs: '%v'", summary.Outputs)
+	}
+}
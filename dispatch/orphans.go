@@ -0,0 +1,608 @@
+package dispatch
+
+// detect and clean up Dispatch tagged AWS resources that no stack in the state store manages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscfn "github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	awseks "github.com/aws/aws-sdk-go-v2/service/eks"
+	awselb "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
+	awselbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
+	tagging "github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	tagtypes "github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+)
+
+const (
+	orphansAction     string        = "orphans"
+	createdByTag      string        = "Created by"
+	clusterTag        string        = "EKS cluster"
+	ownerTag          string        = "Owner"
+	dispatchTagValue  string        = "Dispatch"
+	globalRegion      string        = "global"
+	orphanWaitTimeout time.Duration = 20 * time.Minute
+)
+
+// resource kinds (<service>:<resource type>) in the order they can be deleted, dependents first
+var orphanDeleteOrder = []string{
+	"elasticloadbalancing:loadbalancer",
+	"cloudformation:stack",
+	"eks:nodegroup",
+	"eks:cluster",
+	"ec2:instance",
+	"ec2:natgateway",
+	"ec2:elastic-ip",
+	"ec2:network-interface",
+	"ec2:security-group",
+	"ec2:internet-gateway",
+	"ec2:route-table",
+	"ec2:subnet",
+	"ec2:vpc",
+	"iam:role",
+}
+
+type taggedResource struct {
+	ARN    string
+	Region string
+	Tags   map[string]string
+}
+
+type orphanedResource struct {
+	ARN     string `json:"arn"`
+	Kind    string `json:"kind"`
+	ID      string `json:"id"`
+	Cluster string `json:"cluster"`
+	Owner   string `json:"owner"`
+	Region  string `json:"region"`
+	Status  string `json:"status,omitempty"`
+}
+
+// resource kind and ID from an ARN (arn:<partition>:<service>:<region>:<account>:<type>/<id>)
+func parseResourceARN(arn string) (string, string) {
+	fields := strings.SplitN(arn, ":", 6)
+	if len(fields) < 6 {
+		return "", ""
+	}
+
+	resource := strings.SplitN(fields[5], "/", 2)
+	if len(resource) < 2 {
+		return fields[2] + ":" + fields[5], ""
+	}
+
+	id := resource[1]
+
+	// IAM roles are deleted by name, the ID of other resources includes their path
+	if fields[2] == "iam" {
+		id = id[strings.LastIndex(id, "/")+1:]
+	}
+
+	return fields[2] + ":" + resource[0], id
+}
+
+// position of a resource kind in the delete order, unknown kinds are deleted last
+func orphanOrder(kind string) int {
+	for i, orderedKind := range orphanDeleteOrder {
+		if kind == orderedKind {
+			return i
+		}
+	}
+
+	return len(orphanDeleteOrder)
+}
+
+// Dispatch tagged resources whose cluster has no stack in the state store, in delete order
+func findOrphans(resources []taggedResource, summaries map[string]stackSummary) []orphanedResource {
+	orphans := []orphanedResource{}
+	managed := map[string]bool{}
+
+	// resources are tagged with the cluster's pulumi resource ID
+	for name := range summaries {
+		managed[strings.ReplaceAll(name, ".", "-")] = true
+	}
+
+	for _, resource := range resources {
+		cluster := resource.Tags[clusterTag]
+
+		if resource.Tags[createdByTag] != dispatchTagValue || cluster == "" || managed[cluster] {
+			continue
+		}
+
+		kind, id := parseResourceARN(resource.ARN)
+
+		orphans = append(orphans, orphanedResource{
+			ARN:     resource.ARN,
+			Kind:    kind,
+			ID:      id,
+			Cluster: cluster,
+			Owner:   resource.Tags[ownerTag],
+			Region:  resource.Region,
+		})
+	}
+
+	sort.SliceStable(orphans, func(i, j int) bool {
+		if orphanOrder(orphans[i].Kind) != orphanOrder(orphans[j].Kind) {
+			return orphanOrder(orphans[i].Kind) < orphanOrder(orphans[j].Kind)
+		}
+
+		if orphans[i].Cluster != orphans[j].Cluster {
+			return orphans[i].Cluster < orphans[j].Cluster
+		}
+
+		return orphans[i].ARN < orphans[j].ARN
+	})
+
+	return orphans
+}
+
+// regions scanned for orphaned resources
+func orphanRegions(event Event) []string {
+	if !event.AllRegions {
+		if event.Region != "" {
+			return []string{event.Region}
+		}
+
+		return []string{setAWSRegion()}
+	}
+
+	sess := getSession()
+
+	ctx, cancel := sess.requestContext()
+	defer cancel()
+
+	output, err := sess.ec2().DescribeRegions(ctx, &awsec2.DescribeRegionsInput{})
+	if err != nil {
+		reportErr(err, "list AWS regions")
+	}
+
+	var regions []string
+
+	for _, region := range output.Regions {
+		regions = append(regions, aws.ToString(region.RegionName))
+	}
+
+	sort.Strings(regions)
+
+	return regions
+}
+
+// resource tag filter of the tagging API, a key without values matches any value
+type tagFilter struct {
+	Key    string
+	Values []string
+}
+
+func taggingFilters(filters []tagFilter) []tagtypes.TagFilter {
+	var tagFilters []tagtypes.TagFilter
+
+	for _, filter := range filters {
+		tagFilters = append(tagFilters, tagtypes.TagFilter{Key: aws.String(filter.Key), Values: filter.Values})
+	}
+
+	return tagFilters
+}
+
+func mappedResources(region string, mappings []tagtypes.ResourceTagMapping) []taggedResource {
+	var resources []taggedResource
+
+	for _, mapping := range mappings {
+		tags := map[string]string{}
+
+		for _, tag := range mapping.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+
+		resources = append(resources, taggedResource{ARN: aws.ToString(mapping.ResourceARN), Region: region, Tags: tags})
+	}
+
+	return resources
+}
+
+// regional resources matching every tag filter, optionally limited to resource types (e.g. ec2:vpc)
+func findTaggedResources(region string, filters []tagFilter, resourceTypes ...string) []taggedResource {
+	var resources []taggedResource
+
+	sess := getSession()
+	pages := tagging.NewGetResourcesPaginator(sess.tagging(), &tagging.GetResourcesInput{
+		TagFilters:          taggingFilters(filters),
+		ResourceTypeFilters: resourceTypes,
+	})
+
+	inRegion := func(o *tagging.Options) {
+		o.Region = region
+	}
+
+	for pages.HasMorePages() {
+		ctx, cancel := sess.requestContext()
+
+		page, err := pages.NextPage(ctx, inRegion)
+
+		cancel()
+
+		if err != nil {
+			reportErr(err, "list tagged resources in "+region)
+		}
+
+		resources = append(resources, mappedResources(region, page.ResourceTagMappingList)...)
+	}
+
+	return resources
+}
+
+// regional resources tagged by Dispatch for a user
+func regionTaggedResources(region string, user string) []taggedResource {
+	return findTaggedResources(region, []tagFilter{
+		{Key: createdByTag, Values: []string{dispatchTagValue}},
+		{Key: ownerTag, Values: []string{user}},
+	})
+}
+
+// IAM roles tagged by Dispatch for a user, IAM is global and not covered by the tagging API
+func taggedRoles(user string) []taggedResource {
+	var resources []taggedResource
+
+	sess := getSession()
+	roles := awsiam.NewListRolesPaginator(sess.iam(), &awsiam.ListRolesInput{})
+
+	for roles.HasMorePages() {
+		ctx, cancel := sess.requestContext()
+
+		page, err := roles.NextPage(ctx)
+
+		cancel()
+
+		if err != nil {
+			reportErr(err, "list IAM roles")
+		}
+
+		for _, role := range page.Roles {
+			ctx, cancel := sess.requestContext()
+
+			roleTags, err := sess.iam().ListRoleTags(ctx, &awsiam.ListRoleTagsInput{RoleName: role.RoleName})
+
+			cancel()
+
+			if err != nil {
+				reportErr(err, "list IAM role tags of "+aws.ToString(role.RoleName))
+			}
+
+			tags := map[string]string{}
+
+			for _, tag := range roleTags.Tags {
+				tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+
+			if tags[createdByTag] == dispatchTagValue && tags[ownerTag] == user {
+				resources = append(resources, taggedResource{ARN: aws.ToString(role.Arn), Region: globalRegion, Tags: tags})
+			}
+		}
+	}
+
+	return resources
+}
+
+// revoke ingress rules first, Dispatch security groups reference each other
+func deleteSecurityGroup(ctx context.Context, client *awsec2.Client, groupID string) error {
+	groups, err := client.DescribeSecurityGroups(ctx, &awsec2.DescribeSecurityGroupsInput{GroupIds: []string{groupID}})
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups.SecurityGroups {
+		if len(group.IpPermissions) > 0 {
+			if _, err := client.RevokeSecurityGroupIngress(ctx, &awsec2.RevokeSecurityGroupIngressInput{
+				GroupId:       group.GroupId,
+				IpPermissions: group.IpPermissions,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = client.DeleteSecurityGroup(ctx, &awsec2.DeleteSecurityGroupInput{GroupId: &groupID})
+
+	return err
+}
+
+func deleteInternetGateway(ctx context.Context, client *awsec2.Client, gatewayID string) error {
+	gateways, err := client.DescribeInternetGateways(ctx, &awsec2.DescribeInternetGatewaysInput{InternetGatewayIds: []string{gatewayID}})
+	if err != nil {
+		return err
+	}
+
+	for _, gateway := range gateways.InternetGateways {
+		for _, attachment := range gateway.Attachments {
+			if _, err := client.DetachInternetGateway(ctx, &awsec2.DetachInternetGatewayInput{
+				InternetGatewayId: &gatewayID,
+				VpcId:             attachment.VpcId,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = client.DeleteInternetGateway(ctx, &awsec2.DeleteInternetGatewayInput{InternetGatewayId: &gatewayID})
+
+	return err
+}
+
+// main route tables are deleted with their VPC
+func deleteRouteTable(ctx context.Context, client *awsec2.Client, tableID string) error {
+	tables, err := client.DescribeRouteTables(ctx, &awsec2.DescribeRouteTablesInput{RouteTableIds: []string{tableID}})
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables.RouteTables {
+		for _, association := range table.Associations {
+			if aws.ToBool(association.Main) {
+				return nil
+			}
+
+			if _, err := client.DisassociateRouteTable(ctx, &awsec2.DisassociateRouteTableInput{
+				AssociationId: association.RouteTableAssociationId,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = client.DeleteRouteTable(ctx, &awsec2.DeleteRouteTableInput{RouteTableId: &tableID})
+
+	return err
+}
+
+// detach and delete the role's policies and remove it from instance profiles before deleting it
+func deleteRole(ctx context.Context, client *awsiam.Client, roleName string) error {
+	attached, err := client.ListAttachedRolePolicies(ctx, &awsiam.ListAttachedRolePoliciesInput{RoleName: &roleName})
+	if err != nil {
+		return err
+	}
+
+	for _, policy := range attached.AttachedPolicies {
+		if _, err := client.DetachRolePolicy(ctx, &awsiam.DetachRolePolicyInput{RoleName: &roleName, PolicyArn: policy.PolicyArn}); err != nil {
+			return err
+		}
+	}
+
+	inline, err := client.ListRolePolicies(ctx, &awsiam.ListRolePoliciesInput{RoleName: &roleName})
+	if err != nil {
+		return err
+	}
+
+	for i := range inline.PolicyNames {
+		if _, err := client.DeleteRolePolicy(ctx, &awsiam.DeleteRolePolicyInput{RoleName: &roleName, PolicyName: &inline.PolicyNames[i]}); err != nil {
+			return err
+		}
+	}
+
+	profiles, err := client.ListInstanceProfilesForRole(ctx, &awsiam.ListInstanceProfilesForRoleInput{RoleName: &roleName})
+	if err != nil {
+		return err
+	}
+
+	for _, profile := range profiles.InstanceProfiles {
+		if _, err := client.RemoveRoleFromInstanceProfile(ctx, &awsiam.RemoveRoleFromInstanceProfileInput{
+			RoleName:            &roleName,
+			InstanceProfileName: profile.InstanceProfileName,
+		}); err != nil {
+			return err
+		}
+	}
+
+	_, err = client.DeleteRole(ctx, &awsiam.DeleteRoleInput{RoleName: &roleName})
+
+	return err
+}
+
+// EKS client of a region, waiters poll the region of the client they are created with
+func regionalEKS(sess *awsSession, region string) *awseks.Client {
+	return awseks.NewFromConfig(sess.config, func(o *awseks.Options) {
+		o.Region = region
+	})
+}
+
+// delete an orphaned resource and wait for resources that block the deletion of their dependencies
+func deleteOrphan(orphan orphanedResource) error {
+	sess := getSession()
+
+	ctx, cancel := context.WithTimeout(sess.ctx, orphanWaitTimeout)
+	defer cancel()
+
+	region := orphan.Region
+	client := awsec2.NewFromConfig(sess.config, func(o *awsec2.Options) {
+		o.Region = region
+	})
+
+	switch orphan.Kind {
+	case "elasticloadbalancing:loadbalancer":
+		// application and network load balancer IDs are prefixed with their type
+		if strings.HasPrefix(orphan.ID, "app/") || strings.HasPrefix(orphan.ID, "net/") {
+			_, err := sess.elbv2().DeleteLoadBalancer(ctx, &awselbv2.DeleteLoadBalancerInput{LoadBalancerArn: &orphan.ARN}, func(o *awselbv2.Options) {
+				o.Region = region
+			})
+
+			return err
+		}
+
+		_, err := sess.elb().DeleteLoadBalancer(ctx, &awselb.DeleteLoadBalancerInput{LoadBalancerName: &orphan.ID}, func(o *awselb.Options) {
+			o.Region = region
+		})
+
+		return err
+	case "cloudformation:stack":
+		stack := strings.Split(orphan.ID, "/")[0]
+		client := awscfn.NewFromConfig(sess.config, func(o *awscfn.Options) {
+			o.Region = region
+		})
+
+		if _, err := client.DeleteStack(ctx, &awscfn.DeleteStackInput{StackName: &stack}); err != nil {
+			return err
+		}
+
+		return awscfn.NewStackDeleteCompleteWaiter(client).Wait(ctx, &awscfn.DescribeStacksInput{StackName: &stack}, orphanWaitTimeout)
+	case "eks:nodegroup":
+		// node group IDs are <cluster>/<node group>/<uuid>
+		names := strings.Split(orphan.ID, "/")
+		if len(names) < 2 {
+			return fmt.Errorf("invalid node group ID %s", orphan.ID)
+		}
+
+		client := regionalEKS(sess, region)
+
+		if _, err := client.DeleteNodegroup(ctx, &awseks.DeleteNodegroupInput{ClusterName: &names[0], NodegroupName: &names[1]}); err != nil {
+			return err
+		}
+
+		return awseks.NewNodegroupDeletedWaiter(client).Wait(ctx, &awseks.DescribeNodegroupInput{ClusterName: &names[0], NodegroupName: &names[1]}, orphanWaitTimeout)
+	case "eks:cluster":
+		client := regionalEKS(sess, region)
+
+		if _, err := client.DeleteCluster(ctx, &awseks.DeleteClusterInput{Name: &orphan.ID}); err != nil {
+			return err
+		}
+
+		return awseks.NewClusterDeletedWaiter(client).Wait(ctx, &awseks.DescribeClusterInput{Name: &orphan.ID}, orphanWaitTimeout)
+	case "ec2:instance":
+		if _, err := client.TerminateInstances(ctx, &awsec2.TerminateInstancesInput{InstanceIds: []string{orphan.ID}}); err != nil {
+			return err
+		}
+
+		return awsec2.NewInstanceTerminatedWaiter(client).Wait(ctx, &awsec2.DescribeInstancesInput{InstanceIds: []string{orphan.ID}}, orphanWaitTimeout)
+	case "ec2:natgateway":
+		if _, err := client.DeleteNatGateway(ctx, &awsec2.DeleteNatGatewayInput{NatGatewayId: &orphan.ID}); err != nil {
+			return err
+		}
+
+		// NAT gateways hold their elastic IP and subnet until deleted
+		return awsec2.NewNatGatewayDeletedWaiter(client).Wait(ctx, &awsec2.DescribeNatGatewaysInput{NatGatewayIds: []string{orphan.ID}}, orphanWaitTimeout)
+	case "ec2:elastic-ip":
+		_, err := client.ReleaseAddress(ctx, &awsec2.ReleaseAddressInput{AllocationId: &orphan.ID})
+
+		return err
+	case "ec2:network-interface":
+		_, err := client.DeleteNetworkInterface(ctx, &awsec2.DeleteNetworkInterfaceInput{NetworkInterfaceId: &orphan.ID})
+
+		return err
+	case "ec2:security-group":
+		return deleteSecurityGroup(ctx, client, orphan.ID)
+	case "ec2:internet-gateway":
+		return deleteInternetGateway(ctx, client, orphan.ID)
+	case "ec2:route-table":
+		return deleteRouteTable(ctx, client, orphan.ID)
+	case "ec2:subnet":
+		_, err := client.DeleteSubnet(ctx, &awsec2.DeleteSubnetInput{SubnetId: &orphan.ID})
+
+		return err
+	case "ec2:vpc":
+		_, err := client.DeleteVpc(ctx, &awsec2.DeleteVpcInput{VpcId: &orphan.ID})
+
+		return err
+	case "iam:role":
+		return deleteRole(ctx, sess.iam(), orphan.ID)
+	default:
+		return fmt.Errorf("%s resources are not deleted by Dispatch, delete %s manually", orphan.Kind, orphan.ARN)
+	}
+}
+
+func printOrphans(orphans []orphanedResource, output string) {
+	if output == jsonOutput {
+		report, err := json.MarshalIndent(orphans, "", "  ")
+		if err != nil {
+			reportErr(err, "create orphaned resource report")
+		}
+
+		fmt.Fprintln(reportOutput, string(report))
+
+		return
+	}
+
+	for _, orphan := range orphans {
+		status := ""
+		if orphan.Status != "" {
+			status = " [" + orphan.Status + "]"
+		}
+
+		fmt.Printf("\t <> %-34s %-24s %-16s %s%s\n", orphan.Kind, orphan.Cluster, orphan.Region, orphan.ID, status)
+	}
+}
+
+// report resources tagged by Dispatch that no stack manages, and delete them when cleanup is requested
+func reportOrphans(event Event) {
+	var resources []taggedResource
+
+	for _, region := range orphanRegions(event) {
+		resources = append(resources, regionTaggedResources(region, event.User)...)
+	}
+
+	resources = append(resources, taggedRoles(event.User)...)
+
+	orphans := findOrphans(resources, getStackSummaries(event.Bucket))
+
+	if len(orphans) == 0 {
+		if event.Output == jsonOutput {
+			printOrphans(orphans, event.Output)
+		} else {
+			fmt.Print("\n . No orphaned resources found\n")
+		}
+
+		return
+	}
+
+	if !event.Cleanup {
+		if event.Output != jsonOutput {
+			fmt.Printf("\n - %d orphaned resources, run with -cleanup to delete them:\n", len(orphans))
+		}
+
+		printOrphans(orphans, event.Output)
+
+		return
+	}
+
+	if !event.Verified {
+		var approve string
+
+		fmt.Printf("\n - %d orphaned resources:\n", len(orphans))
+		printOrphans(orphans, "")
+
+		fmt.Printf("\n ? delete %d orphaned resources (y/n): ", len(orphans))
+		fmt.Scanf("%s", &approve)
+
+		if approve != "Y" && approve != "y" {
+			os.Exit(0)
+		}
+	}
+
+	failed := 0
+
+	// failures are reported and cleanup continues, resources depending on a failed deletion fail too
+	for i := range orphans {
+		fmt.Printf(" . deleting %s %s\n", orphans[i].Kind, orphans[i].ID)
+
+		if err := deleteOrphan(orphans[i]); err != nil {
+			fmt.Printf(" ! Failed to delete %s: %v\n", orphans[i].ARN, err)
+
+			orphans[i].Status = "failed"
+			failed++
+
+			continue
+		}
+
+		orphans[i].Status = "deleted"
+	}
+
+	printOrphans(orphans, event.Output)
+
+	if failed > 0 {
+		fmt.Printf("\n ! %d orphaned resources could not be deleted\n", failed)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,121 @@
+package dispatch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	tagtypes "github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+)
+
+func TestParseResourceARN(t *testing.T) {
+	tests := []struct {
+		name string
+		arn  string
+		kind string
+		id   string
+	}{
+		{name: "VPC", arn: "arn:aws:ec2:us-east-1:123456789012:vpc/vpc-0abc", kind: "ec2:vpc", id: "vpc-0abc"},
+		{name: "NAT gateway", arn: "arn:aws:ec2:us-east-1:123456789012:natgateway/nat-0abc", kind: "ec2:natgateway", id: "nat-0abc"},
+		{
+			name: "Node group",
+			arn:  "arn:aws:eks:us-east-1:123456789012:nodegroup/my-cluster/ng-1/9ec2",
+			kind: "eks:nodegroup",
+			id:   "my-cluster/ng-1/9ec2",
+		},
+		{
+			name: "Application load balancer",
+			arn:  "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/my-lb/50dc",
+			kind: "elasticloadbalancing:loadbalancer",
+			id:   "app/my-lb/50dc",
+		},
+		{name: "IAM role with path", arn: "arn:aws:iam::123456789012:role/dispatch/my-cluster-cert-manager", kind: "iam:role", id: "my-cluster-cert-manager"},
+		{name: "Invalid", arn: "vpc-0abc"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kind, id := parseResourceARN(test.arn)
+
+			if kind != test.kind || id != test.id {
+				t.Errorf("parseResourceARN unit test failure\n got: '%s %s', want: '%s %s'", kind, id, test.kind, test.id)
+			}
+		})
+	}
+}
+
+func TestFindOrphans(t *testing.T) {
+	tags := func(cluster string) map[string]string {
+		return map[string]string{createdByTag: dispatchTagValue, clusterTag: cluster, ownerTag: "tester"}
+	}
+
+	resources := []taggedResource{
+		{ARN: "arn:aws:ec2:us-east-1:123456789012:vpc/vpc-1", Region: "us-east-1", Tags: tags("gone")},
+		{ARN: "arn:aws:iam::123456789012:role/gone-cert-manager", Region: globalRegion, Tags: tags("gone")},
+		{ARN: "arn:aws:ec2:us-east-1:123456789012:natgateway/nat-1", Region: "us-east-1", Tags: tags("gone")},
+		{ARN: "arn:aws:ec2:us-east-1:123456789012:vpc/vpc-2", Region: "us-east-1", Tags: tags("my-cluster-v1")},
+		{ARN: "arn:aws:ec2:us-east-1:123456789012:vpc/vpc-3", Region: "us-east-1", Tags: map[string]string{createdByTag: dispatchTagValue}},
+		{ARN: "arn:aws:ec2:us-east-1:123456789012:subnet/subnet-1", Region: "us-east-1", Tags: tags("gone")},
+	}
+
+	// stack names with dots are tagged with dashes
+	summaries := map[string]stackSummary{"my-cluster.v1": {Name: "my-cluster.v1"}}
+
+	var got []string
+
+	for _, orphan := range findOrphans(resources, summaries) {
+		got = append(got, orphan.Kind+" "+orphan.ID)
+	}
+
+	want := []string{"ec2:natgateway nat-1", "ec2:subnet subnet-1", "ec2:vpc vpc-1", "iam:role gone-cert-manager"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findOrphans unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+}
+
+func TestMappedResources(t *testing.T) {
+	mappings := []tagtypes.ResourceTagMapping{
+		{
+			ResourceARN: aws.String("arn:aws:ec2:us-west-2:123456789012:vpc/vpc-1"),
+			Tags: []tagtypes.Tag{
+				{Key: aws.String(createdByTag), Value: aws.String(dispatchTagValue)},
+				{Key: aws.String(clusterTag), Value: aws.String("my-cluster")},
+			},
+		},
+	}
+
+	want := []taggedResource{
+		{
+			ARN:    "arn:aws:ec2:us-west-2:123456789012:vpc/vpc-1",
+			Region: "us-west-2",
+			Tags:   map[string]string{createdByTag: dispatchTagValue, clusterTag: "my-cluster"},
+		},
+	}
+
+	if got := mappedResources("us-west-2", mappings); !reflect.DeepEqual(got, want) {
+		t.Errorf("mappedResources unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+}
+
+func TestTaggingFilters(t *testing.T) {
+	filters := taggingFilters([]tagFilter{{Key: createdByTag, Values: []string{dispatchTagValue}}, {Key: kubernetesClusterTag + "my-cluster"}})
+
+	if len(filters) != 2 || aws.ToString(filters[0].Key) != createdByTag || !reflect.DeepEqual(filters[0].Values, []string{dispatchTagValue}) {
+		t.Errorf("taggingFilters unit test failure\n got: '%v'", filters)
+	}
+
+	// a key without values matches any value of the tag
+	if aws.ToString(filters[1].Key) != kubernetesClusterTag+"my-cluster" || filters[1].Values != nil {
+		t.Errorf("taggingFilters unit test failure\n got: '%v'", filters[1])
+	}
+}
+
+func TestOrphanOrder(t *testing.T) {
+	if orphanOrder("ec2:natgateway") >= orphanOrder("ec2:elastic-ip") || orphanOrder("ec2:subnet") >= orphanOrder("ec2:vpc") {
+		t.Errorf("orphanOrder unit test failure\n NAT gateways must be deleted before elastic IPs and subnets before VPCs")
+	}
+
+	if orphanOrder("ec2:launch-template") != len(orphanDeleteOrder) {
+		t.Errorf("orphanOrder unit test failure\n got: '%v', want: '%v'", orphanOrder("ec2:launch-template"), len(orphanDeleteOrder))
+	}
+}
@@ -0,0 +1,79 @@
+package dispatch
+
+// deletion protection for long-lived clusters
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	protectAction   string = "protect"
+	unprotectAction string = "unprotect"
+	protectedKey    string = "dispatch:protected"
+)
+
+func clusterProtected(summary stackSummary) bool {
+	return summary.Config[protectedKey] == "true"
+}
+
+// protection of a cluster read from its stored metadata rather than a cached summary
+func stackProtected(bucket string, name string) bool {
+	return readStackMetadata(bucket, name)[protectedKey] == "true"
+}
+
+// metadata values of a protect or unprotect event, unprotect removes the stored value
+func protectionConfig(action string) map[string]string {
+	if action == protectAction {
+		return map[string]string{protectedKey: "true"}
+	}
+
+	return map[string]string{protectedKey: ""}
+}
+
+func protectedErr(name string) error {
+	return fmt.Errorf("cluster %s is protected from deletion, run dispatch unprotect -name %s first", name, name)
+}
+
+// split clusters into those that can be deleted and those that are protected
+func partitionProtected(summaries []stackSummary) ([]stackSummary, []stackSummary) {
+	var unprotected, protected []stackSummary
+
+	for _, summary := range summaries {
+		if clusterProtected(summary) {
+			protected = append(protected, summary)
+
+			continue
+		}
+
+		unprotected = append(unprotected, summary)
+	}
+
+	return unprotected, protected
+}
+
+// set or clear deletion protection in the cluster's stored metadata
+func protectCluster(event Event) {
+	if _, found := getStackSummaries(event.Bucket)[event.Name]; !found {
+		fmt.Printf("\n %s was not found, exiting.\n\n", event.Name)
+		os.Exit(0)
+	}
+
+	protect := event.Action == protectAction
+
+	if stackProtected(event.Bucket, event.Name) == protect {
+		fmt.Printf("\n . %s is already %sed\n", event.Name, event.Action)
+
+		return
+	}
+
+	updateStackMetadata(event.Bucket, event.Name, protectionConfig(event.Action))
+
+	if protect {
+		fmt.Printf("\n - %s is protected from deletion\n", event.Name)
+
+		return
+	}
+
+	fmt.Printf("\n - %s deletion protection removed\n", event.Name)
+}
@@ -0,0 +1,71 @@
+package dispatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPartitionProtected(t *testing.T) {
+	summaries := []stackSummary{
+		{Name: "scratch", Config: map[string]string{}},
+		{Name: "shared-demo", Config: map[string]string{protectedKey: "true"}},
+		{Name: "unprotected", Config: map[string]string{protectedKey: "false"}},
+		{Name: "legacy"},
+	}
+
+	var got [2][]string
+
+	unprotected, protected := partitionProtected(summaries)
+
+	for i, clusters := range [][]stackSummary{unprotected, protected} {
+		for _, summary := range clusters {
+			got[i] = append(got[i], summary.Name)
+		}
+	}
+
+	want := [2][]string{{"scratch", "unprotected", "legacy"}, {"shared-demo"}}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("partitionProtected unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+}
+
+func TestProtectionConfig(t *testing.T) {
+	// checkpoints of earlier versions kept protection in their config
+	summary, err := summarizeCheckpoint(stackObject{Key: stackKey("my-cluster")}, []byte(testCheckpoint))
+	if err != nil {
+		t.Fatalf("protectionConfig unit test failure\n error: '%v'", err)
+	}
+
+	legacy := mergeStackConfig(summary.Config, map[string]string{protectedKey: "true"})
+
+	tests := []struct {
+		name     string
+		action   string
+		stored   map[string]string
+		expected bool
+	}{
+		{name: "Protect", action: protectAction, stored: map[string]string{regionConfigKey: "us-west-2"}, expected: true},
+		{name: "Unprotect", action: unprotectAction, stored: map[string]string{protectedKey: "true"}, expected: false},
+		{name: "Unprotect checkpoint config", action: unprotectAction, stored: legacy, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata, err := encodeStackMetadata(mergeStackConfig(test.stored, protectionConfig(test.action)))
+			if err != nil {
+				t.Fatalf("protectionConfig unit test failure\n error: '%v'", err)
+			}
+
+			// stored metadata takes precedence over the checkpoint rewritten by each update
+			protected, err := applyStackMetadata(summary, stackObject{Key: metadataKey("my-cluster")}, metadata)
+			if err != nil {
+				t.Fatalf("protectionConfig unit test failure\n error: '%v'", err)
+			}
+
+			if clusterProtected(protected) != test.expected {
+				t.Errorf("protectionConfig unit test failure\n got: '%v', want: '%v'", clusterProtected(protected), test.expected)
+			}
+		})
+	}
+}
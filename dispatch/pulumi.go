@@ -2,7 +2,6 @@ package dispatch
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -11,20 +10,21 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
+	pulumiec2 "github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/iam"
 	"github.com/pulumi/pulumi-awsx/sdk/go/awsx/ec2"
 	"github.com/pulumi/pulumi-eks/sdk/go/eks"
 	"github.com/pulumi/pulumi/sdk/v3/go/auto"
-	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
 	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
-func setPulumiEngine(bucket string) {
+func setPulumiEngine(bucket string, region string) {
 	fmt.Println("\nPulumi login to S3 backend....")
 
-	region := setAWSRegion()
+	// pulumi providers and the aws CLI target the cluster's region
 	os.Setenv("AWS_REGION", region)
 
 	path, pathSet := os.LookupEnv("PATH")
@@ -40,7 +40,7 @@ func setPulumiEngine(bucket string) {
 	pulumiPath := filepath.Join(home, ".dispatch", "bin", "pulumi", pulumiVersion, runtime.GOOS, "pulumi")
 	os.Setenv("PATH", path+":"+pulumiPath)
 
-	loginCMD := exec.Command("pulumi", "login", "s3://"+bucket)
+	loginCMD := exec.Command("pulumi", "login", "s3://"+bucket+"?region="+getSession().bucketRegion(bucket))
 
 	stdout, err := loginCMD.StdoutPipe()
 	if err != nil {
@@ -78,213 +78,511 @@ func getExportValue(export map[string]interface{}, field string) string {
 	return resource[field]
 }
 
-func Exec(event *Event) string {
-	var eksCertManagerRoleARN string
+// the inline program workspace is temporary, stored config provides the provider settings of each run
+func restoreStackConfig(ctx context.Context, s auto.Stack, stackConfig map[string]string) {
+	workspaceConfig := auto.ConfigMap{}
+
+	for key, value := range stackConfig {
+		workspaceConfig[key] = auto.ConfigValue{Value: value}
+	}
+
+	if err := s.SetAllConfig(ctx, workspaceConfig); err != nil {
+		reportErr(err, "restore pulumi stack config")
+	}
+}
+
+// requested cluster spec stored for cost estimates and later updates
+func clusterSpecConfig(event Event) map[string]string {
+	return map[string]string{
+		nodeSizeKey:  event.Size,
+		nodeCountKey: event.Count,
+		versionKey:   event.Version,
+	}
+}
+
+// inline program stack of a cluster with its stored config restored
+func selectPulumiStack(ctx context.Context, event Event, region string, stackConfig map[string]string, program pulumi.RunFunc) auto.Stack {
+	setPulumiEngine(event.Bucket, region)
+	os.Setenv("PULUMI_CONFIG_PASSPHRASE", "Hello1234")
+	os.Setenv("PULUMI_SKIP_UPDATE_CHECK", "true")
+
+	s, err := auto.UpsertStackInlineSource(ctx, event.Name+"-eks", pulumiProject(event.User), program)
+	if err != nil {
+		reportErr(err, "create inline source")
+	}
+
+	w := s.Workspace()
+
+	err = w.InstallPlugin(ctx, "aws", "v5.21.1")
+	if err != nil {
+		reportErr(err, "install pulumi plugins")
+	}
+
+	restoreStackConfig(ctx, s, stackConfig)
+
+	return s
+}
 
-	// deploy defines AWS resources managed by pulumi
-	deploy := func(ctx *pulumi.Context) error {
+// settings of the cluster program resolved from the event and the stored cluster metadata
+type clusterSettings struct {
+	owner           string
+	irsaNameSeed    string
+	tags            map[string]string
+	natStrategy     string
+	plane           controlPlane
+	access          []accessMapping
+	serviceAccounts []serviceAccountRole
+	certManager     certManagerSettings
+}
+
+// node group of the cluster program
+type nodeGroup struct {
+	instanceType string
+	minSize      int
+	maxSize      int
+}
+
+// node group of an event, existing clusters apply their stored spec first
+func clusterNodeGroup(event Event) (nodeGroup, error) {
+	if event.Count == "" || event.Size == "" {
+		return nodeGroup{}, fmt.Errorf("no node spec stored for cluster %s", event.Name)
+	}
+
+	minSize, err := strconv.Atoi(event.Count)
+	if err != nil {
+		return nodeGroup{}, fmt.Errorf("get cluster node count: %w", err)
+	}
+
+	instanceType, err := getNodeSize(event.Size)
+	if err != nil {
+		return nodeGroup{}, err
+	}
+
+	return nodeGroup{instanceType: instanceType, minSize: minSize, maxSize: minSize + defaultScale}, nil
+}
+
+// clusterProgram defines AWS resources managed by pulumi
+func clusterProgram(event Event, settings clusterSettings) pulumi.RunFunc {
+	return func(ctx *pulumi.Context) error {
 		eksID := strings.ReplaceAll(event.Name, ".", "-")
 
 		// Set cluster values
-		minClusterSize, err := strconv.Atoi(event.Count)
+		nodes, err := clusterNodeGroup(event)
 		if err != nil {
-			reportErr(err, "get cluster node count")
+			return err
 		}
 
-		maxClusterSize := minClusterSize + defaultScale
-
-		eksNodeInstanceType, err := getNodeSize(event.Size)
-		if err != nil {
-			reportErr(err, "get node instance type")
+		// create and clone set the version, updates run with the stored version
+		version := event.Version
+		if version == "" {
+			version = k8sVersion
 		}
 
 		vpcNetworkCidr := "10.0.0.0/16"
 
-		// Create a new VPC, subnets, and associated infrastructure
-		eksVpc, err := ec2.NewVpc(ctx, eksID, &ec2.VpcArgs{
+		// user tags are provider default tags, Dispatch tags are set on each resource
+		clusterTags := pulumi.ToStringMap(dispatchTags(settings.owner, eksID))
+
+		vpcArgs := &ec2.VpcArgs{
 			EnableDnsHostnames: pulumi.Bool(true),
 			CidrBlock:          &vpcNetworkCidr,
-			Tags: pulumi.StringMap{
-				"Owner":       pulumi.String(event.User),
-				"EKS cluster": pulumi.String(eksID),
-				"Created by":  pulumi.String("Dispatch"),
-			},
-		})
+			Tags:               clusterTags,
+		}
+
+		// NAT gateways are reduced while a cluster sleeps
+		if settings.natStrategy != "" {
+			vpcArgs.NatGateways = &ec2.NatGatewayConfigurationArgs{Strategy: ec2.NatGatewayStrategy(settings.natStrategy)}
+		}
+
+		// Create a new VPC, subnets, and associated infrastructure
+		eksVpc, err := ec2.NewVpc(ctx, eksID, vpcArgs)
 		if err != nil {
-			reportErr(err, "create AWS VPC")
+			return fmt.Errorf("create AWS VPC: %w", err)
 		}
 
-		// Create a new EKS cluster
-		eksCluster, err := eks.NewCluster(ctx, eksID, &eks.ClusterArgs{
-			Version: pulumi.String(k8sVersion),
+		clusterArgs := &eks.ClusterArgs{
+			Version: pulumi.String(version),
 			// Put the cluster in the new VPC created earlier
 			VpcId: eksVpc.VpcId,
 			// Public subnets will be used for load balancers
 			PublicSubnetIds: eksVpc.PublicSubnetIds,
 			// Private subnets will be used for cluster nodes
 			PrivateSubnetIds: eksVpc.PrivateSubnetIds,
-			// Cluster settings
-			InstanceType:    pulumi.String(eksNodeInstanceType),
-			DesiredCapacity: pulumi.Int(minClusterSize),
-			MinSize:         pulumi.Int(minClusterSize),
-			MaxSize:         pulumi.Int(maxClusterSize),
+			// nodes are launched by the node group below
+			SkipDefaultNodeGroup: pulumi.BoolRef(true),
 			// OIDC provider for IAM RBAC
 			CreateOidcProvider: pulumi.BoolPtr(true),
+			Tags:               clusterTags,
+		}
+
+		setAccessArgs(clusterArgs, settings.access)
+
+		// endpoint access, control plane logging and secrets encryption
+		clusterOpts, err := setControlPlaneArgs(ctx, clusterArgs, settings.plane, eksID, clusterTags)
+		if err != nil {
+			return fmt.Errorf("configure EKS control plane: %w", err)
+		}
+
+		// Create a new EKS cluster
+		eksCluster, err := eks.NewCluster(ctx, eksID, clusterArgs, clusterOpts...)
+		if err != nil {
+			return fmt.Errorf("create EKS cluster: %w", err)
+		}
+
+		nodeTags := pulumi.ToStringMap(mergeTags(settings.tags, dispatchTags(settings.owner, eksID)))
+
+		// provider default tags don't reach instances and volumes, the launch template tags them at launch
+		_, err = eks.NewNodeGroupV2(ctx, eksID+"-nodes", &eks.NodeGroupV2Args{
+			Cluster:         eksCluster.Core,
+			InstanceType:    pulumi.String(nodes.instanceType),
+			DesiredCapacity: pulumi.Int(nodes.minSize),
+			MinSize:         pulumi.Int(nodes.minSize),
+			MaxSize:         pulumi.Int(nodes.maxSize),
 			// Do not give the worker nodes a public IP address
 			NodeAssociatePublicIpAddress: pulumi.BoolRef(false),
-			Tags: pulumi.StringMap{
-				"Owner":       pulumi.String(event.User),
-				"EKS cluster": pulumi.String(eksID),
-				"Created by":  pulumi.String("Dispatch"),
+			AutoScalingGroupTags:         nodeTags,
+			LaunchTemplateTagSpecifications: pulumiec2.LaunchTemplateTagSpecificationArray{
+				pulumiec2.LaunchTemplateTagSpecificationArgs{ResourceType: pulumi.String("instance"), Tags: nodeTags},
+				pulumiec2.LaunchTemplateTagSpecificationArgs{ResourceType: pulumi.String("volume"), Tags: nodeTags},
 			},
 		})
 		if err != nil {
-			reportErr(err, "create EKS cluster")
+			return fmt.Errorf("create EKS node group: %w", err)
 		}
 
 		oidcARN := eksCluster.Core.OidcProvider().ApplyT(func(oidc *iam.OpenIdConnectProvider) pulumi.StringOutput {
 			return oidc.Arn
 		}).(pulumi.StringOutput)
 
-		oidcPolicyURL := eksCluster.Core.OidcProvider().ApplyT(func(oidc *iam.OpenIdConnectProvider) pulumi.StringOutput {
-			return pulumi.Sprintf("%v:sub", oidc.Url)
+		oidcURL := eksCluster.Core.OidcProvider().ApplyT(func(oidc *iam.OpenIdConnectProvider) pulumi.StringOutput {
+			return oidc.Url
 		}).(pulumi.StringOutput)
 
-		// cert-manager IRSA
-		// cert-manager role trust policy
-		certManagerTrustPolicy := iam.GetPolicyDocumentOutput(ctx, iam.GetPolicyDocumentOutputArgs{
-			Statements: iam.GetPolicyDocumentStatementArray{
-				iam.GetPolicyDocumentStatementArgs{
-					Sid:    pulumi.String(""),
-					Effect: pulumi.String("Allow"),
-					Principals: iam.GetPolicyDocumentStatementPrincipalArray{
-						iam.GetPolicyDocumentStatementPrincipalArgs{
-							Type:        pulumi.String("Federated"),
-							Identifiers: pulumi.ToStringArrayOutput([]pulumi.StringOutput{oidcARN}),
-						},
-					},
-					Actions: pulumi.ToStringArrayOutput([]pulumi.StringOutput{pulumi.Sprintf("sts:AssumeRoleWithWebIdentity")}),
-					Conditions: iam.GetPolicyDocumentStatementConditionArray{
-						iam.GetPolicyDocumentStatementConditionArgs{
-							Test:     pulumi.String("StringEquals"),
-							Variable: oidcPolicyURL,
-							Values:   pulumi.ToStringArrayOutput([]pulumi.StringOutput{pulumi.Sprintf("system:serviceaccount:cert-manager:cert-manager")}),
-						},
-					},
-				},
-			},
-		})
-
-		// cert-manager Role
-		certManagerRole, err := iam.NewRole(ctx, eksID+"-cert-manager", &iam.RoleArgs{
-			AssumeRolePolicy: certManagerTrustPolicy.Json(),
-			Tags: pulumi.StringMap{
-				"Owner":       pulumi.String(event.User),
-				"EKS cluster": pulumi.String(eksID),
-				"Created by":  pulumi.String("Dispatch"),
-			},
-		})
+		// user declared service account roles
+		roleARNs, err := deployServiceAccountRoles(ctx, eksID, settings.irsaNameSeed, settings.serviceAccounts, oidcARN, oidcURL, clusterTags)
 		if err != nil {
-			reportErr(err, "create cert-manager IAM assume role")
-		}
-
-		// ACME DNS01 policy for cert-manager role
-		acmeDNS01PolicyJSON, err := json.Marshal(map[string]interface{}{
-			"Version": "2012-10-17",
-			"Statement": []map[string]interface{}{
-				{
-					"Effect": "Allow",
-					"Action": []string{
-						"route53:GetChange",
-					},
-					"Resource": "arn:aws:route53:::change/*",
-				},
-				{
-					"Effect": "Allow",
-					"Action": []string{
-						"route53:ChangeResourceRecordSets",
-						"route53:ListResourceRecordSets",
-					},
-					"Resource": "arn:aws:route53:::hostedzone/*",
-				},
-				{
-					"Effect": "Allow",
-					"Action": []string{
-						"route53:ListHostedZonesByName",
-					},
-					"Resource": "*",
-				},
-			},
-		})
-		if err != nil {
-			reportErr(err, "create cert-manager inline policy")
+			return fmt.Errorf("create service account IAM roles: %w", err)
 		}
 
-		acmePolicyString := string(acmeDNS01PolicyJSON)
-
-		_, err = iam.NewRolePolicy(ctx, eksID+"-acme-dns01", &iam.RolePolicyArgs{
-			Role:   certManagerRole.Name,
-			Policy: pulumi.String(acmePolicyString),
-		})
-		if err != nil {
-			reportErr(err, "create ACME DNS01 policy")
+		// cert-manager IRSA
+		if settings.certManager.Enabled {
+			certManagerRole, err := newServiceAccountRole(ctx, eksID+"-cert-manager", &iam.RoleArgs{
+				Tags: clusterTags,
+			}, certManagerServiceAccount, oidcARN, oidcURL)
+			if err != nil {
+				return fmt.Errorf("create cert-manager IAM assume role: %w", err)
+			}
+
+			// ACME DNS01 policy for cert-manager role
+			acmePolicyString, err := certManagerPolicy(settings.certManager.ZoneID)
+			if err != nil {
+				return fmt.Errorf("create cert-manager inline policy: %w", err)
+			}
+
+			_, err = iam.NewRolePolicy(ctx, eksID+"-acme-dns01", &iam.RolePolicyArgs{
+				Role:   certManagerRole.Name,
+				Policy: pulumi.String(acmePolicyString),
+			})
+			if err != nil {
+				return fmt.Errorf("create ACME DNS01 policy: %w", err)
+			}
+
+			roleARNs[certManagerServiceAccount] = certManagerRole.Arn
+
+			ctx.Export(certManagerRoleOutput, certManagerRole.Arn)
 		}
 
-		if event.Action == createAction {
-			ctx.Export("cluster", eksCluster.Core.Cluster())
-			ctx.Export("cert-manager-role-arn", certManagerRole.Arn)
-		}
+		// outputs are exported by every update so they stay in the stack state
+		ctx.Export("cluster", eksCluster.Core.Cluster())
+		ctx.Export(irsaRolesOutput, roleARNs)
 
 		return nil
 	}
+}
+
+func Exec(event *Event) string {
+	var eksCertManagerRoleARN string
 
-	if event.Action == deleteAction {
+	if event.Action != createAction && event.Action != adoptAction {
 		if !clusterExists(*event) {
 			fmt.Printf("\n %s was not found, exiting.\n\n", event.Name)
 			os.Exit(0)
 		}
 	}
 
-	setPulumiEngine(event.Bucket)
-	os.Setenv("PULUMI_CONFIG_PASSPHRASE", "Hello1234")
-	os.Setenv("PULUMI_SKIP_UPDATE_CHECK", "true")
+	summaries := getStackSummaries(event.Bucket)
+	region := clusterRegion(*event, summaries[event.Name])
 
-	ctx := context.Background()
+	if event.Region != "" && event.Region != region {
+		fmt.Printf(" ! %s exists in %s, ignoring region %s\n", event.Name, region, event.Region)
+	}
 
-	projectID := event.User + "-dispatch"
-	stackID := event.Name + "-eks"
+	event.Region = region
 
-	s, err := auto.UpsertStackInlineSource(ctx, stackID, projectID, deploy)
-	if err != nil {
-		reportErr(err, "create inline source")
+	summary := summaries[event.Name]
+	stackConfig := summary.Config
+
+	if event.Action == deleteAction && stackProtected(event.Bucket, event.Name) {
+		reportErr(protectedErr(event.Name), "delete cluster "+event.Name)
 	}
 
-	w := s.Workspace()
+	adopted, isAdopted := storedAdoption(stackConfig)
 
-	err = w.InstallPlugin(ctx, "aws", "v5.21.1")
-	if err != nil {
-		reportErr(err, "install pulumi plugins")
+	if event.Action == adoptAction {
+		if _, exists := summaries[event.Name]; exists {
+			reportErr(fmt.Errorf("cluster %s already exists", event.Name), "adopt EKS cluster "+event.EKSCluster)
+		}
+
+		adopted, isAdopted = discoverCluster(event.EKSCluster, region), true
 	}
 
-	region := setAWSRegion()
+	if event.Action == accessAction && isAdopted {
+		reportErr(fmt.Errorf("access mappings of adopted cluster %s are not managed by Dispatch", event.Name), "update cluster access")
+	}
 
-	if err := s.SetConfig(ctx, "aws:region", auto.ConfigValue{Value: region}); err != nil {
-		reportErr(err, "set pulumi config")
+	if event.Action == irsaAction && isAdopted {
+		reportErr(fmt.Errorf("service account roles of adopted cluster %s are not managed by Dispatch", event.Name), "update service account roles")
 	}
 
-	_, err = s.Refresh(ctx)
+	if isAdopted && event.SleepNAT {
+		fmt.Print(" ! NAT gateways of adopted clusters are not managed by Dispatch, ignoring -nat\n")
+
+		event.SleepNAT = false
+	}
+
+	// config file default tags, then the tags of a cloned cluster, then tag flags
+	tags := mergeTags(event.DefaultTags, clusterTags(summaries[event.CloneFrom]), event.Tags)
+
+	access := storedAccessMappings(stackConfig)
+	serviceAccounts := storedServiceAccountRoles(stackConfig)
+
+	// Dispatch config changed by this event, stored once the event is confirmed
+	changes := map[string]string{regionConfigKey: region}
+
+	change := func(values map[string]string) {
+		for key, value := range values {
+			changes[key] = value
+		}
+	}
+
+	if event.Action == createAction {
+		if err := validateTags(tags); err != nil {
+			reportErr(err, "set cluster tags")
+		}
+
+		change(lifecycleConfig(*event, summary))
+		change(clusterSpecConfig(*event))
+		change(tagsConfig(tags))
+
+		if event.CloneFrom != "" {
+			change(cloneConfig(summaries[event.CloneFrom]))
+		}
+
+		change(createControlPlaneConfig(*event, summaries[event.CloneFrom]))
+
+		// config file mappings, then the mappings of a cloned cluster, then admin flags
+		access = mergeAccessMappings(event.DefaultAccess, storedAccessMappings(summaries[event.CloneFrom].Config), event.AccessMappings)
+
+		if err := validateAccessMappings(access); err != nil {
+			reportErr(err, "set cluster access mappings")
+		}
+
+		change(accessConfig(access))
+
+		// service account roles of a cloned cluster, then the IRSA spec file
+		serviceAccounts = mergeServiceAccountRoles(storedServiceAccountRoles(summaries[event.CloneFrom].Config), event.ServiceAccounts)
+
+		change(serviceAccountsConfig(serviceAccounts))
+		change(createCertManagerConfig(*event, summaries[event.CloneFrom]))
+	}
+
+	if event.Action == accessAction {
+		changed, err := changedAccessMappings(*event, access)
+		if err != nil {
+			reportErr(err, "update cluster access")
+		}
+
+		access = changed
+		change(accessConfig(access))
+	}
+
+	if event.Action == irsaAction {
+		changed, err := changedServiceAccountRoles(*event, serviceAccounts)
+		if err != nil {
+			reportErr(err, "update service account roles")
+		}
+
+		serviceAccounts = changed
+		change(serviceAccountsConfig(serviceAccounts))
+	}
+
+	if event.Action == adoptAction {
+		change(adoptConfig(*event, adopted))
+	}
+
+	if event.Action == sleepAction || event.Action == wakeAction {
+		change(hibernationConfig(*event, summary))
+	}
+
+	// fixed AWS names are derived once, clusters created before they were stored keep the names of their owner
+	nameUser := event.User
+	if event.Action != createAction && summary.Config[ownerConfigKey] != "" {
+		nameUser = summary.Config[ownerConfigKey]
+	}
+
+	change(clusterNameConfig(strings.ReplaceAll(event.Name, ".", "-"), nameUser, mergeStackConfig(summary.Config, changes)))
+	change(irsaNameConfig(nameUser, summary.Config))
+
+	stackConfig = mergeStackConfig(summary.Config, changes)
+
+	// updates of existing clusters run the program with their stored node spec
+	if event.Action != createAction {
+		applyStoredSpec(event, stackConfig)
+	}
+
+	plane := storedControlPlane(stackConfig)
+	certManager := storedCertManager(stackConfig)
+	natStrategy := stackConfig[natGatewaysKey]
+
+	// clusters created before the owner was stored are tagged with the user updating them
+	owner := stackConfig[ownerConfigKey]
+	if owner == "" {
+		owner = event.User
+	}
+
+	program := clusterProgram(*event, clusterSettings{
+		owner:           owner,
+		irsaNameSeed:    stackConfig[irsaNameSeedKey],
+		tags:            clusterTags(stackSummary{Config: stackConfig}),
+		natStrategy:     natStrategy,
+		plane:           plane,
+		access:          access,
+		serviceAccounts: serviceAccounts,
+		certManager:     certManager,
+	})
+
+	if isAdopted {
+		program = func(ctx *pulumi.Context) error {
+			return deployAdopted(ctx, *event, adopted, stackConfig[nodeCountKey] == "0")
+		}
+	} else if event.Action != deleteAction {
+		if _, err := clusterNodeGroup(*event); err != nil {
+			reportErr(err, event.Action+" cluster "+event.Name)
+		}
+	}
+
+	// pulumi receives Ctrl-C directly and cancels its own operations
+	getSession().releaseInterrupts()
+
+	ctx := context.Background()
+
+	projectID := pulumiProject(event.User)
+	stackID := event.Name + "-eks"
+
+	s := selectPulumiStack(ctx, *event, region, stackConfig, program)
+
+	refresh, err := s.Refresh(ctx)
 	if err != nil {
 		reportErr(err, "to refresh stack")
 	}
 
-	if !event.Verified {
+	if drifted := refreshedChanges(refresh.Summary); drifted > 0 {
+		fmt.Printf(" ! %d resources changed outside Dispatch were refreshed into the stack state, see dispatch drift -h\n", drifted)
+	}
+
+	if !event.Verified && event.Action == deleteAction {
+		details := append(clusterDetails(summary, loadPricing(), time.Now()),
+			"AWS region: "+region,
+			"Pulumi project: "+projectID,
+			"Pulumi stack: "+stackID,
+		)
+
+		if failure, found := storedDeleteFailure(stackConfig); found {
+			details = append(details, fmt.Sprintf("Resuming delete, %d attempts failed, last at %s: %s", failure.Attempts, failure.FailedAt, failure.Error))
+		}
+
+		if !confirmDelete(*event, details) {
+			fmt.Printf("\n . %s was not deleted, the typed name did not match\n\n", event.Name)
+			os.Exit(0)
+		}
+	} else if !event.Verified {
 		var approve string
 
 		fmt.Printf("\n Cluster name: %s\n", event.Name)
 
 		if event.Action == createAction {
+			if event.CloneFrom != "" {
+				fmt.Printf(" Cloned from: %s\n", event.CloneFrom)
+			}
+
 			fmt.Printf(" Cluster node size: %s\n", event.Size)
 			fmt.Printf(" Cluster node count: %s\n", event.Count)
+
+			if event.TTL != "" {
+				fmt.Printf(" Cluster time-to-live: %s\n", event.TTL)
+			}
+
+			if event.Protect {
+				fmt.Print(" Deletion protection: enabled\n")
+			}
+
+			if len(tags) > 0 {
+				fmt.Printf(" Cluster tags: %s\n", formatTags(tags))
+			}
+
+			printControlPlane(plane)
+
+			if len(access) > 0 {
+				fmt.Print(" Access mappings:\n")
+				printAccessMappings(access)
+			}
+
+			printCertManager(certManager)
+
+			if len(serviceAccounts) > 0 {
+				fmt.Print(" Service account roles:\n")
+				printServiceAccountRoles(serviceAccounts, nil)
+			}
+
+			previewCost(plannedResources(*event, region))
+		}
+
+		if event.Action == adoptAction {
+			printAdoptedCluster(adopted)
+		}
+
+		if event.Action == accessAction {
+			fmt.Printf(" Access mappings after %s %s:\n", event.Subcommand, event.PrincipalARN)
+
+			if len(access) == 0 {
+				fmt.Print("\t no additional IAM roles or users are mapped\n")
+			}
+
+			printAccessMappings(access)
+		}
+
+		if event.Action == irsaAction {
+			fmt.Printf(" Service account roles after %s %s:\n", event.Subcommand, event.ServiceAccount)
+
+			if len(serviceAccounts) == 0 {
+				fmt.Print("\t no service account roles besides cert-manager\n")
+			}
+
+			printServiceAccountRoles(serviceAccounts, irsaRoleARNs(summary))
+		}
+
+		if event.Action == sleepAction || event.Action == wakeAction {
+			fmt.Printf(" Cluster node count: %s -> %s\n", summary.Config[nodeCountKey], event.Count)
+
+			if natStrategy != summary.Config[natGatewaysKey] {
+				fmt.Printf(" NAT gateways: %s\n", natStrategy)
+			}
+
+			previewCost(scaledResources(summary, stackConfig))
+
+			if event.Action == sleepAction {
+				fmt.Printf(" ! Asleep, %s\n", sleepBilling(scaledResources(summary, stackConfig)))
+			}
 		}
 
 		fmt.Printf(" AWS region: %s\n", region)
@@ -299,6 +597,15 @@ func Exec(event *Event) string {
 		}
 	}
 
+	// config is stored ahead of the update so failed updates are retried with the requested settings
+	switch event.Action {
+	case createAction, adoptAction:
+		writeStackMetadata(event.Bucket, event.Name, stackConfig)
+	case deleteAction:
+	default:
+		updateStackMetadata(event.Bucket, event.Name, changes)
+	}
+
 	switch event.Action {
 	case "create":
 		stdoutStreamer := optup.ProgressStreams(os.Stdout)
@@ -312,28 +619,80 @@ func Exec(event *Event) string {
 
 		clusterID := getExportValue(expCluster, "id")
 
-		kubeConfigPath := setEKSConfig(clusterID, event.Name)
+		kubeConfigPath := setEKSConfig(clusterID, event.Name, region)
 
-		eksCertManagerRoleARN = res.Outputs["cert-manager-role-arn"].Value.(string)
+		arns := updatedRoleARNs(res.Outputs)
+		eksCertManagerRoleARN = arns[certManagerServiceAccount]
+
+		if roles := clusterServiceAccountRoles(serviceAccounts, arns); len(roles) > 0 {
+			fmt.Print("\n Service account IAM roles, annotate each service account with eks.amazonaws.com/role-arn:\n")
+			printServiceAccountRoles(roles, arns)
+		}
 
 		fmt.Printf("\n Run the following command for kubectl access to EKS cluster %s:\n", event.Name)
 		fmt.Printf(" export KUBECONFIG='%s'\n\n", kubeConfigPath)
+	case adoptAction:
+		stdoutStreamer := optup.ProgressStreams(os.Stdout)
+
+		if _, err := s.Up(ctx, stdoutStreamer); err != nil {
+			reportErr(err, "import EKS cluster "+event.EKSCluster)
+		}
+
+		kubeConfigPath := setEKSConfig(adopted.Name, event.Name, region)
+
+		fmt.Printf("\n - EKS cluster %s adopted as %s\n", adopted.Name, event.Name)
+		fmt.Printf("\n Run the following command for kubectl access to EKS cluster %s:\n", event.Name)
+		fmt.Printf(" export KUBECONFIG='%s'\n\n", kubeConfigPath)
+	case sleepAction, wakeAction:
+		stdoutStreamer := optup.ProgressStreams(os.Stdout)
+
+		if _, err := s.Up(ctx, stdoutStreamer); err != nil {
+			reportErr(err, event.Action+" cluster "+event.Name)
+		}
+
+		fmt.Printf("\n - %s node count scaled to %s\n", event.Name, event.Count)
+
+		if event.Action == sleepAction {
+			fmt.Printf(" ! %s is asleep, %s\n", event.Name, sleepBilling(scaledResources(summary, stackConfig)))
+		}
+	case accessAction:
+		stdoutStreamer := optup.ProgressStreams(os.Stdout)
+
+		if _, err := s.Up(ctx, stdoutStreamer); err != nil {
+			reportErr(err, "update access of cluster "+event.Name)
+		}
+
+		fmt.Printf("\n - %s access mappings updated, %d IAM roles and users mapped\n", event.Name, len(access))
+	case irsaAction:
+		stdoutStreamer := optup.ProgressStreams(os.Stdout)
+
+		res, err := s.Up(ctx, stdoutStreamer)
+		if err != nil {
+			reportErr(err, "update service account roles of cluster "+event.Name)
+		}
+
+		arns := updatedRoleARNs(res.Outputs)
+
+		fmt.Printf("\n - %s service account roles updated:\n", event.Name)
+		printServiceAccountRoles(clusterServiceAccountRoles(serviceAccounts, arns), arns)
 	case "delete":
-		// wire up our destroy to stream progress to stdout
-		stdoutStreamer := optdestroy.ProgressStreams(os.Stdout)
+		failure, resuming := storedDeleteFailure(stackConfig)
 
-		// destroy our stack and exit early
-		_, err := s.Destroy(ctx, stdoutStreamer)
+		// the stack is only removed once its destroy leaves no resources in state
+		attempts, remaining, err := destroyCluster(ctx, s, *event, region, resuming)
 		if err != nil {
-			fmt.Printf("Failed to destroy stack: %v", err)
+			recordDeleteFailure(*event, failure, attempts, remaining, err)
+			os.Exit(1)
 		}
 
 		fmt.Printf("%s stack successfully destroyed\n", stackID)
 
-		if err := w.RemoveStack(ctx, stackID); err != nil {
+		if err := s.Workspace().RemoveStack(ctx, stackID); err != nil {
 			reportErr(err, "remove stack")
 		}
 
+		deleteStackMetadata(event.Bucket, event.Name)
+
 		fmt.Printf(" - stack %s removed from S3 backend state\n", stackID)
 
 		ClearKubeConfig()
@@ -0,0 +1,198 @@
+package dispatch
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// resources registered by a program run with pulumi mocks, keyed by type and name
+type programMocks struct {
+	mu        sync.Mutex
+	project   string
+	stack     string
+	resources map[string]resource.PropertyMap
+}
+
+func newProgramMocks(event Event) *programMocks {
+	return &programMocks{
+		project:   pulumiProject(event.User),
+		stack:     event.Name + "-eks",
+		resources: map[string]resource.PropertyMap{},
+	}
+}
+
+func (m *programMocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.resources[args.TypeToken+"::"+args.Name] = args.Inputs
+
+	outputs := args.Inputs.Copy()
+
+	// the cluster component's core data references its OIDC provider
+	if args.TypeToken == "eks:index:Cluster" {
+		oidcURN := resource.CreateURN(args.Name+"-oidc", "aws:iam/openIdConnectProvider:OpenIdConnectProvider", "", m.project, m.stack)
+
+		outputs["core"] = resource.NewObjectProperty(resource.PropertyMap{
+			"oidcProvider": resource.MakeCustomResourceReference(oidcURN, resource.ID(args.Name+"-oidc-id"), ""),
+		})
+	}
+
+	return args.Name + "-id", outputs, nil
+}
+
+func (m *programMocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// run the cluster program of an event with pulumi mocks
+func runClusterProgram(t *testing.T, event Event, settings clusterSettings) map[string]resource.PropertyMap {
+	mocks := newProgramMocks(event)
+
+	// the OIDC provider referenced by the mocked cluster component is registered ahead of the program
+	program := func(ctx *pulumi.Context) error {
+		_, err := iam.NewOpenIdConnectProvider(ctx, event.Name+"-oidc", &iam.OpenIdConnectProviderArgs{
+			Url:             pulumi.String("https://oidc.eks.us-west-2.amazonaws.com/id/1A2B3C"),
+			ClientIdLists:   pulumi.StringArray{pulumi.String("sts.amazonaws.com")},
+			ThumbprintLists: pulumi.StringArray{pulumi.String("9e99a48a9960b14926bb7f3b02e22da2b0ab7280")},
+		})
+		if err != nil {
+			return err
+		}
+
+		return clusterProgram(event, settings)(ctx)
+	}
+
+	err := pulumi.RunErr(program, pulumi.WithMocks(mocks.project, mocks.stack, mocks))
+	if err != nil {
+		t.Fatalf("cluster program test failure\n error: '%v'", err)
+	}
+
+	return mocks.resources
+}
+
+func TestClusterNodeGroup(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  nodeGroup
+		err   bool
+	}{
+		{name: "Node spec", event: Event{Name: "my-cluster", Size: "small", Count: "2"}, want: nodeGroup{instanceType: smallEC2, minSize: 2, maxSize: 2 + defaultScale}},
+		{name: "Sleeping cluster", event: Event{Name: "my-cluster", Size: "large", Count: "0"}, want: nodeGroup{instanceType: largeEC2, maxSize: defaultScale}},
+		{name: "No stored spec", event: Event{Name: "my-cluster"}, err: true},
+		{name: "Invalid count", event: Event{Name: "my-cluster", Size: "small", Count: "two"}, err: true},
+		{name: "Invalid size", event: Event{Name: "my-cluster", Size: "huge", Count: "2"}, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := clusterNodeGroup(test.event)
+
+			if (err != nil) != test.err || got != test.want {
+				t.Errorf("clusterNodeGroup unit test failure\n got: '%+v', '%v', want: '%+v', error: '%v'", got, err, test.want, test.err)
+			}
+		})
+	}
+}
+
+func TestClusterProgramNoStoredSpec(t *testing.T) {
+	event := Event{Name: "my-cluster", User: "alice", Action: accessAction}
+	mocks := newProgramMocks(event)
+
+	err := pulumi.RunErr(clusterProgram(event, clusterSettings{}), pulumi.WithMocks(mocks.project, mocks.stack, mocks))
+	if err == nil || !strings.Contains(err.Error(), "no node spec stored") {
+		t.Errorf("clusterProgram unit test failure\n got: '%v', want a missing node spec error", err)
+	}
+}
+
+// Dispatch tags are set on the VPC and the cluster, node instances and volumes get every tag at launch
+func TestClusterProgramTags(t *testing.T) {
+	event := Event{Name: "my-cluster", User: "bob", Action: createAction, Size: "small", Count: "2"}
+	settings := clusterSettings{owner: "alice", tags: map[string]string{"cost-center": "1234"}}
+
+	resources := runClusterProgram(t, event, settings)
+
+	want := dispatchTags("alice", "my-cluster")
+
+	vpc, found := resources["awsx:ec2:Vpc::my-cluster"]
+	if !found {
+		t.Fatalf("cluster program tags test failure\n no VPC in: '%v'", resources)
+	}
+
+	if tags := vpc["tags"].Mappable(); !reflect.DeepEqual(tags, stringInterfaces(want)) {
+		t.Errorf("cluster program tags test failure\n got VPC tags: '%v', want: '%v'", tags, want)
+	}
+
+	cluster, found := resources["eks:index:Cluster::my-cluster"]
+	if !found {
+		t.Fatalf("cluster program tags test failure\n no EKS cluster in: '%v'", resources)
+	}
+
+	if tags := cluster["tags"].Mappable(); !reflect.DeepEqual(tags, stringInterfaces(want)) {
+		t.Errorf("cluster program tags test failure\n got cluster tags: '%v', want: '%v'", tags, want)
+	}
+
+	want["cost-center"] = "1234"
+
+	nodes, found := resources["eks:index:NodeGroupV2::my-cluster-nodes"]
+	if !found {
+		t.Fatalf("cluster program tags test failure\n no node group in: '%v'", resources)
+	}
+
+	if tags := nodes["autoScalingGroupTags"].Mappable(); !reflect.DeepEqual(tags, stringInterfaces(want)) {
+		t.Errorf("cluster program tags test failure\n got node group tags: '%v', want: '%v'", tags, want)
+	}
+
+	// instances and their volumes are tagged by the launch template
+	var launched []string
+
+	for _, spec := range nodes["launchTemplateTagSpecifications"].ArrayValue() {
+		if tags := spec.ObjectValue()["tags"].Mappable(); !reflect.DeepEqual(tags, stringInterfaces(want)) {
+			t.Errorf("cluster program tags test failure\n got launch template tags: '%v', want: '%v'", tags, want)
+		}
+
+		launched = append(launched, spec.ObjectValue()["resourceType"].StringValue())
+	}
+
+	if !reflect.DeepEqual(launched, []string{"instance", "volume"}) {
+		t.Errorf("cluster program tags test failure\n got tagged resource types: '%v', want: instance and volume", launched)
+	}
+}
+
+func stringInterfaces(values map[string]string) map[string]interface{} {
+	converted := map[string]interface{}{}
+
+	for key, value := range values {
+		converted[key] = value
+	}
+
+	return converted
+}
+
+func TestClusterProgramVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    string
+	}{
+		{name: "Clone version", version: "1.24", want: "1.24"},
+		{name: "Default version", version: "", want: k8sVersion},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := Event{Name: "my-cluster", User: "alice", Action: createAction, Size: "small", Count: "2", Version: test.version}
+
+			resources := runClusterProgram(t, event, clusterSettings{})
+
+			if version := resources["eks:index:Cluster::my-cluster"]["version"]; !version.IsString() || version.StringValue() != test.want {
+				t.Errorf("clusterProgram unit test failure\n got version: '%v', want: '%v'", version, test.want)
+			}
+		})
+	}
+}
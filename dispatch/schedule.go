@@ -0,0 +1,309 @@
+package dispatch
+
+// working hours schedules for cluster sleep and wake
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	// scheduler containers may not provide a timezone database
+	_ "time/tzdata"
+)
+
+const (
+	scheduleAction           string        = "schedule"
+	schedulerAction          string        = "scheduler"
+	scheduleKey              string        = "dispatch:schedule"
+	scheduleNATKey           string        = "dispatch:scheduleNat"
+	defaultSchedulerInterval time.Duration = 5 * time.Minute
+	minutesPerHour           int           = 60
+	daysPerWeek              int           = 7
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// weekly window a cluster is awake, e.g. "mon-fri 08:00-19:00 America/New_York"
+type wakeSchedule struct {
+	days     [7]bool
+	start    int
+	end      int
+	location *time.Location
+}
+
+type scheduleTransition struct {
+	Name   string
+	Action string
+	NAT    bool
+}
+
+// parse days like "mon-fri", "sat,sun" or "daily"
+func parseScheduleDays(spec string) ([7]bool, error) {
+	var days [7]bool
+
+	if spec == "daily" {
+		for i := range days {
+			days[i] = true
+		}
+
+		return days, nil
+	}
+
+	for _, part := range strings.Split(strings.ToLower(spec), ",") {
+		first, last, isRange := strings.Cut(part, "-")
+
+		start, found := weekdays[first]
+		if !found {
+			return days, fmt.Errorf("invalid schedule day: %s", first)
+		}
+
+		end := start
+
+		if isRange {
+			if end, found = weekdays[last]; !found {
+				return days, fmt.Errorf("invalid schedule day: %s", last)
+			}
+		}
+
+		// ranges may wrap around the end of the week, e.g. sat-sun
+		for day := int(start); ; day = (day + 1) % daysPerWeek {
+			days[day] = true
+
+			if day == int(end) {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// minutes since midnight of a 24 hour clock time
+func parseClockTime(clock string) (int, error) {
+	hours, minutes, found := strings.Cut(clock, ":")
+	if !found {
+		return 0, fmt.Errorf("invalid schedule time: %s", clock)
+	}
+
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid schedule time: %s", clock)
+	}
+
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m >= minutesPerHour || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid schedule time: %s", clock)
+	}
+
+	return h*minutesPerHour + m, nil
+}
+
+func parseSchedule(schedule string) (wakeSchedule, error) {
+	var parsed wakeSchedule
+
+	fields := strings.Fields(schedule)
+	if len(fields) != 3 {
+		return parsed, fmt.Errorf("invalid schedule '%s', expected '<days> <start>-<end> <timezone>'", schedule)
+	}
+
+	days, err := parseScheduleDays(fields[0])
+	if err != nil {
+		return parsed, err
+	}
+
+	startClock, endClock, found := strings.Cut(fields[1], "-")
+	if !found {
+		return parsed, fmt.Errorf("invalid schedule hours: %s", fields[1])
+	}
+
+	start, err := parseClockTime(startClock)
+	if err != nil {
+		return parsed, err
+	}
+
+	end, err := parseClockTime(endClock)
+	if err != nil {
+		return parsed, err
+	}
+
+	if start == end {
+		return parsed, fmt.Errorf("invalid schedule hours: %s", fields[1])
+	}
+
+	location, err := time.LoadLocation(fields[2])
+	if err != nil {
+		return parsed, fmt.Errorf("invalid schedule timezone: %s", fields[2])
+	}
+
+	return wakeSchedule{days: days, start: start, end: end, location: location}, nil
+}
+
+// whether a cluster should be awake, overnight windows belong to the day they start
+func (w wakeSchedule) awake(now time.Time) bool {
+	local := now.In(w.location)
+	minute := local.Hour()*minutesPerHour + local.Minute()
+	today := int(local.Weekday())
+	yesterday := (today + daysPerWeek - 1) % daysPerWeek
+
+	if w.start < w.end {
+		return w.days[today] && minute >= w.start && minute < w.end
+	}
+
+	return (w.days[today] && minute >= w.start) || (w.days[yesterday] && minute < w.end)
+}
+
+// sleep and wake transitions required by cluster schedules, clusters already in the scheduled state are skipped
+func scheduledTransitions(summaries map[string]stackSummary, now time.Time) ([]scheduleTransition, []error) {
+	var transitions []scheduleTransition
+
+	var errs []error
+
+	for _, summary := range filterClusters(summaries, "", true) {
+		if summary.Config[scheduleKey] == "" {
+			continue
+		}
+
+		schedule, err := parseSchedule(summary.Config[scheduleKey])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", summary.Name, err))
+
+			continue
+		}
+
+		if summary.Config[nodeCountKey] == "" {
+			errs = append(errs, fmt.Errorf("%s: cluster node count is not stored with the stack", summary.Name))
+
+			continue
+		}
+
+		awake := schedule.awake(now)
+		asleep := clusterAsleep(summary)
+
+		switch {
+		case awake && asleep:
+			transitions = append(transitions, scheduleTransition{Name: summary.Name, Action: wakeAction})
+		case !awake && !asleep:
+			transitions = append(transitions, scheduleTransition{
+				Name:   summary.Name,
+				Action: sleepAction,
+				NAT:    summary.Config[scheduleNATKey] == "true",
+			})
+		}
+	}
+
+	return transitions, errs
+}
+
+// store or clear a cluster's schedule without updating its infrastructure
+func scheduleCluster(event Event) {
+	if _, found := getStackSummaries(event.Bucket)[event.Name]; !found {
+		fmt.Printf("\n %s was not found, exiting.\n\n", event.Name)
+		os.Exit(0)
+	}
+
+	nat := ""
+	if event.SleepNAT && event.Schedule != "" {
+		nat = "true"
+	}
+
+	updateStackMetadata(event.Bucket, event.Name, map[string]string{
+		scheduleKey:    event.Schedule,
+		scheduleNATKey: nat,
+	})
+
+	if event.Schedule == "" {
+		fmt.Printf("\n - %s schedule cleared\n", event.Name)
+
+		return
+	}
+
+	fmt.Printf("\n - %s scheduled awake %s\n", event.Name, event.Schedule)
+}
+
+// run each transition as a Dispatch subprocess, Exec exits on failure so a failed transition does not stop the scheduler
+// returns the number of failed transitions
+func applyTransitions(event Event, transitions []scheduleTransition, schedulerLog *log.Logger, run func(Event, string) error) int {
+	failed := 0
+
+	for _, transition := range transitions {
+		if event.DryRun {
+			schedulerLog.Printf("would %s %s", transition.Action, transition.Name)
+
+			continue
+		}
+
+		schedulerLog.Printf("%s %s started", transition.Action, transition.Name)
+
+		transitionEvent := event
+		transitionEvent.Action = transition.Action
+		transitionEvent.SleepNAT = transition.NAT
+
+		if err := run(transitionEvent, transition.Name); err != nil {
+			schedulerLog.Printf("%s %s failed: %v", transition.Action, transition.Name, err)
+
+			failed++
+
+			continue
+		}
+
+		schedulerLog.Printf("%s %s complete", transition.Action, transition.Name)
+	}
+
+	return failed
+}
+
+// apply scheduled sleep and wake transitions to every cluster in the state store
+func runScheduler(event Event) {
+	schedulerLog := log.New(os.Stdout, "scheduler ", log.LstdFlags)
+
+	interval := defaultSchedulerInterval
+
+	if event.Interval != "" {
+		parsed, err := time.ParseDuration(event.Interval)
+		if err != nil {
+			reportErr(err, "parse scheduler interval")
+		}
+
+		interval = parsed
+	}
+
+	for {
+		// stacks change with each transition, summaries are reloaded every pass
+		delete(loadedSummaries, event.Bucket)
+
+		refreshCredentials()
+
+		transitions, errs := scheduledTransitions(getStackSummaries(event.Bucket), time.Now())
+
+		for _, err := range errs {
+			schedulerLog.Printf("skipped %v", err)
+		}
+
+		if len(transitions) == 0 {
+			schedulerLog.Print("no transitions required")
+		}
+
+		failed := applyTransitions(event, transitions, schedulerLog, bulkClusterCommand)
+
+		if event.Once {
+			if failed > 0 {
+				os.Exit(1)
+			}
+
+			return
+		}
+
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,166 @@
+package dispatch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// input schedule string
+	// return error for invalid schedules
+	tests := []struct {
+		name     string
+		schedule string
+		err      bool
+	}{
+		{name: "Weekdays", schedule: "mon-fri 08:00-19:00 America/New_York"},
+		{name: "Weekend", schedule: "sat,sun 10:00-14:30 UTC"},
+		{name: "Overnight", schedule: "daily 22:00-06:00 Europe/Berlin"},
+		{name: "Midnight", schedule: "fri-mon 00:00-24:00 UTC"},
+		{name: "Missing timezone", schedule: "mon-fri 08:00-19:00", err: true},
+		{name: "Invalid day", schedule: "mon-fry 08:00-19:00 UTC", err: true},
+		{name: "Invalid time", schedule: "mon-fri 8am-7pm UTC", err: true},
+		{name: "Empty window", schedule: "mon-fri 08:00-08:00 UTC", err: true},
+		{name: "Invalid timezone", schedule: "mon-fri 08:00-19:00 Mars/Olympus", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseSchedule(test.schedule); (err != nil) != test.err {
+				t.Errorf("parseSchedule unit test failure\n schedule: '%s', error: '%v'", test.schedule, err)
+			}
+		})
+	}
+}
+
+func TestScheduleAwake(t *testing.T) {
+	// 2022-12-05 is a Monday
+	tests := []struct {
+		name     string
+		schedule string
+		now      time.Time
+		want     bool
+	}{
+		{
+			name:     "Working hours",
+			schedule: "mon-fri 08:00-19:00 America/New_York",
+			now:      time.Date(2022, 12, 5, 14, 0, 0, 0, time.UTC),
+			want:     true,
+		},
+		{
+			name:     "Before hours in timezone",
+			schedule: "mon-fri 08:00-19:00 America/New_York",
+			now:      time.Date(2022, 12, 5, 12, 0, 0, 0, time.UTC),
+			want:     false,
+		},
+		{
+			name:     "Weekend",
+			schedule: "mon-fri 08:00-19:00 UTC",
+			now:      time.Date(2022, 12, 4, 10, 0, 0, 0, time.UTC),
+			want:     false,
+		},
+		{
+			name:     "Wrapped day range",
+			schedule: "sat-sun 08:00-19:00 UTC",
+			now:      time.Date(2022, 12, 4, 10, 0, 0, 0, time.UTC),
+			want:     true,
+		},
+		{
+			name:     "Overnight from previous day",
+			schedule: "fri 22:00-06:00 UTC",
+			now:      time.Date(2022, 12, 3, 3, 0, 0, 0, time.UTC),
+			want:     true,
+		},
+		{
+			name:     "Overnight after end",
+			schedule: "fri 22:00-06:00 UTC",
+			now:      time.Date(2022, 12, 3, 7, 0, 0, 0, time.UTC),
+			want:     false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := parseSchedule(test.schedule)
+			if err != nil {
+				t.Fatalf("scheduleAwake unit test failure\n error: '%v'", err)
+			}
+
+			if got := schedule.awake(test.now); got != test.want {
+				t.Errorf("scheduleAwake unit test failure\n got: '%v', want: '%v'", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScheduledTransitions(t *testing.T) {
+	// Monday 2022-12-05 20:00 UTC, outside working hours
+	now := time.Date(2022, 12, 5, 20, 0, 0, 0, time.UTC)
+	workingHours := "mon-fri 08:00-19:00 UTC"
+	evening := "daily 18:00-23:00 UTC"
+
+	summaries := map[string]stackSummary{
+		"awake":       {Name: "awake", Config: map[string]string{scheduleKey: workingHours, scheduleNATKey: "true", nodeCountKey: "2"}},
+		"asleep":      {Name: "asleep", Config: map[string]string{scheduleKey: workingHours, nodeCountKey: "0", wakeNodeCountKey: "2"}},
+		"evening":     {Name: "evening", Config: map[string]string{scheduleKey: evening, nodeCountKey: "0", wakeNodeCountKey: "2"}},
+		"unscheduled": {Name: "unscheduled", Config: map[string]string{nodeCountKey: "2"}},
+		"invalid":     {Name: "invalid", Config: map[string]string{scheduleKey: "weekdays", nodeCountKey: "2"}},
+	}
+
+	want := []scheduleTransition{
+		{Name: "awake", Action: sleepAction, NAT: true},
+		{Name: "evening", Action: wakeAction},
+	}
+
+	transitions, errs := scheduledTransitions(summaries, now)
+
+	if !reflect.DeepEqual(transitions, want) {
+		t.Errorf("scheduledTransitions unit test failure\n got: '%v', want: '%v'", transitions, want)
+	}
+
+	if len(errs) != 1 {
+		t.Errorf("scheduledTransitions unit test failure\n expected one invalid schedule error, got: '%v'", errs)
+	}
+}
+
+func TestApplyTransitions(t *testing.T) {
+	transitions := []scheduleTransition{
+		{Name: "broken", Action: sleepAction, NAT: true},
+		{Name: "dev", Action: wakeAction},
+	}
+
+	var output bytes.Buffer
+
+	var ran []string
+
+	// a failed transition is logged and the remaining transitions still run
+	run := func(event Event, name string) error {
+		ran = append(ran, fmt.Sprintf("%s %s %t", event.Action, name, event.SleepNAT))
+
+		if name == "broken" {
+			return errors.New("exit status 1")
+		}
+
+		return nil
+	}
+
+	failed := applyTransitions(Event{Action: schedulerAction}, transitions, log.New(&output, "", 0), run)
+
+	if want := []string{"sleep broken true", "wake dev false"}; failed != 1 || !reflect.DeepEqual(ran, want) {
+		t.Errorf("applyTransitions unit test failure\n got: '%v' with %d failed, want: '%v' with 1 failed", ran, failed, want)
+	}
+
+	if !strings.Contains(output.String(), "sleep broken failed: exit status 1") || !strings.Contains(output.String(), "wake dev complete") {
+		t.Errorf("applyTransitions unit test failure\n log: '%s'", output.String())
+	}
+
+	ran = nil
+
+	if failed := applyTransitions(Event{DryRun: true}, transitions, log.New(&output, "", 0), run); failed != 0 || ran != nil {
+		t.Errorf("applyTransitions unit test failure\n dry run applied transitions: '%v'", ran)
+	}
+}
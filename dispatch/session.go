@@ -0,0 +1,330 @@
+package dispatch
+
+// AWS SDK session shared by every API call of a Dispatch run
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const (
+	defaultAPITimeout    time.Duration = 30 * time.Second
+	interruptGracePeriod time.Duration = 2 * time.Second
+	interruptStatus      int           = 130
+)
+
+type sessionOptions struct {
+	retryMode   aws.RetryMode
+	maxAttempts int
+	timeout     time.Duration
+}
+
+type awsSession struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	signals chan os.Signal
+	timeout time.Duration
+	config  aws.Config
+
+	s3Once  sync.Once
+	ec2Once sync.Once
+	stsOnce sync.Once
+	iamOnce sync.Once
+	eksOnce sync.Once
+	tagOnce sync.Once
+	elbOnce sync.Once
+	albOnce sync.Once
+	r53Once sync.Once
+
+	s3Client  *s3.Client
+	ec2Client *ec2.Client
+	stsClient *sts.Client
+	iamClient *iam.Client
+	eksClient *eks.Client
+	tagClient *resourcegroupstaggingapi.Client
+	elbClient *elasticloadbalancing.Client
+	albClient *elasticloadbalancingv2.Client
+	r53Client *route53.Client
+
+	bucketMutex   sync.Mutex
+	bucketRegions map[string]string
+	bucketClients map[string]*s3.Client
+}
+
+var sessionSettings = sessionOptions{timeout: defaultAPITimeout}
+
+var currentSession *awsSession
+
+// set AWS API retry and timeout options from the Dispatch config file
+func setSessionOptions(settings dispatchConfig) {
+	sessionSettings = sessionOptions{timeout: defaultAPITimeout}
+
+	if settings.AWSRetryMode != "" {
+		mode, err := aws.ParseRetryMode(settings.AWSRetryMode)
+		if err != nil {
+			reportErr(err, "set AWS retry mode")
+		}
+
+		sessionSettings.retryMode = mode
+	}
+
+	if settings.AWSMaxAttempts > 0 {
+		sessionSettings.maxAttempts = settings.AWSMaxAttempts
+	}
+
+	if settings.AWSAPITimeout != "" {
+		timeout, err := time.ParseDuration(settings.AWSAPITimeout)
+		if err != nil {
+			reportErr(err, "set AWS API timeout")
+		}
+
+		sessionSettings.timeout = timeout
+	}
+}
+
+// provide the run's AWS session, creating it on first use
+func getSession() *awsSession {
+	if currentSession == nil {
+		currentSession = newAWSSession(sessionSettings, sessionCredentials)
+	}
+
+	return currentSession
+}
+
+func awsProfile() string {
+	profile, profileSet := os.LookupEnv("AWS_PROFILE")
+	if !profileSet {
+		profile = "default"
+	}
+
+	return profile
+}
+
+// load AWS SDK config and credentials once for all clients
+func newAWSSession(settings sessionOptions, creds credentialOptions) *awsSession {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sess := &awsSession{
+		ctx:     ctx,
+		cancel:  cancel,
+		signals: make(chan os.Signal, 1),
+		timeout: settings.timeout,
+
+		bucketRegions: map[string]string{},
+		bucketClients: map[string]*s3.Client{},
+	}
+
+	sess.cancelOnInterrupt()
+
+	region := setAWSRegion()
+	profile := awsProfile()
+
+	loadOptions := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		// prompt for MFA codes required by shared config role profiles
+		config.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			o.TokenProvider = stscreds.StdinTokenProvider
+		}),
+	}
+
+	_, envarCredsSet := os.LookupEnv("AWS_ACCESS_KEY_ID")
+
+	if !envarCredsSet {
+		loadOptions = append(loadOptions, config.WithSharedConfigProfile(profile))
+	}
+
+	if settings.retryMode != "" {
+		loadOptions = append(loadOptions, config.WithRetryMode(settings.retryMode))
+	}
+
+	if settings.maxAttempts > 0 {
+		loadOptions = append(loadOptions, config.WithRetryMaxAttempts(settings.maxAttempts))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		reportErr(err, "load AWS configuration for profile "+profile)
+	}
+
+	// bulk subprocesses inherit the credentials of the parent's assumed role, MFA can't be prompted for again
+	if !bulkChild() {
+		cfg.Credentials = assumeRoleChain(cfg, creds)
+	}
+
+	exportCredentials(verifyCredentials(ctx, cfg, profile))
+
+	sess.config = cfg
+
+	return sess
+}
+
+// cancel in-flight AWS API calls on Ctrl-C, then exit
+func (s *awsSession) cancelOnInterrupt() {
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		if _, ok := <-s.signals; !ok {
+			return
+		}
+
+		fmt.Print("\n ! Interrupt received, cancelling AWS API requests\n")
+		s.cancel()
+
+		// allow in-flight requests to return their cancellation errors
+		time.Sleep(interruptGracePeriod)
+		os.Exit(interruptStatus)
+	}()
+}
+
+// restore default interrupt handling, pulumi handles its own cancellation
+func (s *awsSession) releaseInterrupts() {
+	signal.Stop(s.signals)
+}
+
+// context for a single AWS API call
+func (s *awsSession) requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(s.ctx, s.timeout)
+}
+
+func (s *awsSession) s3() *s3.Client {
+	s.s3Once.Do(func() {
+		s.s3Client = s3.NewFromConfig(s.config)
+	})
+
+	return s.s3Client
+}
+
+func (s *awsSession) ec2() *ec2.Client {
+	s.ec2Once.Do(func() {
+		s.ec2Client = ec2.NewFromConfig(s.config)
+	})
+
+	return s.ec2Client
+}
+
+func (s *awsSession) sts() *sts.Client {
+	s.stsOnce.Do(func() {
+		s.stsClient = sts.NewFromConfig(s.config)
+	})
+
+	return s.stsClient
+}
+
+func (s *awsSession) iam() *iam.Client {
+	s.iamOnce.Do(func() {
+		s.iamClient = iam.NewFromConfig(s.config)
+	})
+
+	return s.iamClient
+}
+
+func (s *awsSession) eks() *eks.Client {
+	s.eksOnce.Do(func() {
+		s.eksClient = eks.NewFromConfig(s.config)
+	})
+
+	return s.eksClient
+}
+
+func (s *awsSession) tagging() *resourcegroupstaggingapi.Client {
+	s.tagOnce.Do(func() {
+		s.tagClient = resourcegroupstaggingapi.NewFromConfig(s.config)
+	})
+
+	return s.tagClient
+}
+
+// classic load balancers
+func (s *awsSession) elb() *elasticloadbalancing.Client {
+	s.elbOnce.Do(func() {
+		s.elbClient = elasticloadbalancing.NewFromConfig(s.config)
+	})
+
+	return s.elbClient
+}
+
+// application and network load balancers
+func (s *awsSession) elbv2() *elasticloadbalancingv2.Client {
+	s.albOnce.Do(func() {
+		s.albClient = elasticloadbalancingv2.NewFromConfig(s.config)
+	})
+
+	return s.albClient
+}
+
+func (s *awsSession) route53() *route53.Client {
+	s.r53Once.Do(func() {
+		s.r53Client = route53.NewFromConfig(s.config)
+	})
+
+	return s.r53Client
+}
+
+// region of an S3 bucket, the state store may be in a different region than the session
+func (s *awsSession) bucketRegion(bucket string) string {
+	s.bucketMutex.Lock()
+	defer s.bucketMutex.Unlock()
+
+	if region, found := s.bucketRegions[bucket]; found {
+		return region
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	// bucket locations are available from the us-east-1 endpoint for every region
+	location, err := s.s3().GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: &bucket}, func(o *s3.Options) {
+		o.Region = defaultRegion
+	})
+	if err != nil {
+		reportErr(err, "get S3 bucket "+bucket+" region")
+	}
+
+	// buckets in us-east-1 have an empty location constraint
+	region := string(location.LocationConstraint)
+	if region == "" {
+		region = defaultRegion
+	}
+
+	s.bucketRegions[bucket] = region
+
+	return region
+}
+
+// S3 client for the region of a bucket
+func (s *awsSession) stateStore(bucket string) *s3.Client {
+	region := s.bucketRegion(bucket)
+
+	if region == s.config.Region {
+		return s.s3()
+	}
+
+	s.bucketMutex.Lock()
+	defer s.bucketMutex.Unlock()
+
+	if _, found := s.bucketClients[region]; !found {
+		s.bucketClients[region] = s3.NewFromConfig(s.config, func(o *s3.Options) {
+			o.Region = region
+		})
+	}
+
+	return s.bucketClients[region]
+}
@@ -0,0 +1,47 @@
+package dispatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestSetSessionOptions(t *testing.T) {
+	// input Dispatch config file settings
+	// return AWS session options
+	tests := []struct {
+		expectedReturn sessionOptions
+		name           string
+		input          dispatchConfig
+	}{
+		{
+			name:           "Default",
+			input:          dispatchConfig{UID: "test"},
+			expectedReturn: sessionOptions{timeout: defaultAPITimeout},
+		},
+		{
+			name: "Adaptive retries",
+			input: dispatchConfig{
+				UID:            "test",
+				AWSRetryMode:   "adaptive",
+				AWSMaxAttempts: 5,
+				AWSAPITimeout:  "1m",
+			},
+			expectedReturn: sessionOptions{
+				retryMode:   aws.RetryModeAdaptive,
+				maxAttempts: 5,
+				timeout:     time.Minute,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setSessionOptions(test.input)
+
+			if sessionSettings != test.expectedReturn {
+				t.Errorf("setSessionOptions unit test failure\n got: '%+v', want: '%+v'", sessionSettings, test.expectedReturn)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package dispatch
+
+// Pulumi stack state store listing and summaries
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	stackWorkers        int = 8
+	summaryCacheVersion int = 3
+)
+
+// S3 object of a Pulumi stack checkpoint
+type stackObject struct {
+	Key          string
+	ETag         string
+	LastModified time.Time
+}
+
+// Pulumi stack checkpoint file (.pulumi/stacks/<stack>.json)
+type stackCheckpoint struct {
+	Version    int `json:"version"`
+	Checkpoint struct {
+		Stack  string                 `json:"stack"`
+		Config map[string]interface{} `json:"config"`
+		Latest *struct {
+			Resources []checkpointResource `json:"resources"`
+		} `json:"latest"`
+	} `json:"checkpoint"`
+}
+
+type checkpointResource struct {
+	URN     string                 `json:"urn"`
+	Type    string                 `json:"type"`
+	Outputs map[string]interface{} `json:"outputs"`
+}
+
+// cached summary of a stack checkpoint, refreshed when the object ETag changes
+type stackSummary struct {
+	Name         string                 `json:"name"`
+	Key          string                 `json:"key"`
+	ETag         string                 `json:"etag"`
+	MetadataETag string                 `json:"metadataEtag,omitempty"`
+	LastModified time.Time              `json:"lastModified"`
+	Resources    int                    `json:"resources"`
+	Types        map[string]int         `json:"types,omitempty"`
+	Config       map[string]string      `json:"config,omitempty"`
+	Outputs      map[string]interface{} `json:"outputs,omitempty"`
+}
+
+// stack summary cache file, entries are discarded when the cache version changes
+type summaryCache struct {
+	Version int                     `json:"version"`
+	Stacks  map[string]stackSummary `json:"stacks"`
+}
+
+// stack summaries loaded during this run, by bucket
+var loadedSummaries = map[string]map[string]stackSummary{}
+
+// cluster name of a stack checkpoint key
+func stackClusterName(key string) string {
+	clusterName := strings.TrimPrefix(key, pulumiStacksPath)
+	clusterName = strings.TrimSuffix(clusterName, ".json")
+
+	return strings.TrimSuffix(clusterName, "-eks")
+}
+
+// list all stack checkpoint objects in the state store
+func listStackObjects(bucket string) []stackObject {
+	return listStateObjects(bucket, pulumiStacksPath)
+}
+
+// list the JSON objects of a state store prefix
+func listStateObjects(bucket string, prefix string) []stackObject {
+	var stacks []stackObject
+
+	sess := getSession()
+
+	listConfig := &s3.ListObjectsV2Input{
+		Bucket: &bucket,
+		Prefix: aws.String(prefix),
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(sess.stateStore(bucket), listConfig)
+
+	for paginator.HasMorePages() {
+		ctx, cancel := sess.requestContext()
+		page, err := paginator.NextPage(ctx)
+
+		cancel()
+
+		if err != nil {
+			reportErr(err, "list S3 items in KOPS state store")
+		}
+
+		for _, item := range page.Contents {
+			if strings.HasSuffix(*item.Key, ".json") {
+				stacks = append(stacks, stackObject{
+					Key:          *item.Key,
+					ETag:         aws.ToString(item.ETag),
+					LastModified: aws.ToTime(item.LastModified),
+				})
+			}
+		}
+	}
+
+	return stacks
+}
+
+// summarize a stack checkpoint
+func summarizeCheckpoint(object stackObject, data []byte) (stackSummary, error) {
+	var checkpoint stackCheckpoint
+
+	summary := stackSummary{
+		Name:         stackClusterName(object.Key),
+		Key:          object.Key,
+		ETag:         object.ETag,
+		LastModified: object.LastModified,
+		Types:        map[string]int{},
+	}
+
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return summary, err
+	}
+
+	summary.Config = checkpointConfig(data)
+
+	if checkpoint.Checkpoint.Latest == nil {
+		return summary, nil
+	}
+
+	for _, resource := range checkpoint.Checkpoint.Latest.Resources {
+		if resource.Type == "pulumi:pulumi:Stack" {
+			summary.Outputs = resource.Outputs
+
+			continue
+		}
+
+		if !strings.HasPrefix(resource.Type, "pulumi:providers:") {
+			summary.Resources++
+			summary.Types[resource.Type]++
+		}
+	}
+
+	return summary, nil
+}
+
+// summary config from a stack's Dispatch metadata
+func applyStackMetadata(summary stackSummary, metadata stackObject, data []byte) (stackSummary, error) {
+	stackConfig, err := parseStackMetadata(data)
+	if err != nil {
+		return summary, err
+	}
+
+	summary.Config = stackConfig
+	summary.MetadataETag = metadata.ETag
+
+	return summary, nil
+}
+
+// read and summarize a stack checkpoint and its Dispatch metadata from the state store
+func fetchStackSummary(bucket string, object stackObject, metadata stackObject) (stackSummary, error) {
+	data, _, err := getStateObject(bucket, object.Key)
+	if err != nil {
+		return stackSummary{}, err
+	}
+
+	summary, err := summarizeCheckpoint(object, data)
+	if err != nil || metadata.Key == "" {
+		return summary, err
+	}
+
+	metadataData, found, err := getStateObject(bucket, metadata.Key)
+	if err != nil || !found {
+		return summary, err
+	}
+
+	return applyStackMetadata(summary, metadata, metadataData)
+}
+
+func summaryCachePath(bucket string) string {
+	home, homeSet := os.LookupEnv("HOME")
+	if !homeSet {
+		return ""
+	}
+
+	return filepath.Join(home, ".dispatch", "cache", bucket+".json")
+}
+
+func readSummaryCache(cachePath string) map[string]stackSummary {
+	var cached summaryCache
+
+	if cachePath == "" {
+		return map[string]stackSummary{}
+	}
+
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return map[string]stackSummary{}
+	}
+
+	if err := json.Unmarshal(data, &cached); err != nil || cached.Version != summaryCacheVersion {
+		return map[string]stackSummary{}
+	}
+
+	return cached.Stacks
+}
+
+func writeSummaryCache(cachePath string, summaries map[string]stackSummary) {
+	if cachePath == "" {
+		return
+	}
+
+	ensureDir(filepath.Dir(cachePath))
+
+	data, err := json.Marshal(summaryCache{Version: summaryCacheVersion, Stacks: summaries})
+	if err != nil {
+		reportErr(err, "construct stack summary cache")
+	}
+
+	// concurrent Dispatch processes replace the cache atomically
+	tmpPath := fmt.Sprintf("%s.%d", cachePath, os.Getpid())
+
+	if err := os.WriteFile(tmpPath, data, fs.FileMode(privMode)); err != nil {
+		reportErr(err, "write stack summary cache")
+	}
+
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		reportErr(err, "write stack summary cache")
+	}
+}
+
+// stack summaries by cluster name, checkpoints and metadata are only read when their ETags have changed
+func getStackSummaries(bucket string) map[string]stackSummary {
+	if summaries, loaded := loadedSummaries[bucket]; loaded {
+		return summaries
+	}
+
+	var stale []stackObject
+
+	var mutex sync.Mutex
+
+	var wg sync.WaitGroup
+
+	cachePath := summaryCachePath(bucket)
+	cached := readSummaryCache(cachePath)
+	summaries := map[string]stackSummary{}
+	metadata := map[string]stackObject{}
+
+	for _, object := range listStateObjects(bucket, dispatchStacksPath) {
+		metadata[metadataClusterName(object.Key)] = object
+	}
+
+	for _, object := range listStackObjects(bucket) {
+		name := stackClusterName(object.Key)
+
+		if summary, found := cached[name]; found && summary.ETag == object.ETag && summary.MetadataETag == metadata[name].ETag {
+			summaries[name] = summary
+		} else {
+			stale = append(stale, object)
+		}
+	}
+
+	lookups := make(chan stackObject)
+
+	for i := 0; i < stackWorkers && i < len(stale); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for object := range lookups {
+				summary, err := fetchStackSummary(bucket, object, metadata[stackClusterName(object.Key)])
+				if err != nil {
+					// fall back to listing details for unreadable checkpoints
+					summary = stackSummary{
+						Name:         stackClusterName(object.Key),
+						Key:          object.Key,
+						LastModified: object.LastModified,
+					}
+				}
+
+				mutex.Lock()
+				summaries[summary.Name] = summary
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, object := range stale {
+		lookups <- object
+	}
+
+	close(lookups)
+	wg.Wait()
+
+	writeSummaryCache(cachePath, summaries)
+
+	loadedSummaries[bucket] = summaries
+
+	return summaries
+}
+
+// state store key of a cluster's stack checkpoint
+func stackKey(name string) string {
+	return pulumiStacksPath + name + "-eks.json"
+}
+
+// read a stack checkpoint from the state store
+func readStackCheckpoint(bucket string, name string) []byte {
+	sess := getSession()
+
+	ctx, cancel := sess.requestContext()
+	defer cancel()
+
+	resp, err := sess.stateStore(bucket).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    aws.String(stackKey(name)),
+	})
+	if err != nil {
+		reportErr(err, "read stack checkpoint")
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		reportErr(err, "read stack checkpoint")
+	}
+
+	return data
+}
+
+func putStateObject(bucket string, key string, data []byte, activity string) {
+	if err := storeStateObject(bucket, key, data); err != nil {
+		reportErr(err, activity)
+	}
+}
+
+// write a state store object, returning the error so callers can undo related writes
+func storeStateObject(bucket string, key string, data []byte) error {
+	sess := getSession()
+
+	ctx, cancel := sess.requestContext()
+	defer cancel()
+
+	_, err := sess.stateStore(bucket).PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &bucket,
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+
+	return err
+}
@@ -0,0 +1,159 @@
+package dispatch
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testCheckpoint = `{
+	"version": 3,
+	"checkpoint": {
+		"stack": "my-cluster-eks",
+		"config": {
+			"aws:region": "us-west-2",
+			"dispatch:secret": {"secure": "v1:abc"}
+		},
+		"latest": {
+			"resources": [
+				{"urn": "urn:pulumi:my-cluster-eks::alice-dispatch::pulumi:pulumi:Stack::alice-dispatch-my-cluster-eks", "type": "pulumi:pulumi:Stack", "outputs": {"cert-manager-role-arn": "arn:aws:iam::123456789012:role/cm"}},
+				{"urn": "urn:pulumi:my-cluster-eks::alice-dispatch::pulumi:providers:aws::default", "type": "pulumi:providers:aws"},
+				{"urn": "urn:pulumi:my-cluster-eks::alice-dispatch::awsx:ec2:Vpc::my-cluster", "type": "awsx:ec2:Vpc"},
+				{"urn": "urn:pulumi:my-cluster-eks::alice-dispatch::eks:index:Cluster::my-cluster", "type": "eks:index:Cluster"}
+			]
+		}
+	}
+}`
+
+func TestStackClusterName(t *testing.T) {
+	tests := []struct {
+		expectedReturn string
+		name           string
+		input          string
+	}{
+		{
+			name:           "Stack key",
+			input:          pulumiStacksPath + "my-cluster-eks.json",
+			expectedReturn: "my-cluster",
+		},
+		{
+			name:           "Stack name",
+			input:          "my-cluster-eks",
+			expectedReturn: "my-cluster",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name := stackClusterName(test.input)
+
+			if name != test.expectedReturn {
+				t.Errorf("stackClusterName unit test failure\n got: '%v', want: '%v'", name, test.expectedReturn)
+			}
+		})
+	}
+}
+
+func TestSummarizeCheckpoint(t *testing.T) {
+	object := stackObject{
+		Key:          pulumiStacksPath + "my-cluster-eks.json",
+		ETag:         "\"abc123\"",
+		LastModified: time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	summary, err := summarizeCheckpoint(object, []byte(testCheckpoint))
+	if err != nil {
+		t.Fatalf("summarizeCheckpoint unit test failure\n error: '%v'", err)
+	}
+
+	if summary.Name != "my-cluster" || summary.ETag != object.ETag {
+		t.Errorf("summarizeCheckpoint unit test failure\n got: '%v' '%v', want: 'my-cluster' '%v'", summary.Name, summary.ETag, object.ETag)
+	}
+
+	if summary.Resources != 2 {
+		t.Errorf("summarizeCheckpoint unit test failure\n got: '%v' resources, want: '2'", summary.Resources)
+	}
+
+	if summary.Config["aws:region"] != "us-west-2" {
+		t.Errorf("summarizeCheckpoint unit test failure\n got region: '%v', want: 'us-west-2'", summary.Config["aws:region"])
+	}
+
+	if _, found := summary.Config["dispatch:secret"]; found {
+		t.Error("summarizeCheckpoint unit test failure\n secret config values should not be summarized")
+	}
+
+	if summary.Outputs["cert-manager-role-arn"] == nil {
+		t.Error("summarizeCheckpoint unit test failure\n stack outputs not summarized")
+	}
+}
+
+func TestSummaryCache(t *testing.T) {
+	cachePath := t.TempDir() + "/cache/test-bucket.json"
+
+	summaries := map[string]stackSummary{
+		"my-cluster": {Name: "my-cluster", ETag: "\"abc123\""},
+	}
+
+	writeSummaryCache(cachePath, summaries)
+
+	cached := readSummaryCache(cachePath)
+
+	if cached["my-cluster"].ETag != "\"abc123\"" {
+		t.Errorf("summary cache unit test failure\n got: '%v', want: '%v'", cached["my-cluster"], summaries["my-cluster"])
+	}
+}
+
+func TestApplyStackMetadata(t *testing.T) {
+	object := stackObject{Key: pulumiStacksPath + "my-cluster-eks.json"}
+	metadata := stackObject{Key: metadataKey("my-cluster"), ETag: "\"def456\""}
+
+	summary, err := summarizeCheckpoint(object, []byte(testCheckpoint))
+	if err != nil {
+		t.Fatalf("applyStackMetadata unit test failure\n error: '%v'", err)
+	}
+
+	summary, err = applyStackMetadata(summary, metadata, []byte(`{"version": 1, "config": {"aws:region": "eu-west-1", "dispatch:protected": "true"}}`))
+	if err != nil {
+		t.Fatalf("applyStackMetadata unit test failure\n error: '%v'", err)
+	}
+
+	// metadata replaces config of checkpoints edited by earlier versions
+	if summary.Config[regionConfigKey] != "eu-west-1" || !clusterProtected(summary) || summary.MetadataETag != metadata.ETag {
+		t.Errorf("applyStackMetadata unit test failure\n got: '%+v'", summary)
+	}
+
+	if summary.Resources != 2 || summary.Outputs["cert-manager-role-arn"] == nil {
+		t.Errorf("applyStackMetadata unit test failure\n checkpoint state not preserved: '%+v'", summary)
+	}
+}
+
+func TestMergeStackConfig(t *testing.T) {
+	stored := map[string]string{regionConfigKey: "us-west-2", protectedKey: "true"}
+
+	got := mergeStackConfig(stored, map[string]string{expiryConfigKey: "2022-12-01T18:00:00Z", protectedKey: ""})
+	want := map[string]string{regionConfigKey: "us-west-2", expiryConfigKey: "2022-12-01T18:00:00Z"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeStackConfig unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+
+	if stored[protectedKey] != "true" {
+		t.Errorf("mergeStackConfig unit test failure\n stored config modified: '%v'", stored)
+	}
+}
+
+func TestStackMetadataRoundTrip(t *testing.T) {
+	stackConfig := map[string]string{regionConfigKey: "us-west-2", accessMappingsKey: `[{"arn":"arn:aws:iam::123456789012:role/admin"}]`}
+
+	data, err := encodeStackMetadata(stackConfig)
+	if err != nil {
+		t.Fatalf("encodeStackMetadata unit test failure\n error: '%v'", err)
+	}
+
+	if got, err := parseStackMetadata(data); err != nil || !reflect.DeepEqual(got, stackConfig) {
+		t.Errorf("parseStackMetadata unit test failure\n got: '%v', want: '%v', error: '%v'", got, stackConfig, err)
+	}
+
+	if name := metadataClusterName(metadataKey("my-cluster")); name != "my-cluster" {
+		t.Errorf("metadataClusterName unit test failure\n got: '%v', want: 'my-cluster'", name)
+	}
+}
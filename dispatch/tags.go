@@ -0,0 +1,152 @@
+package dispatch
+
+// user supplied tags applied to every taggable AWS resource of a cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// default tags of the AWS provider, also used by the eks and awsx components
+	defaultTagsKey    string = "aws:defaultTags"
+	tagLabelPrefix    string = "tag:"
+	maxResourceTags   int    = 50
+	maxTagKeyLength   int    = 128
+	maxTagValueLength int    = 256
+)
+
+// tags Dispatch sets on cluster resources, these cannot be overridden
+var reservedTags = []string{ownerTag, clusterTag, createdByTag}
+
+// characters allowed in AWS tag keys and values
+var validTag = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// repeatable key=value tag flag
+type tagFlags map[string]string
+
+func (t tagFlags) String() string {
+	return formatTags(t)
+}
+
+func (t tagFlags) Set(tag string) error {
+	key, value, found := strings.Cut(tag, "=")
+	if !found || key == "" {
+		return fmt.Errorf("invalid tag %s, tags are key=value pairs (e.g. cost-center=1234)", tag)
+	}
+
+	t[key] = value
+
+	return nil
+}
+
+// tags sorted by key, e.g. cost-center=1234, team=infra
+func formatTags(tags map[string]string) string {
+	var pairs []string
+
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ", ")
+}
+
+// check tags against AWS tag limits, the reserved Dispatch tags count towards the limit
+func validateTags(tags map[string]string) error {
+	if len(tags)+len(reservedTags) > maxResourceTags {
+		return fmt.Errorf("%d tags provided, at most %d tags can be added to Dispatch resources", len(tags), maxResourceTags-len(reservedTags))
+	}
+
+	for key, value := range tags {
+		for _, reserved := range reservedTags {
+			if strings.EqualFold(key, reserved) {
+				return fmt.Errorf("tag %s is set by Dispatch and cannot be overridden", key)
+			}
+		}
+
+		switch {
+		case len(key) > maxTagKeyLength:
+			return fmt.Errorf("tag key %s exceeds %d characters", key, maxTagKeyLength)
+		case len(value) > maxTagValueLength:
+			return fmt.Errorf("value of tag %s exceeds %d characters", key, maxTagValueLength)
+		case strings.HasPrefix(strings.ToLower(key), "aws:"):
+			return fmt.Errorf("tag %s uses the reserved aws: prefix", key)
+		case !validTag.MatchString(key) || !validTag.MatchString(value):
+			return fmt.Errorf("tag %s=%s contains characters AWS does not allow, use letters, numbers, spaces and _ . : / = + - @", key, value)
+		}
+	}
+
+	return nil
+}
+
+// tags Dispatch sets on cluster resources, orphan detection relies on them
+func dispatchTags(owner string, eksID string) map[string]string {
+	return map[string]string{ownerTag: owner, clusterTag: eksID, createdByTag: dispatchTagValue}
+}
+
+// later tag sets override earlier ones
+func mergeTags(tagSets ...map[string]string) map[string]string {
+	merged := map[string]string{}
+
+	for _, tags := range tagSets {
+		for key, value := range tags {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+// user tags stored with the stack config
+func clusterTags(summary stackSummary) map[string]string {
+	var defaultTags struct {
+		Tags map[string]string `json:"tags"`
+	}
+
+	if err := json.Unmarshal([]byte(summary.Config[defaultTagsKey]), &defaultTags); err != nil || defaultTags.Tags == nil {
+		return map[string]string{}
+	}
+
+	return defaultTags.Tags
+}
+
+// clusters with every provided tag
+func filterTagged(clusters []stackSummary, tags map[string]string) []stackSummary {
+	var tagged []stackSummary
+
+	for _, summary := range clusters {
+		stored := clusterTags(summary)
+		matched := true
+
+		for key, value := range tags {
+			if stored[key] != value {
+				matched = false
+			}
+		}
+
+		if matched {
+			tagged = append(tagged, summary)
+		}
+	}
+
+	return tagged
+}
+
+// AWS provider default tags of a new cluster
+func tagsConfig(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	defaultTags, err := json.Marshal(map[string]map[string]string{"tags": tags})
+	if err != nil {
+		reportErr(err, "create cluster tags")
+	}
+
+	return map[string]string{defaultTagsKey: string(defaultTags)}
+}
@@ -0,0 +1,114 @@
+package dispatch
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateTags(t *testing.T) {
+	tooMany := map[string]string{}
+
+	for i := 0; i < maxResourceTags-len(reservedTags)+1; i++ {
+		tooMany[fmt.Sprintf("tag-%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name string
+		tags map[string]string
+		err  bool
+	}{
+		{name: "Valid", tags: map[string]string{"cost-center": "1234", "team": "platform eng", "env": ""}},
+		{name: "Empty", tags: map[string]string{}},
+		{name: "Reserved Dispatch tag", tags: map[string]string{"owner": "alice"}, err: true},
+		{name: "Reserved AWS prefix", tags: map[string]string{"aws:team": "infra"}, err: true},
+		{name: "Key too long", tags: map[string]string{strings.Repeat("k", maxTagKeyLength+1): "value"}, err: true},
+		{name: "Value too long", tags: map[string]string{"team": strings.Repeat("v", maxTagValueLength+1)}, err: true},
+		{name: "Invalid characters", tags: map[string]string{"team": "infra;ops"}, err: true},
+		{name: "Too many tags", tags: tooMany, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateTags(test.tags); (err != nil) != test.err {
+				t.Errorf("validateTags unit test failure\n got: '%v', want error: '%v'", err, test.err)
+			}
+		})
+	}
+}
+
+func TestTagFlags(t *testing.T) {
+	tags := tagFlags{}
+
+	for _, tag := range []string{"team=infra", "cost-center=1234", "url=a=b", "env="} {
+		if err := tags.Set(tag); err != nil {
+			t.Fatalf("tagFlags unit test failure\n error: '%v'", err)
+		}
+	}
+
+	if err := tags.Set("=infra"); err == nil {
+		t.Errorf("tagFlags unit test failure\n tags without a key must be rejected")
+	}
+
+	want := "cost-center=1234, env=, team=infra, url=a=b"
+
+	if tags.String() != want {
+		t.Errorf("tagFlags unit test failure\n got: '%s', want: '%s'", tags.String(), want)
+	}
+}
+
+func TestFilterTagged(t *testing.T) {
+	clusters := []stackSummary{
+		{Name: "billing", Config: map[string]string{defaultTagsKey: `{"tags":{"cost-center":"1234","team":"infra"}}`}},
+		{Name: "search", Config: map[string]string{defaultTagsKey: `{"tags":{"cost-center":"5678"}}`}},
+		{Name: "untagged", Config: map[string]string{}},
+	}
+
+	tests := []struct {
+		name string
+		tags map[string]string
+		want []string
+	}{
+		{name: "No filter", tags: map[string]string{}, want: []string{"billing", "search", "untagged"}},
+		{name: "Single tag", tags: map[string]string{"cost-center": "1234"}, want: []string{"billing"}},
+		{name: "Every tag must match", tags: map[string]string{"cost-center": "5678", "team": "infra"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+
+			for _, summary := range filterTagged(clusters, test.tags) {
+				got = append(got, summary.Name)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("filterTagged unit test failure\n got: '%v', want: '%v'", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	defaults := map[string]string{"cost-center": "0000", "environment": "dev"}
+	source := map[string]string{"cost-center": "1234", "team": "infra"}
+	flags := map[string]string{"environment": "staging"}
+
+	want := map[string]string{"cost-center": "1234", "environment": "staging", "team": "infra"}
+
+	if got := mergeTags(defaults, source, flags); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeTags unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+}
+
+func TestDispatchTags(t *testing.T) {
+	tags := dispatchTags("alice", "my-cluster")
+
+	if len(tags) != len(reservedTags) || tags[ownerTag] != "alice" || tags[clusterTag] != "my-cluster" || tags[createdByTag] != dispatchTagValue {
+		t.Errorf("dispatchTags unit test failure\n got: '%v'", tags)
+	}
+
+	// reserved Dispatch tags override user tags of the same key
+	if merged := mergeTags(map[string]string{ownerTag: "mallory"}, tags); merged[ownerTag] != "alice" {
+		t.Errorf("dispatchTags unit test failure\n got: '%v', want owner: 'alice'", merged)
+	}
+}
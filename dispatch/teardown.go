@@ -0,0 +1,304 @@
+package dispatch
+
+// guarded cluster teardown, stack state is only removed after a verified empty destroy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+const (
+	deleteFailureKey     string        = "dispatch:deleteFailure"
+	maxDestroyAttempts   int           = 3
+	maxFailureMessage    int           = 500
+	eksClusterType       string        = "aws:eks/cluster:Cluster"
+	loadBalancerENIWait  time.Duration = 5 * time.Minute
+	loadBalancerENIPoll  time.Duration = 15 * time.Second
+	kubernetesClusterTag string        = "kubernetes.io/cluster/"
+	lbControllerTag      string        = "elbv2.k8s.aws/cluster"
+)
+
+// resource types destroyed ahead of the full destroy when retrying, node groups release their ENIs first
+var teardownStageTypes = [][]string{
+	{"aws:eks/nodeGroup:NodeGroup", "aws:cloudformation/stack:Stack", "aws:autoscaling/group:Group"},
+	{eksClusterType},
+}
+
+// failed delete stored with the cluster metadata so delete can be re-run to resume
+type deleteFailure struct {
+	FailedAt  string   `json:"failedAt"`
+	Attempts  int      `json:"attempts"`
+	Error     string   `json:"error"`
+	Remaining []string `json:"remaining,omitempty"`
+}
+
+func storedDeleteFailure(stackConfig map[string]string) (deleteFailure, bool) {
+	var failure deleteFailure
+
+	if stackConfig[deleteFailureKey] == "" {
+		return failure, false
+	}
+
+	if err := json.Unmarshal([]byte(stackConfig[deleteFailureKey]), &failure); err != nil {
+		return failure, false
+	}
+
+	return failure, true
+}
+
+// stack resources of a pulumi deployment export
+func deploymentResources(ctx context.Context, s auto.Stack) []apitype.ResourceV3 {
+	var deployment apitype.DeploymentV3
+
+	export, err := s.Export(ctx)
+	if err != nil {
+		reportErr(err, "export stack state")
+	}
+
+	if err := json.Unmarshal(export.Deployment, &deployment); err != nil {
+		reportErr(err, "read stack state")
+	}
+
+	return deployment.Resources
+}
+
+// URNs of resources still managed by a stack, the root stack and providers remain after a destroy
+func remainingResources(resources []apitype.ResourceV3) []string {
+	var remaining []string
+
+	for _, resource := range resources {
+		if resource.Type == "pulumi:pulumi:Stack" || strings.HasPrefix(string(resource.Type), "pulumi:providers:") {
+			continue
+		}
+
+		remaining = append(remaining, string(resource.URN))
+	}
+
+	return remaining
+}
+
+// URNs of resources destroyed in each retry stage
+func teardownStages(resources []apitype.ResourceV3) [][]string {
+	var stages [][]string
+
+	for _, types := range teardownStageTypes {
+		var stage []string
+
+		for _, resource := range resources {
+			for _, resourceType := range types {
+				if string(resource.Type) == resourceType {
+					stage = append(stage, string(resource.URN))
+				}
+			}
+		}
+
+		if len(stage) > 0 {
+			stages = append(stages, stage)
+		}
+	}
+
+	return stages
+}
+
+// EKS cluster name and VPC ID from the stack state
+func clusterNetwork(resources []apitype.ResourceV3) (string, string) {
+	for _, resource := range resources {
+		if string(resource.Type) != eksClusterType {
+			continue
+		}
+
+		name, _ := resource.Outputs["name"].(string)
+
+		var vpcID string
+
+		if vpcConfig, found := resource.Outputs["vpcConfig"].(map[string]interface{}); found {
+			vpcID, _ = vpcConfig["vpcId"].(string)
+		}
+
+		return name, vpcID
+	}
+
+	return "", ""
+}
+
+// network interfaces in a VPC matching the filters
+func vpcNetworkInterfaces(ctx context.Context, client *awsec2.Client, vpcID string, filters ...ec2types.Filter) ([]ec2types.NetworkInterface, error) {
+	filters = append(filters, ec2types.Filter{Name: aws.String("vpc-id"), Values: []string{vpcID}})
+
+	output, err := client.DescribeNetworkInterfaces(ctx, &awsec2.DescribeNetworkInterfacesInput{Filters: filters})
+	if err != nil {
+		return nil, err
+	}
+
+	return output.NetworkInterfaces, nil
+}
+
+// delete load balancers, ENIs and security groups Kubernetes created in the cluster VPC, these block VPC deletion
+func cleanupKubernetesResources(eksName string, vpcID string, region string) {
+	sess := getSession()
+
+	ctx, cancel := context.WithTimeout(sess.ctx, orphanWaitTimeout)
+	defer cancel()
+
+	client := awsec2.NewFromConfig(sess.config, func(o *awsec2.Options) {
+		o.Region = region
+	})
+
+	fmt.Printf(" . Removing load balancers and network interfaces Kubernetes left in VPC %s\n", vpcID)
+
+	seen := map[string]bool{}
+
+	// load balancers of the in-tree service controller and the AWS load balancer controller
+	for _, filter := range []tagFilter{{Key: kubernetesClusterTag + eksName}, {Key: lbControllerTag, Values: []string{eksName}}} {
+		for _, resource := range findTaggedResources(region, []tagFilter{filter}, "elasticloadbalancing:loadbalancer") {
+			if seen[resource.ARN] {
+				continue
+			}
+
+			seen[resource.ARN] = true
+
+			kind, id := parseResourceARN(resource.ARN)
+
+			if err := deleteOrphan(orphanedResource{ARN: resource.ARN, Kind: kind, ID: id, Region: region}); err != nil {
+				fmt.Printf(" ! Failed to delete load balancer %s: %v\n", id, err)
+			}
+		}
+	}
+
+	// load balancer ENIs are released asynchronously after the load balancer is deleted
+	for waited := time.Duration(0); waited < loadBalancerENIWait; waited += loadBalancerENIPoll {
+		interfaces, err := vpcNetworkInterfaces(ctx, client, vpcID, ec2types.Filter{Name: aws.String("description"), Values: []string{"ELB *"}})
+		if err != nil || len(interfaces) == 0 {
+			break
+		}
+
+		time.Sleep(loadBalancerENIPoll)
+	}
+
+	interfaces, err := vpcNetworkInterfaces(ctx, client, vpcID, ec2types.Filter{Name: aws.String("status"), Values: []string{"available"}})
+	if err != nil {
+		fmt.Printf(" ! Failed to list network interfaces of VPC %s: %v\n", vpcID, err)
+	}
+
+	for _, eni := range interfaces {
+		if _, err := client.DeleteNetworkInterface(ctx, &awsec2.DeleteNetworkInterfaceInput{NetworkInterfaceId: eni.NetworkInterfaceId}); err != nil {
+			fmt.Printf(" ! Failed to delete network interface %s: %v\n", aws.ToString(eni.NetworkInterfaceId), err)
+		}
+	}
+
+	groups, err := client.DescribeSecurityGroups(ctx, &awsec2.DescribeSecurityGroupsInput{Filters: []ec2types.Filter{
+		{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+		{Name: aws.String("group-name"), Values: []string{"k8s-*"}},
+	}})
+	if err != nil {
+		fmt.Printf(" ! Failed to list security groups of VPC %s: %v\n", vpcID, err)
+
+		return
+	}
+
+	for _, group := range groups.SecurityGroups {
+		if err := deleteSecurityGroup(ctx, client, aws.ToString(group.GroupId)); err != nil {
+			fmt.Printf(" ! Failed to delete security group %s: %v\n", aws.ToString(group.GroupId), err)
+		}
+	}
+}
+
+// destroy resources that commonly block EKS teardown ahead of the full destroy
+func destroyStages(ctx context.Context, s auto.Stack, resources []apitype.ResourceV3) {
+	for _, stage := range teardownStages(resources) {
+		if _, err := s.Destroy(ctx, optdestroy.Target(stage), optdestroy.TargetDependents(), optdestroy.ProgressStreams(os.Stdout)); err != nil {
+			fmt.Printf(" ! Targeted destroy failed, continuing: %v\n", err)
+		}
+	}
+}
+
+// destroy a cluster's stack, retrying after removing Kubernetes created resources
+// returns the attempts made and the resources left in the stack state when the destroy failed
+func destroyCluster(ctx context.Context, s auto.Stack, event Event, region string, resuming bool) (int, []string, error) {
+	var err error
+
+	if !event.SkipCleanup {
+		if eksName, _ := clusterNetwork(deploymentResources(ctx, s)); eksName != "" {
+			cleanupClusterWorkloads(event, eksName, region)
+		}
+	}
+
+	for attempt := 1; attempt <= maxDestroyAttempts; attempt++ {
+		resources := deploymentResources(ctx, s)
+
+		if attempt > 1 || resuming {
+			if eksName, vpcID := clusterNetwork(resources); eksName != "" && vpcID != "" {
+				cleanupKubernetesResources(eksName, vpcID, region)
+			}
+
+			destroyStages(ctx, s, resources)
+		}
+
+		if _, err = s.Destroy(ctx, optdestroy.ProgressStreams(os.Stdout)); err == nil {
+			remaining := remainingResources(deploymentResources(ctx, s))
+			if len(remaining) == 0 {
+				return attempt, nil, nil
+			}
+
+			err = fmt.Errorf("%d resources remain in the stack state after destroy", len(remaining))
+		}
+
+		if ctx.Err() != nil {
+			reportErr(ctx.Err(), "destroy cluster "+event.Name)
+		}
+
+		fmt.Printf("\n ! Destroy attempt %d of %d for %s failed: %v\n", attempt, maxDestroyAttempts, event.Name, err)
+	}
+
+	return maxDestroyAttempts, remainingResources(deploymentResources(ctx, s)), err
+}
+
+// metadata value of a failed delete, attempts add up across resumed deletes
+func deleteFailureConfig(previous deleteFailure, attempts int, remaining []string, err error, now time.Time) (map[string]string, error) {
+	message := err.Error()
+	if len(message) > maxFailureMessage {
+		message = message[:maxFailureMessage] + "..."
+	}
+
+	failure, jsonErr := json.Marshal(deleteFailure{
+		FailedAt:  now.UTC().Format(time.RFC3339),
+		Attempts:  previous.Attempts + attempts,
+		Error:     message,
+		Remaining: remaining,
+	})
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	return map[string]string{deleteFailureKey: string(failure)}, nil
+}
+
+// persist a failed delete with the cluster metadata so the next delete resumes it,
+// the metadata object is kept until the stack is removed
+func recordDeleteFailure(event Event, previous deleteFailure, attempts int, remaining []string, err error) {
+	values, jsonErr := deleteFailureConfig(previous, attempts, remaining, err, time.Now())
+	if jsonErr != nil {
+		reportErr(jsonErr, "create delete failure report")
+	}
+
+	updateStackMetadata(event.Bucket, event.Name, values)
+
+	fmt.Printf("\n ! %s was not deleted, %d resources remain in the stack state\n", event.Name, len(remaining))
+
+	for _, urn := range remaining {
+		fmt.Printf("\t <> %s\n", urn)
+	}
+
+	fmt.Print(" ! Run the same delete command to resume\n\n")
+}
@@ -0,0 +1,128 @@
+package dispatch
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+func testDeployment() []apitype.ResourceV3 {
+	return []apitype.ResourceV3{
+		{URN: "urn:pulumi:test-eks::tester-dispatch::pulumi:pulumi:Stack::tester-dispatch-test-eks", Type: "pulumi:pulumi:Stack"},
+		{URN: "urn:pulumi:test-eks::tester-dispatch::pulumi:providers:aws::default", Type: "pulumi:providers:aws"},
+		{
+			URN:  "urn:pulumi:test-eks::tester-dispatch::eks:index:Cluster$aws:eks/cluster:Cluster::test-eksCluster",
+			Type: "aws:eks/cluster:Cluster",
+			Outputs: map[string]interface{}{
+				"name":      "test-eksCluster-1a2b",
+				"vpcConfig": map[string]interface{}{"vpcId": "vpc-0abc"},
+			},
+		},
+		{
+			URN:  "urn:pulumi:test-eks::tester-dispatch::eks:index:Cluster$aws:cloudformation/stack:Stack::test-nodes",
+			Type: "aws:cloudformation/stack:Stack",
+		},
+		{URN: "urn:pulumi:test-eks::tester-dispatch::awsx:ec2:Vpc$aws:ec2/vpc:Vpc::test", Type: "aws:ec2/vpc:Vpc"},
+	}
+}
+
+func TestRemainingResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []apitype.ResourceV3
+		want      int
+	}{
+		{name: "Cluster resources", resources: testDeployment(), want: 3},
+		{name: "Destroyed stack", resources: testDeployment()[:2], want: 0},
+		{name: "Empty stack", resources: nil, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := remainingResources(test.resources); len(got) != test.want {
+				t.Errorf("remainingResources unit test failure\n got: '%v', want: '%v' resources", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTeardownStages(t *testing.T) {
+	want := [][]string{
+		{"urn:pulumi:test-eks::tester-dispatch::eks:index:Cluster$aws:cloudformation/stack:Stack::test-nodes"},
+		{"urn:pulumi:test-eks::tester-dispatch::eks:index:Cluster$aws:eks/cluster:Cluster::test-eksCluster"},
+	}
+
+	if got := teardownStages(testDeployment()); !reflect.DeepEqual(got, want) {
+		t.Errorf("teardownStages unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+}
+
+func TestClusterNetwork(t *testing.T) {
+	name, vpcID := clusterNetwork(testDeployment())
+
+	if name != "test-eksCluster-1a2b" || vpcID != "vpc-0abc" {
+		t.Errorf("clusterNetwork unit test failure\n got: '%s %s', want: 'test-eksCluster-1a2b vpc-0abc'", name, vpcID)
+	}
+
+	if name, vpcID := clusterNetwork(nil); name != "" || vpcID != "" {
+		t.Errorf("clusterNetwork unit test failure\n got: '%s %s', want empty values", name, vpcID)
+	}
+}
+
+func TestStoredDeleteFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]string
+		want   deleteFailure
+		found  bool
+	}{
+		{
+			name:   "Failed delete",
+			config: map[string]string{deleteFailureKey: `{"failedAt":"2022-12-05T10:00:00Z","attempts":3,"error":"DependencyViolation","remaining":["urn"]}`},
+			want:   deleteFailure{FailedAt: "2022-12-05T10:00:00Z", Attempts: 3, Error: "DependencyViolation", Remaining: []string{"urn"}},
+			found:  true,
+		},
+		{name: "No failure", config: map[string]string{}},
+		{name: "Invalid failure", config: map[string]string{deleteFailureKey: "failed"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, found := storedDeleteFailure(test.config)
+
+			if found != test.found || (found && !reflect.DeepEqual(got, test.want)) {
+				t.Errorf("storedDeleteFailure unit test failure\n got: '%+v', want: '%+v'", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDeleteFailureConfig(t *testing.T) {
+	now := time.Date(2022, 12, 5, 11, 0, 0, 0, time.UTC)
+	previous := deleteFailure{FailedAt: "2022-12-05T10:00:00Z", Attempts: 3, Error: "DependencyViolation"}
+
+	values, err := deleteFailureConfig(previous, 2, []string{"urn"}, errors.New(strings.Repeat("x", maxFailureMessage+1)), now)
+	if err != nil {
+		t.Fatalf("deleteFailureConfig unit test failure\n error: '%v'", err)
+	}
+
+	// the failure record is kept in the stored metadata, which updates of the stack checkpoint leave in place
+	metadata, err := encodeStackMetadata(mergeStackConfig(map[string]string{regionConfigKey: "us-west-2"}, values))
+	if err != nil {
+		t.Fatalf("deleteFailureConfig unit test failure\n error: '%v'", err)
+	}
+
+	summary, err := applyStackMetadata(stackSummary{Name: "my-cluster"}, stackObject{Key: metadataKey("my-cluster")}, metadata)
+	if err != nil {
+		t.Fatalf("deleteFailureConfig unit test failure\n error: '%v'", err)
+	}
+
+	got, found := storedDeleteFailure(summary.Config)
+	want := deleteFailure{FailedAt: "2022-12-05T11:00:00Z", Attempts: 5, Error: strings.Repeat("x", maxFailureMessage) + "...", Remaining: []string{"urn"}}
+
+	if !found || !reflect.DeepEqual(got, want) {
+		t.Errorf("deleteFailureConfig unit test failure\n got: '%+v', want: '%+v'", got, want)
+	}
+}
@@ -0,0 +1,201 @@
+package dispatch
+
+// export and import of cluster stacks between state stores
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	exportAction       string = "export"
+	importAction       string = "import"
+	exportVersion      int    = 1
+	importedFromKey    string = "dispatch:importedFrom"
+	importedAtKey      string = "dispatch:importedAt"
+	pulumiStackURNType string = "::pulumi:pulumi:Stack::"
+)
+
+// exported cluster definition, the checkpoint and metadata are the stack's state store files
+type clusterExport struct {
+	Version    int               `json:"version"`
+	Name       string            `json:"name"`
+	Project    string            `json:"project"`
+	Owner      string            `json:"owner"`
+	Region     string            `json:"region"`
+	Bucket     string            `json:"bucket"`
+	ExportedBy string            `json:"exportedBy"`
+	ExportedAt string            `json:"exportedAt"`
+	Checkpoint json.RawMessage   `json:"checkpoint"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// pulumi project of a Dispatch user's stacks
+func pulumiProject(user string) string {
+	return user + "-dispatch"
+}
+
+// pulumi project of a stack checkpoint from its resource URNs (urn:pulumi:<stack>::<project>::<type>::<name>)
+func checkpointProject(data []byte) (string, error) {
+	var checkpoint stackCheckpoint
+
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return "", err
+	}
+
+	if checkpoint.Checkpoint.Latest == nil || len(checkpoint.Checkpoint.Latest.Resources) == 0 {
+		return "", fmt.Errorf("stack %s has no resources", checkpoint.Checkpoint.Stack)
+	}
+
+	urn := strings.Split(checkpoint.Checkpoint.Latest.Resources[0].URN, "::")
+	if len(urn) < 2 || urn[1] == "" {
+		return "", fmt.Errorf("invalid resource URN: %s", checkpoint.Checkpoint.Latest.Resources[0].URN)
+	}
+
+	return urn[1], nil
+}
+
+// rewrite the project of every resource URN and the root stack resource name in a checkpoint
+func renameCheckpointProject(data []byte, stack string, from string, to string) []byte {
+	renamed := bytes.ReplaceAll(data,
+		[]byte("urn:pulumi:"+stack+"::"+from+"::"),
+		[]byte("urn:pulumi:"+stack+"::"+to+"::"))
+
+	return bytes.ReplaceAll(renamed,
+		[]byte(pulumiStackURNType+from+"-"+stack+"\""),
+		[]byte(pulumiStackURNType+to+"-"+stack+"\""))
+}
+
+// write a cluster's stack checkpoint and Dispatch metadata as JSON
+func exportCluster(event Event) {
+	summary, found := getStackSummaries(event.Bucket)[event.Name]
+	if !found {
+		fmt.Printf("\n %s was not found, exiting.\n\n", event.Name)
+		os.Exit(1)
+	}
+
+	data := readStackCheckpoint(event.Bucket, event.Name)
+
+	project, err := checkpointProject(data)
+	if err != nil {
+		reportErr(err, "export cluster "+event.Name)
+	}
+
+	export, err := json.MarshalIndent(clusterExport{
+		Version:    exportVersion,
+		Name:       event.Name,
+		Project:    project,
+		Owner:      clusterOwner(summary),
+		Region:     summaryRegion(summary),
+		Bucket:     event.Bucket,
+		ExportedBy: event.User,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		Checkpoint: data,
+		Metadata:   readStackMetadata(event.Bucket, event.Name),
+	}, "", "  ")
+	if err != nil {
+		reportErr(err, "export cluster "+event.Name)
+	}
+
+	fmt.Fprintln(reportOutput, string(export))
+
+	fmt.Printf("\n - %s exported from %s\n", event.Name, event.Bucket)
+	fmt.Print(" ! Once imported, manage the cluster from the new state store only\n")
+}
+
+// parse and validate a cluster export
+func readClusterExport(data []byte) (clusterExport, error) {
+	var export clusterExport
+
+	if err := json.Unmarshal(data, &export); err != nil {
+		return export, err
+	}
+
+	if export.Version != exportVersion {
+		return export, fmt.Errorf("unsupported export version %d", export.Version)
+	}
+
+	if export.Name == "" || export.Project == "" || len(export.Checkpoint) == 0 {
+		return export, fmt.Errorf("export is missing the cluster name, project or checkpoint")
+	}
+
+	return export, nil
+}
+
+// checkpoint of an imported cluster in the importing user's project
+func importCheckpoint(export clusterExport, user string) ([]byte, error) {
+	stack := export.Name + "-eks"
+
+	checkpoint := renameCheckpointProject(export.Checkpoint, stack, export.Project, pulumiProject(user))
+
+	project, err := checkpointProject(checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if project != pulumiProject(user) {
+		return nil, fmt.Errorf("stack %s still references project %s after renaming from %s", stack, project, export.Project)
+	}
+
+	return checkpoint, nil
+}
+
+// Dispatch metadata of an imported cluster, exports of earlier versions carry it in the checkpoint
+func importMetadata(export clusterExport, user string, now time.Time) map[string]string {
+	stored := export.Metadata
+	if stored == nil {
+		stored = checkpointConfig(export.Checkpoint)
+	}
+
+	// fixed AWS names of earlier versions are derived from the owner replaced below
+	if owner := stored[ownerConfigKey]; owner != "" {
+		stored = mergeStackConfig(stored, clusterNameConfig(strings.ReplaceAll(export.Name, ".", "-"), owner, stored))
+		stored = mergeStackConfig(stored, irsaNameConfig(owner, stored))
+	}
+
+	return mergeStackConfig(stored, map[string]string{
+		ownerConfigKey:  user,
+		importedFromKey: export.Owner + " (" + export.Bucket + ")",
+		importedAtKey:   now.Format(time.RFC3339),
+	})
+}
+
+// add an exported cluster to this user's state store and project
+func importCluster(event Event) {
+	data, err := os.ReadFile(event.File)
+	if err != nil {
+		reportErr(err, "read cluster export "+event.File)
+	}
+
+	export, err := readClusterExport(data)
+	if err != nil {
+		reportErr(err, "read cluster export "+event.File)
+	}
+
+	if _, exists := getStackSummaries(event.Bucket)[export.Name]; exists {
+		reportErr(fmt.Errorf("cluster %s already exists in %s", export.Name, event.Bucket), "import cluster")
+	}
+
+	now := time.Now().UTC()
+
+	checkpoint, err := importCheckpoint(export, event.User)
+	if err != nil {
+		reportErr(err, "import cluster "+export.Name)
+	}
+
+	writeStackMetadata(event.Bucket, export.Name, importMetadata(export, event.User, now))
+
+	// metadata without a checkpoint is removed so a failed import leaves nothing behind
+	if err := storeStateObject(event.Bucket, stackKey(export.Name), checkpoint); err != nil {
+		deleteStackMetadata(event.Bucket, export.Name)
+		reportErr(err, "write stack checkpoint")
+	}
+
+	delete(loadedSummaries, event.Bucket)
+
+	fmt.Printf("\n - %s imported from %s into %s (project %s)\n", export.Name, export.Bucket, event.Bucket, pulumiProject(event.User))
+}
@@ -0,0 +1,125 @@
+package dispatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckpointProject(t *testing.T) {
+	project, err := checkpointProject([]byte(testCheckpoint))
+	if err != nil || project != "alice-dispatch" {
+		t.Errorf("checkpointProject unit test failure\n got: '%v', want: 'alice-dispatch', error: '%v'", project, err)
+	}
+
+	if _, err := checkpointProject([]byte(`{"checkpoint": {"stack": "empty-eks"}}`)); err == nil {
+		t.Error("checkpointProject unit test failure\n expected error for stack without resources")
+	}
+}
+
+func TestImportCheckpoint(t *testing.T) {
+	export := clusterExport{
+		Version:    exportVersion,
+		Name:       "my-cluster",
+		Project:    "alice-dispatch",
+		Owner:      "alice",
+		Bucket:     "alice-dispatch-state-store-123456789012",
+		Checkpoint: json.RawMessage(testCheckpoint),
+	}
+
+	checkpoint, err := importCheckpoint(export, "bob")
+	if err != nil {
+		t.Fatalf("importCheckpoint unit test failure\n error: '%v'", err)
+	}
+
+	if strings.Contains(string(checkpoint), "alice-dispatch::") || strings.Contains(string(checkpoint), "Stack::alice-dispatch") {
+		t.Errorf("importCheckpoint unit test failure\n project references not renamed: '%s'", checkpoint)
+	}
+
+	if !strings.Contains(string(checkpoint), "urn:pulumi:my-cluster-eks::bob-dispatch::pulumi:pulumi:Stack::bob-dispatch-my-cluster-eks") {
+		t.Errorf("importCheckpoint unit test failure\n stack resource not renamed: '%s'", checkpoint)
+	}
+
+	summary, err := summarizeCheckpoint(stackObject{Key: stackKey("my-cluster")}, checkpoint)
+	if err != nil || summary.Resources != 2 {
+		t.Errorf("importCheckpoint unit test failure\n got: '%+v', error: '%v'", summary, err)
+	}
+}
+
+func TestImportMetadata(t *testing.T) {
+	now := time.Date(2022, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	export := clusterExport{
+		Owner:      "alice",
+		Bucket:     "alice-dispatch-state-store-123456789012",
+		Checkpoint: json.RawMessage(testCheckpoint),
+		Metadata:   map[string]string{regionConfigKey: "eu-west-1", ownerConfigKey: "alice", protectedKey: "true"},
+	}
+
+	want := map[string]string{
+		regionConfigKey: "eu-west-1",
+		ownerConfigKey:  "bob",
+		irsaNameSeedKey: "alice",
+		protectedKey:    "true",
+		importedFromKey: "alice (alice-dispatch-state-store-123456789012)",
+		importedAtKey:   "2022-12-01T12:00:00Z",
+	}
+
+	if got := importMetadata(export, "bob", now); !reflect.DeepEqual(got, want) {
+		t.Errorf("importMetadata unit test failure\n got: '%v', want: '%v'", got, want)
+	}
+
+	// exports of earlier versions carry the stack config in the checkpoint
+	export.Metadata = nil
+
+	if got := importMetadata(export, "bob", now); got[regionConfigKey] != "us-west-2" || got[ownerConfigKey] != "bob" {
+		t.Errorf("importMetadata unit test failure\n got: '%v', want the checkpoint region us-west-2", got)
+	}
+
+	// logging clusters of earlier versions keep the EKS cluster name derived from the exporting owner
+	export.Name = "my.cluster"
+	export.Metadata = map[string]string{ownerConfigKey: "alice", logTypesKey: "api"}
+
+	if got := importMetadata(export, "bob", now); got[eksNameKey] != eksClusterName("my-cluster", "alice") {
+		t.Errorf("importMetadata unit test failure\n got: '%v', want the EKS cluster name of alice", got[eksNameKey])
+	}
+}
+
+func TestReadClusterExport(t *testing.T) {
+	// input export file contents
+	// return error for invalid exports
+	tests := []struct {
+		name string
+		data string
+		err  bool
+	}{
+		{
+			name: "Valid",
+			data: `{"version": 1, "name": "my-cluster", "project": "alice-dispatch", "checkpoint": {"version": 3}}`,
+		},
+		{
+			name: "Unsupported version",
+			data: `{"version": 2, "name": "my-cluster", "project": "alice-dispatch", "checkpoint": {"version": 3}}`,
+			err:  true,
+		},
+		{
+			name: "Missing checkpoint",
+			data: `{"version": 1, "name": "my-cluster", "project": "alice-dispatch"}`,
+			err:  true,
+		},
+		{
+			name: "Invalid JSON",
+			data: `my-cluster`,
+			err:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := readClusterExport([]byte(test.data)); (err != nil) != test.err {
+				t.Errorf("readClusterExport unit test failure\n error: '%v'", err)
+			}
+		})
+	}
+}
@@ -3,17 +3,57 @@ package dispatch
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
 )
 
+// reports and exports are written to the original stdout, status output can be redirected to stderr
+var reportOutput io.Writer = os.Stdout
+
+// write status output to stderr so reports and exports written to reportOutput can be redirected or parsed
+func redirectStatusOutput() {
+	reportOutput = os.Stdout
+	os.Stdout = os.Stderr
+}
+
 type TUIEventAPI interface {
 	getTUIAction() string
 	tuiCreate() []string
-	tuiDelete(cluster []map[string]string) string
+	tuiDelete(cluster []map[string]string) []string
+	tuiClone(clusters []map[string]string) (string, []string)
 	getClusters(Bucket string) []string
 	getClusterCreationDate(Bucket string, cluster string) string
+	isClusterProtected(Bucket string, cluster string) bool
+}
+
+// AWS credential flags shared by subcommands
+func credentialFlags(command *flag.FlagSet, event *Event) {
+	command.StringVar(&event.RoleARN, "role-arn", "", "IAM role ARN to assume, comma separated ARNs are assumed in order")
+	command.StringVar(&event.ExternalID, "external-id", "", "external ID for the assumed IAM role")
+	command.StringVar(&event.SessionName, "session-name", "", "assumed IAM role session name (default \"dispatch-<uid>\")")
+	command.StringVar(&event.MFASerial, "mfa-serial", "", "MFA device serial number or ARN used to assume the IAM role")
+}
+
+// -region usage of commands on existing clusters, which keep the region stored with the stack, and of new clusters
+const (
+	clusterRegionUsage    string = "AWS region of the session and of clusters without a stored region (default $AWS_REGION or \"us-east-1\")"
+	newClusterRegionUsage string = "AWS region of the session and of the new cluster (default $AWS_REGION or \"us-east-1\")"
+)
+
+// region flag shared by subcommands, the region is also used for the AWS session
+func regionFlag(command *flag.FlagSet, event *Event, usage string) {
+	command.StringVar(&event.Region, "region", "", usage)
+}
+
+// NAT gateways can't be removed from the private subnets, a sleeping cluster keeps at least one gateway running
+const sleepNATUsage string = "reduce NAT gateways to a single gateway while the cluster sleeps, the remaining gateway keeps running and is billed"
+
+// flags selecting several clusters for bulk operations
+func selectionFlags(command *flag.FlagSet, event *Event) {
+	command.StringVar(&event.Selector, "selector", "", "select clusters by label, comma separated key=value pairs (e.g. owner=alice,region=us-east-1)")
+	command.IntVar(&event.Parallel, "parallel", defaultBulkWorkers, "clusters changed at a time when several clusters are selected")
 }
 
 func CLICreate(event *Event) Event {
@@ -23,6 +63,25 @@ func CLICreate(event *Event) Event {
 	nodeCount := createCommand.String("nodes", "2", "cluster node count")
 	createVersion := createCommand.String("version", k8sVersion, "Kubernetes version")
 	createYOLO := createCommand.Bool("yes", false, "skip verification prompt for cluster creation")
+	createCommand.StringVar(&event.TTL, "ttl", "", "cluster time-to-live before expiry (e.g. 8h, 2d)")
+	createCommand.BoolVar(&event.Protect, "protect", false, "protect the cluster from deletion until dispatch unprotect is run")
+	createCommand.BoolVar(&event.PrivateEndpoint, "private-endpoint", false, "enable private Kubernetes API access from within the cluster VPC, the public endpoint stays enabled since Dispatch deploys cluster resources through it, private-only endpoints are not supported")
+	createCommand.StringVar(&event.PublicCIDRs, "public-cidrs", "", "comma separated CIDR blocks allowed to reach the public Kubernetes API, my-ip resolves to your egress IP, enables private access for nodes (default 0.0.0.0/0)")
+	createCommand.StringVar(&event.LogTypes, "log-types", "", "comma separated control plane log types sent to CloudWatch Logs (api, audit, authenticator, controllerManager, scheduler or all)")
+	createCommand.StringVar(&event.LogRetention, "log-retention", "", "days CloudWatch Logs retains control plane logs (default 30)")
+	createCommand.StringVar(&event.KMSKey, "kms-key", "", "KMS key ARN for envelope encryption of Kubernetes secrets, create adds a dedicated key")
+
+	event.Tags = map[string]string{}
+	createCommand.Var(tagFlags(event.Tags), "tag", "tag applied to every cluster resource as `key=value`, repeatable (e.g. -tag cost-center=1234)")
+
+	createCommand.StringVar(&event.File, "irsa-file", "", "IRSA spec file of service accounts granted IAM roles")
+	createCommand.BoolVar(&event.NoCertManager, "no-cert-manager", false, "skip the cert-manager IAM role for ACME DNS01 challenges")
+	createCommand.StringVar(&event.DNSZone, "dns-zone", "", "public Route53 hosted zone the cert-manager role may change records in (default every hosted zone)")
+	createCommand.Var(adminFlags{kind: roleKind, mappings: &event.AccessMappings}, "admin-role", "IAM role `arn` granted Kubernetes admin access, repeatable")
+	createCommand.Var(adminFlags{kind: userKind, mappings: &event.AccessMappings}, "admin-user", "IAM user `arn` granted Kubernetes admin access, repeatable")
+	regionFlag(createCommand, event, newClusterRegionUsage)
+
+	credentialFlags(createCommand, event)
 
 	err := createCommand.Parse(os.Args[2:])
 	if err != nil {
@@ -40,9 +99,15 @@ func CLICreate(event *Event) Event {
 
 func CLIDelete(event *Event) Event {
 	deleteCommand := flag.NewFlagSet("delete", flag.ExitOnError)
-	deleteName := deleteCommand.String("name", "", "cluster name")
+	deleteName := deleteCommand.String("name", "", "cluster name or name pattern (e.g. 'pr-*')")
 	deleteYOLO := deleteCommand.Bool("yes", false, "skip verification prompt for cluster deletion")
 
+	deleteCommand.BoolVar(&event.SkipCleanup, "skip-k8s-cleanup", false, "skip deleting Kubernetes load balancers, ingresses and volume claims before destroy")
+
+	regionFlag(deleteCommand, event, clusterRegionUsage)
+	selectionFlags(deleteCommand, event)
+	credentialFlags(deleteCommand, event)
+
 	err := deleteCommand.Parse(os.Args[2:])
 	if err != nil {
 		reportErr(err, " parse delete command")
@@ -54,6 +119,391 @@ func CLIDelete(event *Event) Event {
 	return *event
 }
 
+func CLIList(event *Event) Event {
+	listCommand := flag.NewFlagSet("list", flag.ExitOnError)
+	regionFlag(listCommand, event, "AWS region of the session and of listed clusters (default $AWS_REGION or \"us-east-1\")")
+	listCommand.BoolVar(&event.AllRegions, "all-regions", false, "list clusters in every region")
+
+	event.Tags = map[string]string{}
+	listCommand.Var(tagFlags(event.Tags), "tag", "only list clusters with the tag `key=value`, repeatable")
+
+	credentialFlags(listCommand, event)
+
+	err := listCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse list command")
+	}
+
+	return *event
+}
+
+func CLIReap(event *Event) Event {
+	reapCommand := flag.NewFlagSet("reap", flag.ExitOnError)
+	reapCommand.BoolVar(&event.DryRun, "dry-run", false, "report expired clusters without destroying them")
+	reapCommand.StringVar(&event.Output, "output", "text", "report format, text or json")
+	reapCommand.BoolVar(&event.Verified, "yes", false, "skip verification prompt for expired cluster deletion")
+
+	regionFlag(reapCommand, event, clusterRegionUsage)
+	credentialFlags(reapCommand, event)
+
+	err := reapCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse reap command")
+	}
+
+	return *event
+}
+
+func CLIExtend(event *Event) Event {
+	extendCommand := flag.NewFlagSet("extend", flag.ExitOnError)
+	extendName := extendCommand.String("name", "", "cluster name")
+	extendCommand.StringVar(&event.ExtendBy, "by", "", "duration to extend the cluster expiry by (e.g. 4h, 1d)")
+
+	regionFlag(extendCommand, event, clusterRegionUsage)
+	credentialFlags(extendCommand, event)
+
+	err := extendCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse extend command")
+	}
+
+	event.Name = strings.ToLower(*extendName)
+
+	return *event
+}
+
+func CLIProtect(event *Event, action string) Event {
+	protectCommand := flag.NewFlagSet(action, flag.ExitOnError)
+	protectName := protectCommand.String("name", "", "cluster name")
+
+	regionFlag(protectCommand, event, clusterRegionUsage)
+	credentialFlags(protectCommand, event)
+
+	err := protectCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse "+action+" command")
+	}
+
+	event.Name = strings.ToLower(*protectName)
+
+	return *event
+}
+
+func CLICost(event *Event) Event {
+	costCommand := flag.NewFlagSet("cost", flag.ExitOnError)
+	costName := costCommand.String("name", "", "cluster name (default all clusters)")
+	costCommand.StringVar(&event.Output, "output", "text", "report format, text or json")
+
+	regionFlag(costCommand, event, clusterRegionUsage)
+	credentialFlags(costCommand, event)
+
+	err := costCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse cost command")
+	}
+
+	event.Name = strings.ToLower(*costName)
+
+	return *event
+}
+
+func CLIDrift(event *Event) Event {
+	driftCommand := flag.NewFlagSet("drift", flag.ExitOnError)
+	driftName := driftCommand.String("name", "", "cluster name")
+	driftCommand.BoolVar(&event.All, "all", false, "check every cluster for drift")
+	driftCommand.StringVar(&event.Output, "output", "text", "report format, text or json")
+
+	regionFlag(driftCommand, event, clusterRegionUsage)
+	credentialFlags(driftCommand, event)
+
+	err := driftCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse drift command")
+	}
+
+	event.Name = strings.ToLower(*driftName)
+
+	return *event
+}
+
+func CLIOrphans(event *Event) Event {
+	orphansCommand := flag.NewFlagSet("orphans", flag.ExitOnError)
+	regionFlag(orphansCommand, event, "AWS region of the session and scanned for orphaned resources (default $AWS_REGION or \"us-east-1\")")
+	orphansCommand.BoolVar(&event.AllRegions, "all-regions", false, "scan every region for orphaned resources")
+	orphansCommand.BoolVar(&event.Cleanup, "cleanup", false, "delete orphaned resources in dependency order")
+	orphansCommand.StringVar(&event.Output, "output", "text", "report format, text or json")
+	orphansCommand.BoolVar(&event.Verified, "yes", false, "skip verification prompt for orphaned resource deletion")
+
+	credentialFlags(orphansCommand, event)
+
+	err := orphansCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse orphans command")
+	}
+
+	return *event
+}
+
+func CLISleep(event *Event) Event {
+	sleepCommand := flag.NewFlagSet("sleep", flag.ExitOnError)
+	sleepName := sleepCommand.String("name", "", "cluster name or name pattern (e.g. 'pr-*')")
+	sleepCommand.BoolVar(&event.SleepNAT, "nat", false, sleepNATUsage)
+	sleepCommand.BoolVar(&event.Verified, "yes", false, "skip verification prompt for cluster sleep")
+
+	regionFlag(sleepCommand, event, clusterRegionUsage)
+	selectionFlags(sleepCommand, event)
+	credentialFlags(sleepCommand, event)
+
+	err := sleepCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse sleep command")
+	}
+
+	event.Name = strings.ToLower(*sleepName)
+
+	return *event
+}
+
+func CLIWake(event *Event) Event {
+	wakeCommand := flag.NewFlagSet("wake", flag.ExitOnError)
+	wakeName := wakeCommand.String("name", "", "cluster name or name pattern (e.g. 'pr-*')")
+	wakeCommand.BoolVar(&event.Verified, "yes", false, "skip verification prompt for cluster wake")
+
+	regionFlag(wakeCommand, event, clusterRegionUsage)
+	selectionFlags(wakeCommand, event)
+	credentialFlags(wakeCommand, event)
+
+	err := wakeCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse wake command")
+	}
+
+	event.Name = strings.ToLower(*wakeName)
+
+	return *event
+}
+
+func CLISchedule(event *Event) Event {
+	scheduleCommand := flag.NewFlagSet("schedule", flag.ExitOnError)
+	scheduleName := scheduleCommand.String("name", "", "cluster name")
+	scheduleDays := scheduleCommand.String("days", "mon-fri", "days the cluster is awake (e.g. mon-fri, sat,sun, daily)")
+	scheduleHours := scheduleCommand.String("hours", "", "hours the cluster is awake (e.g. 08:00-19:00)")
+	scheduleTimezone := scheduleCommand.String("timezone", "UTC", "schedule timezone (e.g. America/New_York)")
+	scheduleClear := scheduleCommand.Bool("clear", false, "remove the cluster schedule")
+	scheduleCommand.BoolVar(&event.SleepNAT, "nat", false, sleepNATUsage)
+
+	regionFlag(scheduleCommand, event, clusterRegionUsage)
+	credentialFlags(scheduleCommand, event)
+
+	err := scheduleCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse schedule command")
+	}
+
+	event.Name = strings.ToLower(*scheduleName)
+
+	if !*scheduleClear {
+		event.Schedule = fmt.Sprintf("%s %s %s", strings.ToLower(*scheduleDays), *scheduleHours, *scheduleTimezone)
+	}
+
+	return *event
+}
+
+func CLIAccess(event *Event, command string) Event {
+	var groups, username string
+
+	accessCommand := flag.NewFlagSet("access "+command, flag.ExitOnError)
+	accessName := accessCommand.String("name", "", "cluster name")
+	accessYOLO := new(bool)
+
+	if command != accessList {
+		accessCommand.StringVar(&event.PrincipalARN, "arn", "", "IAM role or user ARN")
+		accessYOLO = accessCommand.Bool("yes", false, "skip verification prompt for the access update")
+	}
+
+	if command == accessAdd {
+		accessCommand.StringVar(&groups, "groups", adminGroup, "comma separated Kubernetes groups granted to the IAM principal")
+		accessCommand.StringVar(&username, "username", "", "Kubernetes username of the IAM principal (default the IAM role or user name)")
+	}
+
+	regionFlag(accessCommand, event, clusterRegionUsage)
+	credentialFlags(accessCommand, event)
+
+	err := accessCommand.Parse(os.Args[3:])
+	if err != nil {
+		reportErr(err, " parse access command")
+	}
+
+	event.Name = strings.ToLower(*accessName)
+	event.Subcommand = command
+	event.Verified = *accessYOLO
+
+	if command == accessAdd {
+		event.AccessMappings = []accessMapping{{ARN: event.PrincipalARN, Username: username, Groups: splitList(groups)}}
+	}
+
+	return *event
+}
+
+func CLIIRSA(event *Event, command string) Event {
+	var policyARNs repeatedFlag
+
+	var policyFile string
+
+	irsaCommand := flag.NewFlagSet("irsa "+command, flag.ExitOnError)
+	irsaName := irsaCommand.String("name", "", "cluster name")
+	irsaYOLO := irsaCommand.Bool("yes", false, "skip verification prompt for the service account role update")
+
+	irsaCommand.StringVar(&event.ServiceAccount, "sa", "", "Kubernetes service account as namespace/name")
+
+	if command == irsaAdd {
+		irsaCommand.Var(&policyARNs, "policy-arn", "managed IAM policy `arn` attached to the service account role, repeatable")
+		irsaCommand.StringVar(&policyFile, "policy", "", "JSON IAM policy document file added as the role's inline policy")
+		irsaCommand.StringVar(&event.File, "f", "", "IRSA spec file of service accounts, instead of -sa")
+	}
+
+	regionFlag(irsaCommand, event, clusterRegionUsage)
+	credentialFlags(irsaCommand, event)
+
+	err := irsaCommand.Parse(os.Args[3:])
+	if err != nil {
+		reportErr(err, " parse irsa command")
+	}
+
+	event.Name = strings.ToLower(*irsaName)
+	event.Subcommand = command
+	event.Verified = *irsaYOLO
+
+	if command == irsaAdd && event.ServiceAccount != "" {
+		role := serviceAccountRole{ServiceAccount: event.ServiceAccount, PolicyARNs: policyARNs}
+
+		if policyFile != "" {
+			policy, err := os.ReadFile(policyFile)
+			if err != nil {
+				reportErr(err, "read IAM policy document")
+			}
+
+			role.Policy = string(policy)
+		}
+
+		event.ServiceAccounts = []serviceAccountRole{role}
+	}
+
+	return *event
+}
+
+func CLIDescribe(event *Event) Event {
+	describeCommand := flag.NewFlagSet("describe", flag.ExitOnError)
+	describeName := describeCommand.String("name", "", "cluster name")
+
+	regionFlag(describeCommand, event, clusterRegionUsage)
+	credentialFlags(describeCommand, event)
+
+	err := describeCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse describe command")
+	}
+
+	event.Name = strings.ToLower(*describeName)
+
+	return *event
+}
+
+func CLIScheduler(event *Event) Event {
+	schedulerCommand := flag.NewFlagSet("scheduler run", flag.ExitOnError)
+	schedulerCommand.BoolVar(&event.Once, "once", false, "evaluate schedules once and exit, for cron jobs")
+	schedulerCommand.StringVar(&event.Interval, "interval", defaultSchedulerInterval.String(), "time between schedule evaluations")
+	schedulerCommand.BoolVar(&event.DryRun, "dry-run", false, "log scheduled transitions without applying them")
+
+	regionFlag(schedulerCommand, event, clusterRegionUsage)
+	credentialFlags(schedulerCommand, event)
+
+	err := schedulerCommand.Parse(os.Args[3:])
+	if err != nil {
+		reportErr(err, " parse scheduler command")
+	}
+
+	return *event
+}
+
+func CLIClone(event *Event) Event {
+	cloneCommand := flag.NewFlagSet("clone", flag.ExitOnError)
+	cloneFrom := cloneCommand.String("from", "", "source cluster name")
+	cloneName := cloneCommand.String("name", "", "new cluster name")
+	cloneCommand.StringVar(&event.Size, "size", "", "cluster node size (default source cluster size)")
+	cloneCommand.StringVar(&event.Count, "nodes", "", "cluster node count (default source cluster count)")
+	cloneCommand.StringVar(&event.Version, "version", "", "Kubernetes version (default source cluster version)")
+	cloneCommand.StringVar(&event.TTL, "ttl", "", "cluster time-to-live before expiry (e.g. 8h, 2d)")
+	regionFlag(cloneCommand, event, "AWS region of the session and of the new cluster (default source cluster region)")
+	cloneCommand.BoolVar(&event.Verified, "yes", false, "skip verification prompt for cluster creation")
+
+	event.Tags = map[string]string{}
+	cloneCommand.Var(tagFlags(event.Tags), "tag", "tag applied to every cluster resource as `key=value`, repeatable, source cluster tags are kept unless overridden")
+
+	credentialFlags(cloneCommand, event)
+
+	err := cloneCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse clone command")
+	}
+
+	event.CloneFrom = strings.ToLower(*cloneFrom)
+	event.Name = strings.ToLower(*cloneName)
+
+	return *event
+}
+
+func CLIExport(event *Event) Event {
+	exportCommand := flag.NewFlagSet("export", flag.ExitOnError)
+	exportName := exportCommand.String("name", "", "cluster name")
+
+	regionFlag(exportCommand, event, clusterRegionUsage)
+	credentialFlags(exportCommand, event)
+
+	err := exportCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse export command")
+	}
+
+	event.Name = strings.ToLower(*exportName)
+
+	return *event
+}
+
+func CLIImport(event *Event) Event {
+	importCommand := flag.NewFlagSet("import", flag.ExitOnError)
+	importCommand.StringVar(&event.File, "f", "", "cluster export file")
+
+	regionFlag(importCommand, event, clusterRegionUsage)
+	credentialFlags(importCommand, event)
+
+	err := importCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse import command")
+	}
+
+	return *event
+}
+
+func CLIAdopt(event *Event) Event {
+	adoptCommand := flag.NewFlagSet("adopt", flag.ExitOnError)
+	adoptName := adoptCommand.String("name", "", "Dispatch cluster name")
+	adoptCommand.StringVar(&event.EKSCluster, "eks-cluster", "", "name of the existing EKS cluster")
+	regionFlag(adoptCommand, event, "AWS region of the session and of the EKS cluster (default $AWS_REGION or \"us-east-1\")")
+	adoptCommand.BoolVar(&event.Verified, "yes", false, "skip verification prompt for cluster adoption")
+
+	credentialFlags(adoptCommand, event)
+
+	err := adoptCommand.Parse(os.Args[2:])
+	if err != nil {
+		reportErr(err, " parse adopt command")
+	}
+
+	event.Name = strings.ToLower(*adoptName)
+
+	return *event
+}
+
 func CLIWorkflow(dispatchVersion string, event *Event) Event {
 	action := os.Args[1]
 
@@ -77,12 +527,251 @@ func CLIWorkflow(dispatchVersion string, event *Event) Event {
 			}
 		}
 
+		if event.TTL != "" {
+			if _, err := parseTTL(event.TTL); err != nil {
+				reportErr(err, "provide valid cluster time-to-live")
+			}
+		}
+
+		if err := validateTags(event.Tags); err != nil {
+			reportErr(err, "provide valid cluster tags")
+		}
+
+		if err := validateControlPlane(*event); err != nil {
+			reportErr(err, "provide valid control plane options")
+		}
+
+		if event.File != "" {
+			spec, err := loadIRSASpec(event.File)
+			if err != nil {
+				reportErr(err, "load IRSA spec")
+			}
+
+			event.ServiceAccounts = spec.ServiceAccounts
+
+			// cert-manager flags override the spec file
+			if spec.CertManager != nil && !event.NoCertManager && event.DNSZone == "" {
+				event.NoCertManager = spec.CertManager.Disabled
+				event.DNSZone = spec.CertManager.DNSZone
+			}
+		}
+
+		if err := validateServiceAccountRoles(event.ServiceAccounts); err != nil {
+			reportErr(err, "provide valid service account roles")
+		}
+
+		if err := validateCertManager(*event); err != nil {
+			reportErr(err, "provide valid cert-manager options")
+		}
+
 	case "delete":
 		*event = CLIDelete(event)
 		event.Action = action
 
+		if event.Name == "" && event.Selector == "" {
+			fmt.Println(" ! delete events require the -name or -selector flag")
+
+			event.Action = exitStatus
+		} else {
+			validateSelection(*event)
+		}
+
+	case "list":
+		*event = CLIList(event)
+		event.Action = action
+
+	case "reap":
+		*event = CLIReap(event)
+		event.Action = action
+
+		if event.Output == jsonOutput {
+			redirectStatusOutput()
+		}
+
+	case "extend":
+		*event = CLIExtend(event)
+		event.Action = action
+
+		if event.Name == "" || event.ExtendBy == "" {
+			fmt.Println(" ! extend events require the -name and -by flags")
+
+			event.Action = exitStatus
+		} else if _, err := parseTTL(event.ExtendBy); err != nil {
+			reportErr(err, "provide valid cluster extension")
+		}
+
+	case "protect", "unprotect":
+		*event = CLIProtect(event, action)
+		event.Action = action
+
+		if event.Name == "" {
+			fmt.Printf(" ! %s events require the -name flag\n", action)
+
+			event.Action = exitStatus
+		}
+
+	case "cost":
+		*event = CLICost(event)
+		event.Action = action
+
+		if event.Output == jsonOutput {
+			redirectStatusOutput()
+		}
+
+	case "drift":
+		*event = CLIDrift(event)
+		event.Action = action
+
+		if (event.Name == "") == !event.All {
+			fmt.Println(" ! drift events require either the -name or -all flag")
+
+			event.Action = exitStatus
+		} else if event.Output == jsonOutput {
+			redirectStatusOutput()
+		}
+
+	case "orphans":
+		*event = CLIOrphans(event)
+		event.Action = action
+
+		if event.Output == jsonOutput {
+			redirectStatusOutput()
+		}
+
+	case "sleep", "wake":
+		if action == sleepAction {
+			*event = CLISleep(event)
+		} else {
+			*event = CLIWake(event)
+		}
+
+		event.Action = action
+
+		if event.Name == "" && event.Selector == "" {
+			fmt.Printf(" ! %s events require the -name or -selector flag\n", action)
+
+			event.Action = exitStatus
+		} else {
+			validateSelection(*event)
+		}
+
+	case "schedule":
+		*event = CLISchedule(event)
+		event.Action = action
+
+		if event.Name == "" {
+			fmt.Println(" ! schedule events require the -name flag")
+
+			event.Action = exitStatus
+		} else if event.Schedule != "" {
+			if _, err := parseSchedule(event.Schedule); err != nil {
+				reportErr(err, "provide valid cluster schedule")
+			}
+		}
+
+	case "access":
+		if len(os.Args) < 3 || (os.Args[2] != accessAdd && os.Args[2] != accessRemove && os.Args[2] != accessList) {
+			fmt.Println(" ! access events require the add, remove or list command, dispatch access add -h")
+
+			event.Action = exitStatus
+
+			break
+		}
+
+		*event = CLIAccess(event, os.Args[2])
+		event.Action = action
+
+		switch {
+		case event.Name == "":
+			fmt.Printf(" ! access %s events require the -name flag\n", event.Subcommand)
+
+			event.Action = exitStatus
+		case event.Subcommand == accessList:
+		case principalKind(event.PrincipalARN) == "":
+			reportErr(fmt.Errorf("invalid IAM principal ARN '%s', use an IAM role or user ARN with the -arn flag", event.PrincipalARN), "provide valid access mapping")
+		default:
+			if err := validateAccessMappings(event.AccessMappings); err != nil {
+				reportErr(err, "provide valid access mapping")
+			}
+		}
+
+	case "irsa":
+		if len(os.Args) < 3 || (os.Args[2] != irsaAdd && os.Args[2] != irsaRemove) {
+			fmt.Println(" ! irsa events require the add or remove command, dispatch irsa add -h")
+
+			event.Action = exitStatus
+
+			break
+		}
+
+		*event = CLIIRSA(event, os.Args[2])
+		event.Action = action
+
+		if event.File != "" {
+			spec, err := loadIRSASpec(event.File)
+			if err != nil {
+				reportErr(err, "load IRSA spec")
+			}
+
+			if spec.CertManager != nil {
+				fmt.Printf(" ! cert_manager options of %s are only applied by dispatch create, ignoring them\n", event.File)
+			}
+
+			event.ServiceAccounts = spec.ServiceAccounts
+		}
+
+		switch {
+		case event.Name == "" || (event.ServiceAccount == "" && event.File == ""):
+			fmt.Printf(" ! irsa %s events require the -name and -sa flags\n", event.Subcommand)
+
+			event.Action = exitStatus
+		case event.Subcommand == irsaRemove:
+		default:
+			if err := validateServiceAccountRoles(event.ServiceAccounts); err != nil {
+				reportErr(err, "provide valid service account roles")
+			}
+		}
+
+	case "describe":
+		*event = CLIDescribe(event)
+		event.Action = action
+
 		if event.Name == "" {
-			fmt.Println(" ! delete events require the -name flag")
+			fmt.Println(" ! describe events require the -name flag")
+
+			event.Action = exitStatus
+		}
+
+	case "scheduler":
+		if len(os.Args) < 3 || os.Args[2] != "run" {
+			fmt.Println(" ! scheduler events require the run command, dispatch scheduler run -h")
+
+			event.Action = exitStatus
+
+			break
+		}
+
+		*event = CLIScheduler(event)
+		event.Action = action
+
+	case "clone":
+		*event = CLIClone(event)
+		event.Action = action
+
+		if event.Name == "" || event.CloneFrom == "" {
+			fmt.Println(" ! clone events require the -from and -name flags")
+
+			event.Action = exitStatus
+		} else {
+			validateCloneEvent(*event)
+		}
+
+	case "adopt":
+		*event = CLIAdopt(event)
+		event.Action = action
+
+		if event.Name == "" || event.EKSCluster == "" {
+			fmt.Println(" ! adopt events require the -name and -eks-cluster flags")
 
 			event.Action = exitStatus
 		} else {
@@ -92,8 +781,35 @@ func CLIWorkflow(dispatchVersion string, event *Event) Event {
 			}
 		}
 
+	case "export":
+		*event = CLIExport(event)
+		event.Action = action
+
+		if event.Name == "" {
+			fmt.Println(" ! export events require the -name flag")
+
+			event.Action = exitStatus
+		} else {
+			redirectStatusOutput()
+		}
+
+	case "import":
+		*event = CLIImport(event)
+		event.Action = action
+
+		if event.File == "" {
+			fmt.Println(" ! import events require the -f flag")
+
+			event.Action = exitStatus
+		}
+
+	case "policy":
+		fmt.Println(dispatchPolicyJSON())
+
+		event.Action = exitStatus
+
 	case "-h":
-		fmt.Printf("Dispatch options:\n dispatch create -h\n dispatch delete -h\n")
+		fmt.Printf("Dispatch options:\n dispatch create -h\n dispatch delete -h\n dispatch list -h\n dispatch describe -h\n dispatch reap -h\n dispatch extend -h\n dispatch protect -h\n dispatch unprotect -h\n dispatch clone -h\n dispatch adopt -h\n dispatch export -h\n dispatch import -h\n dispatch cost -h\n dispatch drift -h\n dispatch orphans -h\n dispatch sleep -h\n dispatch wake -h\n dispatch schedule -h\n dispatch scheduler run -h\n dispatch access add -h\n dispatch access remove -h\n dispatch access list -h\n dispatch irsa add -h\n dispatch irsa remove -h\n dispatch policy\n")
 
 		event.Action = exitStatus
 
@@ -110,6 +826,9 @@ func CLIWorkflow(dispatchVersion string, event *Event) Event {
 func TUIWorkflow(te TUIEventAPI, event *Event) Event {
 	action := te.getTUIAction()
 
+	// confirmations of TUI sessions are also shown in the TUI
+	event.TUI = true
+
 	switch action {
 	case createAction:
 		createOptions := te.tuiCreate()
@@ -118,30 +837,60 @@ func TUIWorkflow(te TUIEventAPI, event *Event) Event {
 		event.Name = createOptions[0]
 		event.Size = createOptions[1]
 		event.Count = createOptions[2]
+		event.TTL = createOptions[3]
 		event.Version = k8sVersion
 
 		if event.Name == "" {
 			reportErr(fmt.Errorf("no cluster name provided"), "set cluster name")
 		}
 
-	case deleteAction:
-		var clusterList []map[string]string
+		if event.TTL != "" {
+			if _, err := parseTTL(event.TTL); err != nil {
+				reportErr(err, "provide valid cluster time-to-live")
+			}
+		}
 
-		existingClusters := te.getClusters(event.Bucket)
+	case cloneAction:
+		clusterList := clusterOptions(te, event.Bucket)
 
-		if len(existingClusters) > 0 {
-			for _, c := range existingClusters {
-				cluster := make(map[string]string)
-				cluster["name"] = c
-				cluster["date"] = te.getClusterCreationDate(event.Bucket, c)
-				clusterList = append(clusterList, cluster)
-			}
+		if len(clusterList) == 0 {
+			fmt.Print(" . No existing clusters to clone\n")
+
+			return Event{Action: exitStatus}
+		}
+
+		source, cloneOptions := te.tuiClone(clusterList)
+		if source == "" || len(cloneOptions) == 0 {
+			os.Exit(0)
+		}
 
+		event.Action = action
+		event.CloneFrom = source
+		event.Name = cloneOptions[0]
+		event.Size = cloneOptions[1]
+		event.Count = cloneOptions[2]
+		event.TTL = cloneOptions[3]
+
+		if event.Name == "" {
+			reportErr(fmt.Errorf("no cluster name provided"), "set cluster name")
+		}
+
+		validateCloneEvent(*event)
+
+	case deleteAction:
+		clusterList := clusterOptions(te, event.Bucket)
+
+		if len(clusterList) > 0 {
 			event.Action = action
-			event.Name = te.tuiDelete(clusterList)
 
-			if event.Name == "" {
+			// several selected clusters are deleted in parallel
+			switch names := te.tuiDelete(clusterList); len(names) {
+			case 0:
 				os.Exit(0)
+			case 1:
+				event.Name = names[0]
+			default:
+				event.Names = names
 			}
 		} else {
 			fmt.Print(" . No existing clusters to delete\n")
@@ -158,6 +907,64 @@ func TUIWorkflow(te TUIEventAPI, event *Event) Event {
 	return *event
 }
 
+// existing clusters with creation dates and deletion protection for TUI cluster selection
+func clusterOptions(te TUIEventAPI, bucket string) []map[string]string {
+	var clusterList []map[string]string
+
+	for _, c := range te.getClusters(bucket) {
+		cluster := make(map[string]string)
+		cluster["name"] = c
+		cluster["date"] = te.getClusterCreationDate(bucket, c)
+
+		if te.isClusterProtected(bucket, c) {
+			cluster["protected"] = "true"
+		}
+
+		clusterList = append(clusterList, cluster)
+	}
+
+	return clusterList
+}
+
+// cluster name, name pattern, selector and worker count of events that may select several clusters
+func validateSelection(event Event) {
+	if event.Name != "" && !namePattern(event.Name) {
+		if _, err := validateClusterName(event.Name); err != nil {
+			reportErr(err, "provide valid cluster name")
+		}
+	}
+
+	if _, err := parseSelector(event.Selector); err != nil {
+		reportErr(err, "provide valid cluster selector")
+	}
+
+	if event.Parallel < 1 {
+		reportErr(fmt.Errorf("invalid parallel value %d, at least one cluster must be changed at a time", event.Parallel), "provide valid parallel value")
+	}
+}
+
+func validateCloneEvent(event Event) {
+	if _, err := validateClusterName(event.Name); err != nil {
+		reportErr(err, "provide valid cluster name")
+	}
+
+	if event.Size != "" {
+		if _, err := getNodeSize(event.Size); err != nil {
+			reportErr(err, "provide valid cluster node size")
+		}
+	}
+
+	if event.TTL != "" {
+		if _, err := parseTTL(event.TTL); err != nil {
+			reportErr(err, "provide valid cluster time-to-live")
+		}
+	}
+
+	if err := validateTags(event.Tags); err != nil {
+		reportErr(err, "provide valid cluster tags")
+	}
+}
+
 func clusterExists(event Event) bool {
 	stackID := event.Name + "-eks"
 
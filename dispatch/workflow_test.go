@@ -2,13 +2,17 @@ package dispatch
 
 import (
 	"os"
+	"reflect"
 	"testing"
 )
 
 type mockTUIEvent struct {
 	action, FQDN, datestamp string
 	createDetails           []string
+	cloneDetails            []string
 	clusters                []string
+	protected               []string
+	selected                []string
 	err                     error
 }
 
@@ -20,9 +24,19 @@ func (e mockTUIEvent) tuiCreate() []string {
 	return e.createDetails
 }
 
-func (e mockTUIEvent) tuiDelete(clusters []map[string]string) string {
+func (e mockTUIEvent) tuiDelete(clusters []map[string]string) []string {
 	_ = clusters
-	return e.FQDN
+
+	if e.selected != nil || e.FQDN == "" {
+		return e.selected
+	}
+
+	return []string{e.FQDN}
+}
+
+func (e mockTUIEvent) tuiClone(clusters []map[string]string) (string, []string) {
+	_ = clusters
+	return e.FQDN, e.cloneDetails
 }
 
 func (e mockTUIEvent) getClusters(bucket string) []string {
@@ -41,6 +55,18 @@ func (e mockTUIEvent) getClusterCreationDate(bucket string, cluster string) stri
 	return e.datestamp
 }
 
+func (e mockTUIEvent) isClusterProtected(bucket string, cluster string) bool {
+	_ = bucket
+
+	for _, protected := range e.protected {
+		if protected == cluster {
+			return true
+		}
+	}
+
+	return false
+}
+
 func TestValidClusterName(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -90,6 +116,29 @@ func ExampleCLIWorkflow_help() {
 	// Output: Dispatch options:
 	//  dispatch create -h
 	//  dispatch delete -h
+	//  dispatch list -h
+	//  dispatch describe -h
+	//  dispatch reap -h
+	//  dispatch extend -h
+	//  dispatch protect -h
+	//  dispatch unprotect -h
+	//  dispatch clone -h
+	//  dispatch adopt -h
+	//  dispatch export -h
+	//  dispatch import -h
+	//  dispatch cost -h
+	//  dispatch drift -h
+	//  dispatch orphans -h
+	//  dispatch sleep -h
+	//  dispatch wake -h
+	//  dispatch schedule -h
+	//  dispatch scheduler run -h
+	//  dispatch access add -h
+	//  dispatch access remove -h
+	//  dispatch access list -h
+	//  dispatch irsa add -h
+	//  dispatch irsa remove -h
+	//  dispatch policy
 }
 
 func ExampleCLIWorkflow_createHelp() {
@@ -109,7 +158,7 @@ func ExampleCLIWorkflow_deleteHelp() {
 
 	CLIWorkflow("delete", event)
 
-	// Output:  ! delete events require the -name flag
+	// Output:  ! delete events require the -name or -selector flag
 }
 
 func ExampleCLIWorkflow_notValid() {
@@ -124,6 +173,61 @@ func ExampleCLIWorkflow_notValid() {
 	//  dispatch create -h or dispatch delete -h
 }
 
+func TestTUIWorkflowClone(t *testing.T) {
+	teAPI := mockTUIEvent{
+		action:       cloneAction,
+		FQDN:         "my-cluster",
+		datestamp:    "2022-12-01 12:00:00 UTC",
+		cloneDetails: []string{"my-cluster-repro", "", "4", "8h"},
+		clusters:     []string{pulumiStacksPath + "my-cluster-eks.json"},
+	}
+
+	event := TUIWorkflow(teAPI, &Event{})
+
+	if event.Action != cloneAction || event.CloneFrom != "my-cluster" || event.Name != "my-cluster-repro" ||
+		event.Size != "" || event.Count != "4" || event.TTL != "8h" {
+		t.Errorf("TUIWorkflow clone unit test failure\n got: '%+v'", event)
+	}
+}
+
+func TestTUIWorkflowDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		selected []string
+		want     Event
+	}{
+		{name: "Single", selected: []string{"pr-1"}, want: Event{Action: deleteAction, Name: "pr-1", TUI: true}},
+		{name: "Multiple", selected: []string{"pr-1", "pr-2"}, want: Event{Action: deleteAction, Names: []string{"pr-1", "pr-2"}, TUI: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			teAPI := mockTUIEvent{
+				action:   deleteAction,
+				selected: test.selected,
+				clusters: []string{pulumiStacksPath + "pr-1-eks.json", pulumiStacksPath + "pr-2-eks.json"},
+			}
+
+			if event := TUIWorkflow(teAPI, &Event{}); !reflect.DeepEqual(event, test.want) {
+				t.Errorf("TUIWorkflow delete unit test failure\n got: '%+v', want: '%+v'", event, test.want)
+			}
+		})
+	}
+}
+
+func TestClusterOptions(t *testing.T) {
+	teAPI := mockTUIEvent{
+		datestamp: "2022-12-01 12:00:00 UTC",
+		clusters:  []string{"shared-demo", "scratch"},
+		protected: []string{"shared-demo"},
+	}
+
+	options := clusterOptions(teAPI, "bucket")
+
+	if len(options) != 2 || options[0]["protected"] != "true" || options[1]["protected"] != "" {
+		t.Errorf("clusterOptions unit test failure\n got: '%v', want: 'shared-demo protected'", options)
+	}
+}
+
 func ExampleTUIWorkflow_notValid() {
 	teAPI := mockTUIEvent{}
 	testEvent := &Event{Action: "test"}
@@ -29,6 +29,17 @@ type kubeconfigFile struct {
 	Contexts       []map[string]string `yaml:"contexts"`
 }
 
+// Dispatch config file (~/.dispatch/dispatch.conf)
+type dispatchConfig struct {
+	UID            string            `yaml:"uid"`
+	AWSRetryMode   string            `yaml:"aws_retry_mode,omitempty"`
+	AWSMaxAttempts int               `yaml:"aws_max_attempts,omitempty"`
+	AWSAPITimeout  string            `yaml:"aws_api_timeout,omitempty"`
+	MaxLifetime    string            `yaml:"max_lifetime,omitempty"`
+	DefaultTags    map[string]string `yaml:"default_tags,omitempty"`
+	AccessMappings []accessMapping   `yaml:"access_mappings,omitempty"`
+}
+
 type workspace struct {
 	root       string
 	kube       string
@@ -65,11 +76,20 @@ func ensureKubeConfig(kubeDir string) {
 	}
 }
 
+// Dispatch kubeconfig, bulk operation subprocesses use a kubeconfig per cluster
+func dispatchKubeconfig(home string) string {
+	if path := os.Getenv(kubeconfigEnv); path != "" {
+		return path
+	}
+
+	return filepath.Join(home, ".dispatch", ".kube", "config")
+}
+
 func ClearKubeConfig() {
 	home, homeSet := os.LookupEnv("HOME")
 
 	if homeSet {
-		configFile := filepath.Join(home, ".dispatch", ".kube", "config")
+		configFile := dispatchKubeconfig(home)
 
 		_, readErr := os.Stat(configFile)
 
@@ -101,8 +121,8 @@ func ClearKubeConfig() {
 	}
 }
 
-func ensureDispatchConfig(dispatchDir string) string {
-	var dispatchUID string
+func ensureDispatchConfig(dispatchDir string) dispatchConfig {
+	var settings dispatchConfig
 
 	configFile := dispatchDir + "/dispatch.conf"
 
@@ -110,16 +130,14 @@ func ensureDispatchConfig(dispatchDir string) string {
 
 	if os.IsNotExist(readErr) {
 		fmt.Print(" + Please enter a user ID: ")
-		fmt.Scanf("%s", &dispatchUID)
+		fmt.Scanf("%s", &settings.UID)
 
-		if len(dispatchUID) == 0 {
+		if len(settings.UID) == 0 {
 			fmt.Println("   ! You must provide a user ID, exiting.")
 			os.Exit(0)
 		}
 
-		configMap := map[string]string{"uid": dispatchUID}
-
-		configData, err := yaml.Marshal(configMap)
+		configData, err := yaml.Marshal(settings)
 		if err != nil {
 			reportErr(err, "set UID")
 		}
@@ -134,18 +152,15 @@ func ensureDispatchConfig(dispatchDir string) string {
 			reportErr(readErr, "read Dispatch config file")
 		}
 
-		configMap := make(map[string]string)
-		yamlErr := yaml.Unmarshal(configData, &configMap)
+		yamlErr := yaml.Unmarshal(configData, &settings)
 		if yamlErr != nil {
-			reportErr(readErr, "set UID from config file")
+			reportErr(yamlErr, "set UID from config file")
 		}
 
-		dispatchUID = configMap["uid"]
-
-		fmt.Printf(" . Found user ID '%s'\n", configMap["uid"])
+		fmt.Printf(" . Found user ID '%s'\n", settings.UID)
 	}
 
-	return dispatchUID
+	return settings
 }
 
 func removePreviousPulumiBins(binPath string) {
@@ -283,8 +298,8 @@ func ensurePulumi(workDir workspace) {
 	}
 }
 
-func ensureWorkspace() string {
-	var dispatchUID string
+func ensureWorkspace() dispatchConfig {
+	var settings dispatchConfig
 
 	home, homeSet := os.LookupEnv("HOME")
 
@@ -301,27 +316,44 @@ func ensureWorkspace() string {
 		ensureDir(sessionDirs.root)
 		ensureKubeConfig(sessionDirs.kube)
 		ensurePulumi(sessionDirs)
-		dispatchUID = ensureDispatchConfig(sessionDirs.root)
+		settings = ensureDispatchConfig(sessionDirs.root)
 	} else {
 		fmt.Print("$HOME environment variable not found, exiting.\n")
 		os.Exit(1)
 	}
 
-	return dispatchUID
+	return settings
 }
 
 func EnsureDependencies(event *Event) Event {
 	fmt.Print("\nEnsuring dependencies:\n")
 
-	event.User = ensureWorkspace()
+	settings := ensureWorkspace()
+
+	event.User = settings.UID
+	event.MaxLifetime = settings.MaxLifetime
+	event.DefaultTags = settings.DefaultTags
+	event.DefaultAccess = settings.AccessMappings
 
-	clientConfig := awsClientConfig()
+	if event.Region != "" {
+		os.Setenv("AWS_REGION", event.Region)
+	}
 
-	testAWSCreds(*clientConfig)
+	setCredentialOptions(*event)
+	setSessionOptions(settings)
 
-	event.Bucket = ensureS3Bucket(*clientConfig, *event)
+	sess := getSession()
 
-	printExistingClusters(event.Bucket)
+	// the parent of a bulk operation has checked the caller's permissions
+	if !bulkChild() {
+		testAWSCreds(sess, event.User)
+	}
+
+	event.Bucket = ensureS3Bucket(sess, *event)
+
+	if event.Action != listAction && event.Action != costAction {
+		printExistingClusters(event.Bucket)
+	}
 
 	return *event
 }
@@ -43,5 +43,5 @@ func main() {
 		}
 	}
 
-	dispatch.Exec(sessionEvent)
+	dispatch.Run(sessionEvent)
 }
@@ -92,7 +92,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) View() string {
-	if m.choice == "create" {
+	if m.choice == "create" || m.choice == "clone" {
 		return quitTextStyle.Render(fmt.Sprintf("%s config:", m.choice))
 	} else if m.choice != "" {
 		return quitTextStyle.Render("")
@@ -108,6 +108,7 @@ func (m model) View() string {
 func Action() string {
 	items := []list.Item{
 		item("create"),
+		item("clone"),
 		item("delete"),
 	}
 
@@ -0,0 +1,82 @@
+package tuiconfirm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var confirmed bool
+
+var (
+	titleStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("196"))
+	detailStyle  = lipgloss.NewStyle().PaddingLeft(2)
+	focusedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	hintStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	docStyle     = lipgloss.NewStyle().Margin(1, 2)
+)
+
+type model struct {
+	title    string
+	details  []string
+	expected string
+	input    textinput.Model
+}
+
+func (m model) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case "enter":
+			confirmed = m.input.Value() == m.expected
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+
+	return m, cmd
+}
+
+func (m model) View() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s\n\n", titleStyle.Render(m.title))
+
+	for _, detail := range m.details {
+		fmt.Fprintf(&b, "%s\n", detailStyle.Render(detail))
+	}
+
+	fmt.Fprintf(&b, "\nType %s to confirm\n\n%s\n\n", focusedStyle.Render(m.expected), m.input.View())
+	b.WriteString(hintStyle.Render("enter to confirm, esc to cancel"))
+
+	return docStyle.Render(b.String())
+}
+
+// Confirm shows details of a destructive operation and returns true only when the expected value is typed
+func Confirm(title string, details []string, expected string) bool {
+	input := textinput.New()
+	input.PromptStyle = focusedStyle
+	input.TextStyle = focusedStyle
+	input.CharLimit = len(expected) + 32
+	input.Focus()
+
+	m := model{title: title, details: details, expected: expected, input: input}
+
+	if err := tea.NewProgram(m, tea.WithAltScreen()).Start(); err != nil {
+		fmt.Println("Error running program:", err)
+		os.Exit(1)
+	}
+
+	return confirmed
+}
@@ -28,9 +28,16 @@ type model struct {
 	cursorMode textinput.CursorMode
 }
 
-func initialModel() model {
+var createPlaceholders = []string{
+	"cluster name",
+	"node size (S)mall/(M)edium/(L)arge (default: S)",
+	"node count (default: 2)",
+	"time-to-live e.g. 8h, 2d (default: none)",
+}
+
+func initialModel(placeholders []string) model {
 	m := model{
-		inputs: make([]textinput.Model, 3),
+		inputs: make([]textinput.Model, len(placeholders)),
 	}
 
 	var t textinput.Model
@@ -38,17 +45,12 @@ func initialModel() model {
 		t = textinput.New()
 		t.CursorStyle = cursorStyle
 		t.CharLimit = 32
+		t.Placeholder = placeholders[i]
 
-		switch i {
-		case 0:
-			t.Placeholder = "cluster name"
+		if i == 0 {
 			t.Focus()
 			t.PromptStyle = focusedStyle
 			t.TextStyle = focusedStyle
-		case 1:
-			t.Placeholder = "node size (S)mall/(M)edium/(L)arge (default: S)"
-		case 2:
-			t.Placeholder = "node count (default: 2)"
 		}
 
 		m.inputs[i] = t
@@ -155,7 +157,7 @@ func (m model) View() string {
 }
 
 func Create() []string {
-	if err := tea.NewProgram(initialModel()).Start(); err != nil {
+	if err := tea.NewProgram(initialModel(createPlaceholders)).Start(); err != nil {
 		fmt.Printf("could not start program: %s\n", err)
 		os.Exit(1)
 	}
@@ -174,3 +176,24 @@ func Create() []string {
 
 	return eventOptions
 }
+
+// Clone collects the name and overrides of a new cluster cloned from source, empty overrides keep the source values
+func Clone(source string) []string {
+	placeholders := []string{
+		"new cluster name",
+		"node size (S)mall/(M)edium/(L)arge (default: " + source + " size)",
+		"node count (default: " + source + " count)",
+		"time-to-live e.g. 8h, 2d (default: none)",
+	}
+
+	if err := tea.NewProgram(initialModel(placeholders)).Start(); err != nil {
+		fmt.Printf("could not start program: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(eventOptions) == 0 {
+		os.Exit(0)
+	}
+
+	return eventOptions
+}
@@ -4,26 +4,88 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
-const lipglossStyleMargin = 2
+const (
+	lipglossStyleMargin = 2
+	protectedMarker     = "🔒 "
+)
 
 var option string
+var options []string
 var docStyle = lipgloss.NewStyle().Margin(1, lipglossStyleMargin)
 
+var toggleKey = key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "select"))
+
 type item struct {
 	title, desc string
+	protected   bool
+	multi       bool
+	checked     bool
+}
+
+func (i item) Title() string {
+	title := i.title
+	if i.protected {
+		title = protectedMarker + title
+	}
+
+	if !i.multi {
+		return title
+	}
+
+	if i.checked {
+		return "[x] " + title
+	}
+
+	return "[ ] " + title
 }
 
-func (i item) Title() string       { return i.title }
 func (i item) Description() string { return i.desc }
 func (i item) FilterValue() string { return i.title }
 
 type model struct {
-	list list.Model
+	list  list.Model
+	multi bool
+}
+
+// toggle the highlighted item of a multi-select list
+func (m *model) toggle() tea.Cmd {
+	selected, ok := m.list.SelectedItem().(item)
+	if !ok {
+		return nil
+	}
+
+	for i, listItem := range m.list.Items() {
+		if listItem.FilterValue() == selected.FilterValue() {
+			selected.checked = !selected.checked
+
+			return m.list.SetItem(i, selected)
+		}
+	}
+
+	return nil
+}
+
+// checked items of a multi-select list, the highlighted item when none are checked
+func (m model) checked() []string {
+	var checked []string
+
+	for _, listItem := range m.list.Items() {
+		if i, ok := listItem.(item); ok && i.checked {
+			checked = append(checked, i.FilterValue())
+		}
+	}
+
+	if len(checked) == 0 && m.list.SelectedItem() != nil {
+		checked = append(checked, m.list.SelectedItem().FilterValue())
+	}
+
+	return checked
 }
 
 func (m model) Init() tea.Cmd {
@@ -37,8 +99,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		}
 
-		if msg.String() == "enter" {
-			option = m.list.SelectedItem().FilterValue()
+		if m.multi && !m.list.SettingFilter() && key.Matches(msg, toggleKey) {
+			return m, m.toggle()
+		}
+
+		// enter applies the filter of a multi-select list before confirming the selection
+		if msg.String() == "enter" && !(m.multi && m.list.SettingFilter()) {
+			if m.multi {
+				options = m.checked()
+			} else {
+				option = m.list.SelectedItem().FilterValue()
+			}
+
 			return m, tea.Quit
 		}
 	case tea.WindowSizeMsg:
@@ -56,22 +128,46 @@ func (m model) View() string {
 	return docStyle.Render(m.list.View())
 }
 
-func SelectCluster(clusters []map[string]string) string {
+func clusterItems(clusters []map[string]string, multi bool) []list.Item {
 	items := []list.Item{}
 
 	for _, cluster := range clusters {
-		items = append(items, item{title: cluster["name"], desc: "creation date: " + cluster["date"]})
+		desc := "creation date: " + cluster["date"]
+		if cluster["protected"] == "true" {
+			desc = "protected from deletion, " + desc
+		}
+
+		items = append(items, item{title: cluster["name"], desc: desc, protected: cluster["protected"] == "true", multi: multi})
 	}
 
-	m := model{list: list.New(items, list.NewDefaultDelegate(), 0, 0)}
-	m.list.Title = "Existing Clusters"
+	return items
+}
 
+func run(m model) {
 	p := tea.NewProgram(m, tea.WithAltScreen())
 
 	if err := p.Start(); err != nil {
 		fmt.Println("Error running program:", err)
 		os.Exit(1)
 	}
+}
+
+func SelectCluster(clusters []map[string]string) string {
+	m := model{list: list.New(clusterItems(clusters, false), list.NewDefaultDelegate(), 0, 0)}
+	m.list.Title = "Existing Clusters"
+
+	run(m)
 
 	return option
 }
+
+// SelectClusters returns the clusters checked with space, or the highlighted cluster when none are checked
+func SelectClusters(clusters []map[string]string) []string {
+	m := model{list: list.New(clusterItems(clusters, true), list.NewDefaultDelegate(), 0, 0), multi: true}
+	m.list.Title = "Existing Clusters"
+	m.list.AdditionalShortHelpKeys = func() []key.Binding { return []key.Binding{toggleKey} }
+
+	run(m)
+
+	return options
+}